@@ -2,12 +2,16 @@ package cleanup
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/traefik/mesh/cmd"
 	"github.com/traefik/mesh/pkg/cleanup"
+	"github.com/traefik/mesh/pkg/dns"
 	"github.com/traefik/mesh/pkg/k8s"
 	"github.com/traefik/paerser/cli"
 )
@@ -16,7 +20,7 @@ import (
 func NewCmd(cConfig *cmd.CleanupConfiguration, loaders []cli.ResourceLoader) *cli.Command {
 	return &cli.Command{
 		Name:          "cleanup",
-		Description:   `Removes Traefik Mesh shadow services from a Kubernetes cluster.`,
+		Description:   `Removes Traefik Mesh shadow services from a Kubernetes cluster, and restores its DNS configuration.`,
 		Configuration: cConfig,
 		Run: func(_ []string) error {
 			return cleanupCommand(cConfig)
@@ -43,8 +47,44 @@ func cleanupCommand(cConfig *cmd.CleanupConfiguration) error {
 		return fmt.Errorf("error building clients: %w", err)
 	}
 
-	c := cleanup.NewCleanup(logger, clients.KubernetesClient(), cConfig.Namespace)
+	if err = dns.ValidateDomains(cConfig.MeshDomains); err != nil {
+		return fmt.Errorf("invalid mesh domains: %w", err)
+	}
+
+	if cConfig.Wait && cConfig.DNSRolloutTimeout <= 0 {
+		return errors.New("waiting for the DNS pods requires a positive DNS rollout timeout")
+	}
+
+	opts := []cleanup.Option{
+		cleanup.WithDNSOptions(
+			dns.RolloutTimeout(time.Duration(cConfig.DNSRolloutTimeout)),
+			dns.Domains(cConfig.MeshDomains...),
+		),
+	}
+
+	if cConfig.Wait {
+		opts = append(opts, cleanup.WithDNSOptions(dns.ForceRestart()))
+	}
+
+	if cConfig.DryRun {
+		logger.Debug("Dry-run mode enabled, the changes are printed instead of being applied")
+
+		opts = append(opts, cleanup.DryRun(os.Stdout))
+	}
+
+	c := cleanup.NewCleanup(logger, clients, cConfig.Namespace, opts...)
+
+	if err = runCleanup(ctx, c, cConfig.All); err != nil {
+		// Report what has been removed before the failure.
+		_ = c.Report().Write(os.Stdout)
+
+		return err
+	}
+
+	return c.Report().Write(os.Stdout)
+}
 
+func runCleanup(ctx context.Context, c *cleanup.Cleanup, all bool) error {
 	if err := c.CleanShadowServices(ctx); err != nil {
 		return fmt.Errorf("error encountered during cluster cleanup: %w", err)
 	}
@@ -53,5 +93,25 @@ func cleanupCommand(cConfig *cmd.CleanupConfiguration) error {
 		return fmt.Errorf("error encountered during DNS restore: %w", err)
 	}
 
+	if !all {
+		return nil
+	}
+
+	if err := c.CleanDNSBackups(ctx); err != nil {
+		return fmt.Errorf("error encountered during DNS backups cleanup: %w", err)
+	}
+
+	if err := c.CleanMeshCA(ctx); err != nil {
+		return fmt.Errorf("error encountered during mesh CA cleanup: %w", err)
+	}
+
+	if err := c.CleanEvents(ctx); err != nil {
+		return fmt.Errorf("error encountered during Events cleanup: %w", err)
+	}
+
+	if err := c.CleanStatusAnnotations(ctx); err != nil {
+		return fmt.Errorf("error encountered during SMI status annotations cleanup: %w", err)
+	}
+
 	return nil
 }
@@ -2,92 +2,176 @@ package cmd
 
 import (
 	"os"
+	"time"
+
+	ptypes "github.com/traefik/paerser/types"
 )
 
 // TraefikMeshConfiguration wraps the static configuration and extra parameters.
 type TraefikMeshConfiguration struct {
-	ConfigFile       string   `description:"Configuration file to use. If specified all other flags are ignored." export:"true"`
-	KubeConfig       string   `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
-	MasterURL        string   `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
-	LogLevel         string   `description:"The log level." export:"true"`
-	LogFormat        string   `description:"The log format." export:"true"`
-	Debug            bool     `description:"Debug mode, deprecated, use --loglevel=debug instead." export:"true"`
-	ACL              bool     `description:"Enable ACL mode." export:"true"`
-	SMI              bool     `description:"Enable SMI operation, deprecated, use --acl instead." export:"true"`
-	DefaultMode      string   `description:"Default mode for mesh services." export:"true"`
-	Namespace        string   `description:"The namespace that Traefik Mesh is installed in." export:"true"`
-	WatchNamespaces  []string `description:"Namespaces to watch." export:"true"`
-	IgnoreNamespaces []string `description:"Namespaces to ignore." export:"true"`
-	APIPort          int32    `description:"API port for the controller." export:"true"`
-	APIHost          string   `description:"API host for the controller to bind to." export:"true"`
-	LimitHTTPPort    int32    `description:"Number of HTTP ports allocated." export:"true"`
-	LimitTCPPort     int32    `description:"Number of TCP ports allocated." export:"true"`
-	LimitUDPPort     int32    `description:"Number of UDP ports allocated." export:"true"`
+	ConfigFile        string   `description:"Configuration file to use. If specified all other flags are ignored." export:"true"`
+	KubeConfig        string   `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL         string   `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	LogLevel          string   `description:"The log level." export:"true"`
+	LogFormat         string   `description:"The log format." export:"true"`
+	Debug             bool     `description:"Debug mode, deprecated, use --loglevel=debug instead." export:"true"`
+	ACL               bool     `description:"Enable ACL mode." export:"true"`
+	ACLMode           string   `description:"ACL enforcement mode, either ip or identity. The identity mode requires mTLS, and falls back to the ip mode for plain text requests." export:"true"`
+	ACLAuthURL        string   `description:"URL of the controller API used by the proxies to authorize requests in identity ACL mode. Defaults to the traefik-mesh-controller service URL." export:"true"`
+	ACLAuthCA         string   `description:"Path to the CA certificate used by the proxies to verify the controller API certificate, required when the identity ACL mode authorization URL uses https." export:"true"`
+	ACLAudit          bool     `description:"Don't block the HTTP requests denied in ACL mode, tag them with the X-Traefik-Mesh-Acl-Audit header instead." export:"true"`
+	SMI               bool     `description:"Enable SMI operation, deprecated, use --acl instead." export:"true"`
+	DefaultMode       string   `description:"Default mode for mesh services." export:"true"`
+	Namespace         string   `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	WatchNamespaces   []string `description:"Namespaces to watch." export:"true"`
+	IgnoreNamespaces  []string `description:"Namespaces to ignore." export:"true"`
+	NamespaceSelector string   `description:"Label selector restricting the namespaces to watch, e.g. mesh.traefik.io/enabled=true." export:"true"`
+	MeshDomains       []string `description:"Mesh domains the services are reachable through. The first one is the main domain, used to reach the TrafficSplit backends." export:"true"`
+	OptIn             bool     `description:"Only mesh the services explicitly enabled with the mesh.traefik.io/enabled annotation." export:"true"`
+	TracingHeaders    bool     `description:"Tag the mesh traffic with headers identifying the destination services, and the client identity in identity ACL mode." export:"true"`
+	MTLS              bool     `description:"Enable mTLS between the proxies and the pods serving HTTPS, with certificates issued by the mesh CA." export:"true"`
+	MTLSCASecret      string   `description:"Name of the TLS Secret, in the Traefik Mesh namespace, storing the mesh CA. Created if missing." export:"true"`
+	MTLSTrustDomain   string   `description:"SPIFFE trust domain of the identities carried by the mesh certificates." export:"true"`
+	APIPort           int32    `description:"API port for the controller." export:"true"`
+	APIHost           string   `description:"API host for the controller to bind to." export:"true"`
+	APITLSCert        string   `description:"Path to the certificate used to serve the controller API over TLS. Reloaded on change." export:"true"`
+	APITLSKey         string   `description:"Path to the key used to serve the controller API over TLS. Reloaded on change." export:"true"`
+	APIAuth           bool     `description:"Require a Kubernetes bearer token, validated with a TokenReview, to access the controller API." export:"true"`
+	DNS               bool     `description:"Serve the mesh domains from a DNS server embedded in the controller." export:"true"`
+	DNSPort           int32    `description:"Port of the embedded DNS server, over UDP and TCP." export:"true"`
+	LimitHTTPPort     int32    `description:"Number of HTTP ports allocated." export:"true"`
+	LimitTCPPort      int32    `description:"Number of TCP ports allocated." export:"true"`
+	LimitUDPPort      int32    `description:"Number of UDP ports allocated." export:"true"`
 }
 
 // NewTraefikMeshConfiguration creates a TraefikMeshConfiguration with default values.
 func NewTraefikMeshConfiguration() *TraefikMeshConfiguration {
 	return &TraefikMeshConfiguration{
-		ConfigFile:    "",
-		KubeConfig:    os.Getenv("KUBECONFIG"),
-		LogLevel:      "error",
-		LogFormat:     "common",
-		Debug:         false,
-		ACL:           false,
-		SMI:           false,
-		DefaultMode:   "http",
-		Namespace:     "maesh",
-		APIPort:       9000,
-		APIHost:       "",
-		LimitHTTPPort: 10,
-		LimitTCPPort:  25,
-		LimitUDPPort:  25,
+		ConfigFile:      "",
+		KubeConfig:      os.Getenv("KUBECONFIG"),
+		LogLevel:        "error",
+		LogFormat:       "common",
+		Debug:           false,
+		ACL:             false,
+		ACLMode:         "ip",
+		SMI:             false,
+		DefaultMode:     "http",
+		Namespace:       "maesh",
+		MeshDomains:     []string{"traefik.mesh", "maesh"},
+		MTLSCASecret:    "traefik-mesh-ca",
+		MTLSTrustDomain: "cluster.local",
+		APIPort:         9000,
+		APIHost:         "",
+		DNSPort:         5353,
+		LimitHTTPPort:   10,
+		LimitTCPPort:    25,
+		LimitUDPPort:    25,
 	}
 }
 
 // PrepareConfiguration holds the configuration to prepare the cluster.
 type PrepareConfiguration struct {
-	ConfigFile    string `description:"Configuration file to use. If specified all other flags are ignored." export:"true"`
-	KubeConfig    string `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
-	MasterURL     string `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
-	LogLevel      string `description:"The log level." export:"true"`
-	LogFormat     string `description:"The log format." export:"true"`
-	Debug         bool   `description:"Debug mode, deprecated, use --loglevel=debug instead." export:"true"`
-	Namespace     string `description:"The namespace that Traefik Mesh is installed in." export:"true"`
-	ClusterDomain string `description:"Your internal K8s cluster domain." export:"true"`
-	SMI           bool   `description:"Enable SMI operation, deprecated, use --acl instead." export:"true"`
-	ACL           bool   `description:"Enable ACL mode." export:"true"`
+	ConfigFile        string          `description:"Configuration file to use. If specified all other flags are ignored." export:"true"`
+	KubeConfig        string          `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL         string          `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	LogLevel          string          `description:"The log level." export:"true"`
+	LogFormat         string          `description:"The log format." export:"true"`
+	Debug             bool            `description:"Debug mode, deprecated, use --loglevel=debug instead." export:"true"`
+	Namespace         string          `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	ClusterDomain     string          `description:"Your internal K8s cluster domain." export:"true"`
+	MeshDomains       []string        `description:"Mesh domains to configure in the cluster DNS. The first one is the main domain." export:"true"`
+	SMI               bool            `description:"Enable SMI operation, deprecated, use --acl instead." export:"true"`
+	ACL               bool            `description:"Enable ACL mode." export:"true"`
+	DNSForward        string          `description:"Address of the DNS server embedded in the controller, as IP:port. When set, the mesh domains are forwarded to it instead of being rewritten by the cluster DNS." export:"true"`
+	DNSRolloutTimeout ptypes.Duration `description:"Maximum duration to wait for the DNS pods restarted after a configuration change to be available, before restoring the previous configuration. Zero disables the wait." export:"true"`
+	DryRun            bool            `description:"Print the DNS configuration changes, as unified diffs, and the workloads to restart, without applying them." export:"true"`
 }
 
 // NewPrepareConfiguration creates a PrepareConfiguration with default values.
 func NewPrepareConfiguration() *PrepareConfiguration {
 	return &PrepareConfiguration{
-		KubeConfig:    os.Getenv("KUBECONFIG"),
-		LogLevel:      "error",
-		LogFormat:     "common",
-		Debug:         false,
-		Namespace:     "maesh",
-		ClusterDomain: "cluster.local",
-		SMI:           false,
+		KubeConfig:        os.Getenv("KUBECONFIG"),
+		LogLevel:          "error",
+		LogFormat:         "common",
+		Debug:             false,
+		Namespace:         "maesh",
+		ClusterDomain:     "cluster.local",
+		MeshDomains:       []string{"traefik.mesh", "maesh"},
+		SMI:               false,
+		DNSRolloutTimeout: ptypes.Duration(2 * time.Minute),
 	}
 }
 
 // CleanupConfiguration holds the configuration for the cleanup command.
 type CleanupConfiguration struct {
-	ConfigFile string `description:"Configuration file to use. If specified all other flags are ignored." export:"true"`
-	KubeConfig string `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
-	MasterURL  string `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
-	Namespace  string `description:"The namespace that Traefik Mesh is installed in." export:"true"`
-	LogLevel   string `description:"The log level." export:"true"`
-	LogFormat  string `description:"The log format." export:"true"`
+	ConfigFile        string          `description:"Configuration file to use. If specified all other flags are ignored." export:"true"`
+	KubeConfig        string          `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL         string          `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	Namespace         string          `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	MeshDomains       []string        `description:"Mesh domains configured in the cluster DNS, removed when no backup of the DNS configuration is found." export:"true"`
+	LogLevel          string          `description:"The log level." export:"true"`
+	LogFormat         string          `description:"The log format." export:"true"`
+	DNSRolloutTimeout ptypes.Duration `description:"Maximum duration to wait for the DNS pods restarted after a configuration change to be available, before restoring the previous configuration. Zero disables the wait." export:"true"`
+	DryRun            bool            `description:"Print the shadow services to delete, the DNS configuration changes, as unified diffs, and the workloads to restart, without applying them." export:"true"`
+	All               bool            `description:"Also remove the DNS configuration backups left over, the mesh CA Secrets created by the controller, the Events emitted by the controller, and the status annotations of the SMI resources." export:"true"`
+	Wait              bool            `description:"Wait for the DNS pods to be restarted with the restored configuration and to be available, even when CoreDNS would reload it by itself. The wait is bounded by the DNS rollout timeout." export:"true"`
 }
 
 // NewCleanupConfiguration creates CleanupConfiguration.
 func NewCleanupConfiguration() *CleanupConfiguration {
 	return &CleanupConfiguration{
-		KubeConfig: os.Getenv("KUBECONFIG"),
-		Namespace:  "maesh",
-		LogLevel:   "error",
-		LogFormat:  "common",
+		KubeConfig:        os.Getenv("KUBECONFIG"),
+		Namespace:         "maesh",
+		MeshDomains:       []string{"traefik.mesh", "maesh"},
+		LogLevel:          "error",
+		LogFormat:         "common",
+		DNSRolloutTimeout: ptypes.Duration(2 * time.Minute),
+	}
+}
+
+// DoctorConfiguration holds the configuration for the doctor command.
+type DoctorConfiguration struct {
+	ConfigFile               string   `description:"Configuration file to use. If specified all other flags are ignored." export:"true"`
+	KubeConfig               string   `description:"Path to a kubeconfig. Only required if out-of-cluster." export:"true"`
+	MasterURL                string   `description:"The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster." export:"true"`
+	LogLevel                 string   `description:"The log level." export:"true"`
+	LogFormat                string   `description:"The log format." export:"true"`
+	Namespace                string   `description:"The namespace that Traefik Mesh is installed in." export:"true"`
+	ControllerServiceAccount string   `description:"The service account of the controller, in the Traefik Mesh namespace." export:"true"`
+	ClusterDomain            string   `description:"Your internal K8s cluster domain." export:"true"`
+	MeshDomains              []string `description:"Mesh domains expected in the cluster DNS. The first one is the main domain." export:"true"`
+	DNSForward               string   `description:"Address of the DNS server embedded in the controller, as IP:port, if the mesh domains are forwarded to it." export:"true"`
+	ACL                      bool     `description:"Check the requirements of the ACL mode." export:"true"`
+	WatchNamespaces          []string `description:"Namespaces watched by the controller." export:"true"`
+	IgnoreNamespaces         []string `description:"Namespaces ignored by the controller." export:"true"`
+	NamespaceSelector        string   `description:"Label selector restricting the namespaces watched by the controller, if any." export:"true"`
+	OptIn                    bool     `description:"Only count the services explicitly enabled with the mesh.traefik.io/enabled annotation." export:"true"`
+	MTLS                     bool     `description:"Check the requirements of the mTLS mode." export:"true"`
+	DefaultMode              string   `description:"Default mode for mesh services." export:"true"`
+	APIPort                  int32    `description:"API port of the controller." export:"true"`
+	APITLS                   bool     `description:"Reach the controller API over TLS." export:"true"`
+	APIAuth                  bool     `description:"Check the requirements of the controller API authentication." export:"true"`
+	LimitHTTPPort            int32    `description:"Number of HTTP ports allocated." export:"true"`
+	LimitTCPPort             int32    `description:"Number of TCP ports allocated." export:"true"`
+	LimitUDPPort             int32    `description:"Number of UDP ports allocated." export:"true"`
+	Output                   string   `description:"Output format, either text or json." export:"true"`
+}
+
+// NewDoctorConfiguration creates a DoctorConfiguration with default values.
+func NewDoctorConfiguration() *DoctorConfiguration {
+	return &DoctorConfiguration{
+		KubeConfig:               os.Getenv("KUBECONFIG"),
+		LogLevel:                 "error",
+		LogFormat:                "common",
+		Namespace:                "maesh",
+		ControllerServiceAccount: "traefik-mesh-controller",
+		ClusterDomain:            "cluster.local",
+		MeshDomains:              []string{"traefik.mesh", "maesh"},
+		DefaultMode:              "http",
+		APIPort:                  9000,
+		LimitHTTPPort:            10,
+		LimitTCPPort:             25,
+		LimitUDPPort:             25,
+		Output:                   "text",
 	}
 }
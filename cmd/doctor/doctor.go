@@ -0,0 +1,105 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/traefik/mesh/cmd"
+	"github.com/traefik/mesh/pkg/dns"
+	"github.com/traefik/mesh/pkg/doctor"
+	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/paerser/cli"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NewCmd builds a new Doctor command.
+func NewCmd(dConfig *cmd.DoctorConfiguration, loaders []cli.ResourceLoader) *cli.Command {
+	return &cli.Command{
+		Name:          "doctor",
+		Description:   `Checks that a Kubernetes cluster is ready to run Traefik Mesh.`,
+		Configuration: dConfig,
+		Run: func(_ []string) error {
+			return doctorCommand(dConfig)
+		},
+		Resources: loaders,
+	}
+}
+
+func doctorCommand(dConfig *cmd.DoctorConfiguration) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	if dConfig.Output != "text" && dConfig.Output != "json" {
+		return fmt.Errorf("unsupported output format %q", dConfig.Output)
+	}
+
+	if err := dns.ValidateDomains(dConfig.MeshDomains); err != nil {
+		return fmt.Errorf("invalid mesh domains: %w", err)
+	}
+
+	logger, err := cmd.NewLogger(dConfig.LogFormat, dConfig.LogLevel, false)
+	if err != nil {
+		return fmt.Errorf("could not create logger: %w", err)
+	}
+
+	var namespaceSelector labels.Selector
+
+	if dConfig.NamespaceSelector != "" {
+		namespaceSelector, err = labels.Parse(dConfig.NamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("invalid namespace selector %q: %w", dConfig.NamespaceSelector, err)
+		}
+	}
+
+	logger.Debug("Starting doctor...")
+	logger.Debugf("Using masterURL: %q", dConfig.MasterURL)
+	logger.Debugf("Using kubeconfig: %q", dConfig.KubeConfig)
+
+	clients, err := k8s.NewClient(logger, dConfig.MasterURL, dConfig.KubeConfig)
+	if err != nil {
+		return fmt.Errorf("error building clients: %w", err)
+	}
+
+	d := doctor.NewDoctor(logger, clients.KubernetesClient(), doctor.Config{
+		Namespace:                dConfig.Namespace,
+		ControllerServiceAccount: dConfig.ControllerServiceAccount,
+		ClusterDomain:            dConfig.ClusterDomain,
+		MeshDomains:              dConfig.MeshDomains,
+		DNSForward:               dConfig.DNSForward,
+		ACL:                      dConfig.ACL,
+		WatchNamespaces:          dConfig.WatchNamespaces,
+		IgnoreNamespaces:         dConfig.IgnoreNamespaces,
+		NamespaceSelector:        namespaceSelector,
+		OptIn:                    dConfig.OptIn,
+		MTLS:                     dConfig.MTLS,
+		DefaultMode:              dConfig.DefaultMode,
+		LimitHTTPPort:            dConfig.LimitHTTPPort,
+		LimitTCPPort:             dConfig.LimitTCPPort,
+		LimitUDPPort:             dConfig.LimitUDPPort,
+		APIPort:                  dConfig.APIPort,
+		APITLS:                   dConfig.APITLS,
+		APIAuth:                  dConfig.APIAuth,
+	})
+
+	report := d.Run(ctx)
+
+	if dConfig.Output == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to write the report: %w", err)
+	}
+
+	if report.Failed {
+		return errors.New("some checks have failed")
+	}
+
+	return nil
+}
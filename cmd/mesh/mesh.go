@@ -8,19 +8,28 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/cmd"
 	"github.com/traefik/mesh/cmd/cleanup"
+	"github.com/traefik/mesh/cmd/doctor"
 	"github.com/traefik/mesh/cmd/prepare"
 	"github.com/traefik/mesh/cmd/version"
 	"github.com/traefik/mesh/pkg/api"
 	"github.com/traefik/mesh/pkg/controller"
+	"github.com/traefik/mesh/pkg/dns"
 	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/metrics"
+	"github.com/traefik/mesh/pkg/mtls"
 	"github.com/traefik/paerser/cli"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/workqueue"
 )
 
 const (
@@ -29,6 +38,11 @@ const (
 	minUDPPort  = int32(15000)
 )
 
+const (
+	aclModeIP       = "ip"
+	aclModeIdentity = "identity"
+)
+
 func main() {
 	traefikMeshConfig := cmd.NewTraefikMeshConfiguration()
 	traefikMeshLoaders := []cli.ResourceLoader{&cmd.FileLoader{}, &cli.FlagLoader{}, &cmd.EnvLoader{}}
@@ -55,6 +69,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	doctorConfig := cmd.NewDoctorConfiguration()
+	if err := cmdTraefikMesh.AddCommand(doctor.NewCmd(doctorConfig, traefikMeshLoaders)); err != nil {
+		stdlog.Println(err)
+		os.Exit(1)
+	}
+
 	if err := cmdTraefikMesh.AddCommand(version.NewCmd()); err != nil {
 		stdlog.Println(err)
 		os.Exit(1)
@@ -93,28 +113,154 @@ func traefikMeshCommand(config *cmd.TraefikMeshConfiguration) error {
 	aclEnabled := config.ACL || config.SMI
 	log.Debugf("ACL mode enabled: %t", aclEnabled)
 
-	apiServer, err := api.NewAPI(log, config.APIPort, config.APIHost, clients.KubernetesClient(), config.Namespace)
+	switch config.ACLMode {
+	case aclModeIP:
+	case aclModeIdentity:
+		if !config.MTLS {
+			return errors.New("the identity ACL mode requires mTLS to be enabled")
+		}
+	default:
+		return fmt.Errorf("unknown ACL mode %q", config.ACLMode)
+	}
+
+	if config.ACLAudit && aclEnabled {
+		log.Warn("ACL audit mode is enabled, the HTTP requests denied by the ACL are not blocked")
+	}
+
+	if err = dns.ValidateDomains(config.MeshDomains); err != nil {
+		return fmt.Errorf("invalid mesh domains: %w", err)
+	}
+
+	aclIdentity := aclEnabled && config.ACLMode == aclModeIdentity
+	aclAuthURL := config.ACLAuthURL
+
+	if aclIdentity && aclAuthURL == "" {
+		scheme := "http"
+		if config.APITLSCert != "" {
+			scheme = "https"
+		}
+
+		aclAuthURL = fmt.Sprintf("%s://traefik-mesh-controller.%s.svc:%d", scheme, config.Namespace, config.APIPort)
+	}
+
+	var aclAuthCA string
+
+	if aclIdentity && strings.HasPrefix(aclAuthURL, "https://") {
+		if config.ACLAuthCA == "" {
+			return errors.New("the identity ACL mode requires the CA of the API certificate (--aclAuthCA) when the API is served over TLS")
+		}
+
+		caPEM, err := os.ReadFile(config.ACLAuthCA)
+		if err != nil {
+			return fmt.Errorf("unable to read the ACL authorization CA: %w", err)
+		}
+
+		aclAuthCA = string(caPEM)
+	}
+
+	var namespaceSelector labels.Selector
+
+	if config.NamespaceSelector != "" {
+		namespaceSelector, err = labels.Parse(config.NamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("invalid namespace selector %q: %w", config.NamespaceSelector, err)
+		}
+
+		log.Debugf("Using namespace selector: %q", namespaceSelector)
+	}
+
+	promRegistry := prometheus.NewRegistry()
+	promRegistry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	metricsRegistry, err := metrics.NewPrometheusRegistry(promRegistry)
+	if err != nil {
+		return fmt.Errorf("unable to create the metrics registry: %w", err)
+	}
+
+	// The work queue metrics are recorded by the work queue itself, which must be created afterwards.
+	workqueue.SetProvider(metricsRegistry.WorkQueueMetricsProvider())
+
+	apiOpts := []api.Option{api.DefaultMode(config.DefaultMode)}
+
+	if aclEnabled {
+		apiOpts = append(apiOpts, api.ACL())
+	}
+
+	if config.APITLSCert != "" || config.APITLSKey != "" {
+		if config.APITLSCert == "" || config.APITLSKey == "" {
+			return errors.New("both the API TLS certificate and key must be set")
+		}
+
+		apiOpts = append(apiOpts, api.TLSCertificate(config.APITLSCert, config.APITLSKey))
+	}
+
+	if config.APIAuth {
+		apiOpts = append(apiOpts, api.TokenAuthentication())
+	}
+
+	var mtlsRootCA string
+
+	if config.MTLS {
+		// The proxies private keys are delivered by the API.
+		if config.APITLSCert == "" || !config.APIAuth {
+			return errors.New("mTLS requires the API to be served over TLS (--apiTLSCert and --apiTLSKey) with authentication (--apiAuth)")
+		}
+
+		ca, err := mtls.LoadOrCreateCertificateAuthority(ctx, clients.KubernetesClient(), config.Namespace, config.MTLSCASecret)
+		if err != nil {
+			return fmt.Errorf("unable to load the mesh CA: %w", err)
+		}
+
+		mtlsRootCA = string(ca.CertificatePEM())
+		issuer := mtls.NewNodeCertificateIssuer(ca, mtls.DefaultNodeCertificateValidity, config.MTLSTrustDomain, config.MeshDomains)
+
+		apiOpts = append(apiOpts, api.MutualTLS(issuer))
+	}
+
+	apiServer, err := api.NewAPI(log, config.APIPort, config.APIHost, clients.KubernetesClient(), config.Namespace, promRegistry, apiOpts...)
 	if err != nil {
 		return fmt.Errorf("unable to create the API server: %w", err)
 	}
 
+	var dnsServer *dns.Server
+
+	if config.DNS {
+		dnsServer, err = dns.NewServer(log, config.DNSPort, config.APIHost, clients.KubernetesClient(), config.Namespace, config.MeshDomains)
+		if err != nil {
+			return fmt.Errorf("unable to create the DNS server: %w", err)
+		}
+	}
+
 	ctr := controller.NewMeshController(clients, controller.Config{
-		ACLEnabled:       aclEnabled,
-		DefaultMode:      config.DefaultMode,
-		Namespace:        config.Namespace,
-		WatchNamespaces:  config.WatchNamespaces,
-		IgnoreNamespaces: config.IgnoreNamespaces,
-		MinHTTPPort:      minHTTPPort,
-		MaxHTTPPort:      getMaxPort(minHTTPPort, config.LimitHTTPPort),
-		MinTCPPort:       minTCPPort,
-		MaxTCPPort:       getMaxPort(minTCPPort, config.LimitTCPPort),
-		MinUDPPort:       minUDPPort,
-		MaxUDPPort:       getMaxPort(minUDPPort, config.LimitUDPPort),
-	}, apiServer, log)
+		ACLEnabled:        aclEnabled,
+		DefaultMode:       config.DefaultMode,
+		Namespace:         config.Namespace,
+		WatchNamespaces:   config.WatchNamespaces,
+		IgnoreNamespaces:  config.IgnoreNamespaces,
+		NamespaceSelector: namespaceSelector,
+		OptIn:             config.OptIn,
+		TracingHeaders:    config.TracingHeaders,
+		MTLS:              config.MTLS,
+		MTLSRootCA:        mtlsRootCA,
+		MTLSTrustDomain:   config.MTLSTrustDomain,
+		ACLIdentity:       aclIdentity,
+		ACLAuthURL:        strings.TrimSuffix(aclAuthURL, "/"),
+		ACLAuthCA:         aclAuthCA,
+		ACLAudit:          aclEnabled && config.ACLAudit,
+		Domains:           config.MeshDomains,
+		EmbeddedDNS:       config.DNS,
+		MinHTTPPort:       minHTTPPort,
+		MaxHTTPPort:       getMaxPort(minHTTPPort, config.LimitHTTPPort),
+		MinTCPPort:        minTCPPort,
+		MaxTCPPort:        getMaxPort(minTCPPort, config.LimitTCPPort),
+		MinUDPPort:        minUDPPort,
+		MaxUDPPort:        getMaxPort(minUDPPort, config.LimitUDPPort),
+	}, apiServer, metricsRegistry, log)
 
 	var wg sync.WaitGroup
 
 	apiErrCh := make(chan error, 1)
+	dnsErrCh := make(chan error, 1)
 	ctrlErrCh := make(chan error, 1)
 
 	// Start the API server.
@@ -128,6 +274,19 @@ func traefikMeshCommand(config *cmd.TraefikMeshConfiguration) error {
 		}
 	}()
 
+	// Start the DNS server.
+	if dnsServer != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := dnsServer.ListenAndServe(); !errors.Is(err, dns.ErrServerClosed) {
+				dnsErrCh <- fmt.Errorf("DNS server has stopped unexpectedly: %w", err)
+			}
+		}()
+	}
+
 	// Start the Controller.
 	wg.Add(1)
 
@@ -144,14 +303,22 @@ func traefikMeshCommand(config *cmd.TraefikMeshConfiguration) error {
 	case <-ctx.Done():
 		ctr.Shutdown()
 		stopAPIServer(apiServer, log)
+		stopDNSServer(dnsServer, log)
 
 	case err := <-apiErrCh:
 		log.Error(err)
 		ctr.Shutdown()
+		stopDNSServer(dnsServer, log)
+
+	case err := <-dnsErrCh:
+		log.Error(err)
+		ctr.Shutdown()
+		stopAPIServer(apiServer, log)
 
 	case err := <-ctrlErrCh:
 		log.Error(err)
 		stopAPIServer(apiServer, log)
+		stopDNSServer(dnsServer, log)
 	}
 
 	wg.Wait()
@@ -168,6 +335,19 @@ func stopAPIServer(apiServer *api.API, log logrus.FieldLogger) {
 	}
 }
 
+func stopDNSServer(dnsServer *dns.Server, log logrus.FieldLogger) {
+	if dnsServer == nil {
+		return
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := dnsServer.Shutdown(stopCtx); err != nil {
+		log.Errorf("Unable to stop the DNS server: %v", err)
+	}
+}
+
 func getMaxPort(min int32, limit int32) int32 {
 	return min + limit - 1
 }
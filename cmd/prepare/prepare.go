@@ -3,14 +3,15 @@ package prepare
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/traefik/mesh/cmd"
 	"github.com/traefik/mesh/pkg/dns"
 	"github.com/traefik/mesh/pkg/k8s"
 	"github.com/traefik/paerser/cli"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // NewCmd builds a new Prepare command.
@@ -44,7 +45,28 @@ func prepareCommand(pConfig *cmd.PrepareConfiguration) error {
 		return fmt.Errorf("unable to create kubernetes client: %w", err)
 	}
 
-	dnsClient := dns.NewClient(log, client.KubernetesClient())
+	if err = dns.ValidateDomains(pConfig.MeshDomains); err != nil {
+		return fmt.Errorf("invalid mesh domains: %w", err)
+	}
+
+	dnsOpts := []dns.ClientOption{
+		dns.RolloutTimeout(time.Duration(pConfig.DNSRolloutTimeout)),
+		dns.Domains(pConfig.MeshDomains...),
+	}
+
+	if pConfig.DNSForward != "" {
+		log.Debugf("Forwarding the mesh domains to: %q", pConfig.DNSForward)
+
+		dnsOpts = append(dnsOpts, dns.ForwardTo(pConfig.DNSForward))
+	}
+
+	if pConfig.DryRun {
+		log.Debug("Dry-run mode enabled, the changes are printed instead of being applied")
+
+		dnsOpts = append(dnsOpts, dns.DryRun(os.Stdout))
+	}
+
+	dnsClient := dns.NewClient(log, client.KubernetesClient(), dnsOpts...)
 
 	if pConfig.SMI {
 		log.Warnf("SMI mode is deprecated, please consider using --acl instead")
@@ -67,7 +89,12 @@ func prepareCommand(pConfig *cmd.PrepareConfiguration) error {
 
 	switch dnsProvider {
 	case dns.CoreDNS:
-		if err := dnsClient.ConfigureCoreDNS(ctx, metav1.NamespaceSystem, pConfig.ClusterDomain, pConfig.Namespace); err != nil {
+		coreDNSNamespace, err := dnsClient.CoreDNSNamespace(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to find CoreDNS: %w", err)
+		}
+
+		if err := dnsClient.ConfigureCoreDNS(ctx, coreDNSNamespace, pConfig.ClusterDomain, pConfig.Namespace); err != nil {
 			return fmt.Errorf("unable to configure CoreDNS: %w", err)
 		}
 	case dns.KubeDNS:
@@ -76,5 +103,16 @@ func prepareCommand(pConfig *cmd.PrepareConfiguration) error {
 		}
 	}
 
+	nodeLocalDNS, err := dnsClient.CheckNodeLocalDNS(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to check NodeLocal DNSCache: %w", err)
+	}
+
+	if nodeLocalDNS {
+		if err := dnsClient.ConfigureNodeLocalDNS(ctx, pConfig.ClusterDomain, pConfig.Namespace); err != nil {
+			return fmt.Errorf("unable to configure NodeLocal DNSCache: %w", err)
+		}
+	}
+
 	return nil
 }
@@ -3,7 +3,9 @@ package annotations
 import (
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -23,17 +25,36 @@ const (
 )
 
 const (
+	annotationEnabled                  = "enabled"
 	annotationServiceType              = "traffic-type"
 	annotationScheme                   = "scheme"
 	annotationRetryAttempts            = "retry-attempts"
 	annotationCircuitBreakerExpression = "circuit-breaker-expression"
 	annotationRateLimitAverage         = "ratelimit-average"
 	annotationRateLimitBurst           = "ratelimit-burst"
+	annotationMaxConnections           = "max-connections"
+	annotationIPWhiteList              = "ip-whitelist"
 )
 
 // ErrNotFound indicates that the annotation hasn't been found.
 var ErrNotFound = errors.New("annotation not found")
 
+// IsMeshEnabled returns the value of the enabled annotation, which allows services and namespaces to opt in or opt out
+// of the mesh.
+func IsMeshEnabled(annotations map[string]string) (bool, error) {
+	enabled, exists := getAnnotation(annotations, annotationEnabled)
+	if !exists {
+		return false, ErrNotFound
+	}
+
+	value, err := strconv.ParseBool(enabled)
+	if err != nil {
+		return false, fmt.Errorf("invalid value %q: %w", annotationEnabled, err)
+	}
+
+	return value, nil
+}
+
 // GetTrafficType returns the value of the traffic-type annotation.
 func GetTrafficType(defaultTrafficType string, annotations map[string]string) (string, error) {
 	trafficType, exists := getAnnotation(annotations, annotationServiceType)
@@ -125,6 +146,52 @@ func GetRateLimitAverage(annotations map[string]string) (int, error) {
 	return average, nil
 }
 
+// GetMaxConnections returns the value of the max-connections annotation.
+func GetMaxConnections(annotations map[string]string) (int, error) {
+	maxConnections, exists := getAnnotation(annotations, annotationMaxConnections)
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	connections, err := strconv.Atoi(maxConnections)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %w", annotationMaxConnections, err)
+	}
+
+	return connections, nil
+}
+
+// GetIPWhiteList returns the value of the ip-whitelist annotation, which is a comma separated list of IPs or CIDRs.
+func GetIPWhiteList(annotations map[string]string) ([]string, error) {
+	ipWhiteList, exists := getAnnotation(annotations, annotationIPWhiteList)
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var sourceRange []string
+
+	for _, source := range strings.Split(ipWhiteList, ",") {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+
+		if net.ParseIP(source) == nil {
+			if _, _, err := net.ParseCIDR(source); err != nil {
+				return nil, fmt.Errorf("invalid value %q: %q is neither an IP nor a CIDR", annotationIPWhiteList, source)
+			}
+		}
+
+		sourceRange = append(sourceRange, source)
+	}
+
+	if len(sourceRange) == 0 {
+		return nil, fmt.Errorf("invalid value %q: at least one IP or CIDR is required", annotationIPWhiteList)
+	}
+
+	return sourceRange, nil
+}
+
 // getAnnotation returns the value of the annotation with the given name and a boolean evaluating to true if the
 // annotation has been found, false otherwise. This function will try to resolve the annotation with the traefik mesh
 // domain prefix and fallback to the deprecated maesh domain prefix if not found.
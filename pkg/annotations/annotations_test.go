@@ -8,6 +8,61 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestIsMeshEnabled(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         bool
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/enabled": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "true",
+			annotations: map[string]string{
+				"mesh.traefik.io/enabled": "true",
+			},
+			want: true,
+		},
+		{
+			desc: "false",
+			annotations: map[string]string{
+				"mesh.traefik.io/enabled": "false",
+			},
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			enabled, err := IsMeshEnabled(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, enabled)
+		})
+	}
+}
+
 func TestGetTrafficType(t *testing.T) {
 	tests := []struct {
 		desc        string
@@ -311,6 +366,109 @@ func TestGetRateLimitAverage(t *testing.T) {
 	}
 }
 
+func TestGetMaxConnections(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         int
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc: "invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-connections": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-connections": "100",
+			},
+			want: 100,
+		},
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			maxConnections, err := GetMaxConnections(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, maxConnections)
+		})
+	}
+}
+
+func TestGetIPWhiteList(t *testing.T) {
+	tests := []struct {
+		desc         string
+		annotations  map[string]string
+		want         []string
+		err          bool
+		wantNotFound bool
+	}{
+		{
+			desc:         "not set",
+			annotations:  map[string]string{},
+			err:          true,
+			wantNotFound: true,
+		},
+		{
+			desc: "invalid source",
+			annotations: map[string]string{
+				"mesh.traefik.io/ip-whitelist": "10.0.0.0/8, my-pod",
+			},
+			err: true,
+		},
+		{
+			desc: "empty",
+			annotations: map[string]string{
+				"mesh.traefik.io/ip-whitelist": " , ",
+			},
+			err: true,
+		},
+		{
+			desc: "valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/ip-whitelist": "10.0.0.0/8, 192.168.1.7,fd00::/8,",
+			},
+			want: []string{"10.0.0.0/8", "192.168.1.7", "fd00::/8"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			sourceRange, err := GetIPWhiteList(test.annotations)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, test.wantNotFound, errors.Is(err, ErrNotFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, sourceRange)
+		})
+	}
+}
+
 func Test_getAnnotation(t *testing.T) {
 	tests := []struct {
 		desc        string
@@ -9,6 +9,8 @@ import (
 
 type middlewareBuilder func(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error)
 
+type tcpMiddlewareBuilder func(annotations map[string]string) (middleware *dynamic.TCPMiddleware, name string, err error)
+
 // BuildMiddlewares builds middlewares from the given annotations.
 func BuildMiddlewares(annotations map[string]string) (map[string]*dynamic.Middleware, error) {
 	builders := []middlewareBuilder{
@@ -33,6 +35,29 @@ func BuildMiddlewares(annotations map[string]string) (map[string]*dynamic.Middle
 	return middlewares, nil
 }
 
+// BuildTCPMiddlewares builds TCP middlewares from the given annotations.
+func BuildTCPMiddlewares(annotations map[string]string) (map[string]*dynamic.TCPMiddleware, error) {
+	builders := []tcpMiddlewareBuilder{
+		buildInFlightConnMiddleware,
+		buildTCPIPWhiteListMiddleware,
+	}
+
+	middlewares := map[string]*dynamic.TCPMiddleware{}
+
+	for _, builder := range builders {
+		middleware, name, err := builder(annotations)
+		if err != nil {
+			return nil, err
+		}
+
+		if middleware != nil {
+			middlewares[name] = middleware
+		}
+	}
+
+	return middlewares, nil
+}
+
 func buildRetryMiddleware(annotations map[string]string) (middleware *dynamic.Middleware, name string, err error) {
 	var retryAttempts int
 
@@ -109,3 +134,47 @@ func buildCircuitBreakerMiddleware(annotations map[string]string) (middleware *d
 
 	return middleware, name, nil
 }
+
+func buildInFlightConnMiddleware(annotations map[string]string) (middleware *dynamic.TCPMiddleware, name string, err error) {
+	var maxConnections int
+
+	maxConnections, err = GetMaxConnections(annotations)
+	if errors.Is(err, ErrNotFound) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", fmt.Errorf("unable to build in-flight-conn middleware: %w", err)
+	}
+
+	if maxConnections <= 0 {
+		return nil, "", errors.New("unable to build in-flight-conn middleware: max-connections must be greater than 0")
+	}
+
+	name = "in-flight-conn"
+	middleware = &dynamic.TCPMiddleware{
+		InFlightConn: &dynamic.TCPInFlightConn{
+			Amount: int64(maxConnections),
+		},
+	}
+
+	return middleware, name, nil
+}
+
+func buildTCPIPWhiteListMiddleware(annotations map[string]string) (middleware *dynamic.TCPMiddleware, name string, err error) {
+	var sourceRange []string
+
+	sourceRange, err = GetIPWhiteList(annotations)
+	if errors.Is(err, ErrNotFound) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", fmt.Errorf("unable to build ip-whitelist middleware: %w", err)
+	}
+
+	name = "ip-whitelist"
+	middleware = &dynamic.TCPMiddleware{
+		IPWhiteList: &dynamic.TCPIPWhiteList{
+			SourceRange: sourceRange,
+		},
+	}
+
+	return middleware, name, nil
+}
@@ -142,3 +142,87 @@ func TestBuildMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildTCPMiddlewares(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        map[string]*dynamic.TCPMiddleware
+		err         bool
+	}{
+		{
+			desc:        "empty when no middleware have been created",
+			annotations: map[string]string{},
+			want:        map[string]*dynamic.TCPMiddleware{},
+		},
+		{
+			desc: "max-connections annotation is valid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-connections": "100",
+			},
+			want: map[string]*dynamic.TCPMiddleware{
+				"in-flight-conn": {
+					InFlightConn: &dynamic.TCPInFlightConn{
+						Amount: 100,
+					},
+				},
+			},
+		},
+		{
+			desc: "max-connections annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-connections": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "max-connections annotation is not greater than 0",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-connections": "0",
+			},
+			err: true,
+		},
+		{
+			desc: "ip-whitelist annotation is invalid",
+			annotations: map[string]string{
+				"mesh.traefik.io/ip-whitelist": "hello",
+			},
+			err: true,
+		},
+		{
+			desc: "multiple middlewares",
+			annotations: map[string]string{
+				"mesh.traefik.io/max-connections": "100",
+				"mesh.traefik.io/ip-whitelist":    "10.0.0.0/8, 192.168.1.7",
+			},
+			want: map[string]*dynamic.TCPMiddleware{
+				"in-flight-conn": {
+					InFlightConn: &dynamic.TCPInFlightConn{
+						Amount: 100,
+					},
+				},
+				"ip-whitelist": {
+					IPWhiteList: &dynamic.TCPIPWhiteList{
+						SourceRange: []string{"10.0.0.0/8", "192.168.1.7"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := BuildTCPMiddlewares(test.annotations)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
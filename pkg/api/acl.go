@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/traefik/mesh/pkg/provider"
+)
+
+// clientCertInfoHeader is the header in which the proxies pass the SANs of the client certificate.
+const clientCertInfoHeader = provider.ACLClientCertInfoHeader
+
+// authorizeIdentity authorizes, in identity ACL mode, a request forwarded by a proxy. The request is allowed if the
+// client certificate carries one of the identities given in the "identity" query parameters, which is returned in the
+// source identity header. In audit mode, the request is always allowed, and tagged with the audit header if it would
+// have been denied.
+func (a *API) authorizeIdentity(w http.ResponseWriter, r *http.Request) {
+	allowed := make(map[string]struct{})
+	for _, identity := range r.URL.Query()["identity"] {
+		allowed[identity] = struct{}{}
+	}
+
+	for _, identity := range clientCertIdentities(r.Header.Get(clientCertInfoHeader)) {
+		if _, ok := allowed[identity]; ok {
+			w.Header().Set(provider.ACLSourceIdentityHeader, identity)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("audit") == "true" {
+		a.log.Infof("ACL audit: request to %q from client certificate %q would have been denied", r.Header.Get("X-Forwarded-Host"), r.Header.Get(clientCertInfoHeader))
+
+		w.Header().Set(provider.ACLAuditHeader, provider.ACLAuditDenied)
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	a.log.Debugf("Rejecting request to %q from client certificate %q", r.Header.Get("X-Forwarded-Host"), r.Header.Get(clientCertInfoHeader))
+
+	http.Error(w, "", http.StatusForbidden)
+}
+
+// clientCertIdentities returns the SPIFFE IDs of the leaf certificate described in the given client certificate info
+// header. The header value is URL-escaped, and lists the certificates of the chain separated by commas, each of them
+// being described by a SAN="<san>,<san>" field.
+func clientCertIdentities(header string) []string {
+	info, err := url.QueryUnescape(header)
+	if err != nil {
+		return nil
+	}
+
+	_, sans, ok := strings.Cut(info, `SAN="`)
+	if !ok {
+		return nil
+	}
+
+	sans, _, ok = strings.Cut(sans, `"`)
+	if !ok {
+		return nil
+	}
+
+	var identities []string
+
+	for _, san := range strings.Split(sans, ",") {
+		if strings.HasPrefix(san, "spiffe://") {
+			identities = append(identities, san)
+		}
+	}
+
+	return identities
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAuthorizeIdentity(t *testing.T) {
+	tests := []struct {
+		desc         string
+		identities   []string
+		certInfo     string
+		audit        bool
+		wantCode     int
+		wantAudit    string
+		wantIdentity string
+	}{
+		{
+			desc:         "allowed identity",
+			identities:   []string{"spiffe://cluster.local/ns/my-ns/sa/client", "spiffe://cluster.local/ns/my-ns/sa/other"},
+			certInfo:     `SAN="10.10.1.1,spiffe://cluster.local/ns/my-ns/sa/client"`,
+			wantCode:     http.StatusOK,
+			wantIdentity: "spiffe://cluster.local/ns/my-ns/sa/client",
+		},
+		{
+			desc:       "unknown identity",
+			identities: []string{"spiffe://cluster.local/ns/my-ns/sa/client"},
+			certInfo:   `SAN="spiffe://cluster.local/ns/my-ns/sa/other"`,
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			desc:       "identity of an intermediate certificate",
+			identities: []string{"spiffe://cluster.local/ns/my-ns/sa/client"},
+			certInfo:   `SAN="spiffe://cluster.local/ns/my-ns/sa/other",SAN="spiffe://cluster.local/ns/my-ns/sa/client"`,
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			desc:       "no client certificate",
+			identities: []string{"spiffe://cluster.local/ns/my-ns/sa/client"},
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			desc:         "audit mode: allowed identity",
+			identities:   []string{"spiffe://cluster.local/ns/my-ns/sa/client"},
+			certInfo:     `SAN="spiffe://cluster.local/ns/my-ns/sa/client"`,
+			audit:        true,
+			wantCode:     http.StatusOK,
+			wantIdentity: "spiffe://cluster.local/ns/my-ns/sa/client",
+		},
+		{
+			desc:       "audit mode: unknown identity",
+			identities: []string{"spiffe://cluster.local/ns/my-ns/sa/client"},
+			certInfo:   `SAN="spiffe://cluster.local/ns/my-ns/sa/other"`,
+			audit:      true,
+			wantCode:   http.StatusOK,
+			wantAudit:  "denied",
+		},
+		{
+			desc:     "no allowed identity",
+			certInfo: `SAN="spiffe://cluster.local/ns/my-ns/sa/client"`,
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			api, err := NewAPI(log, 9000, localhost, fake.NewSimpleClientset(), "foo", prometheus.NewRegistry())
+			require.NoError(t, err)
+
+			query := make(url.Values)
+			for _, identity := range test.identities {
+				query.Add("identity", identity)
+			}
+
+			if test.audit {
+				query.Set("audit", "true")
+			}
+
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/acl/authorize?"+query.Encode(), nil)
+
+			if test.certInfo != "" {
+				req.Header.Set(clientCertInfoHeader, url.QueryEscape(test.certInfo))
+			}
+
+			api.Handler.ServeHTTP(res, req)
+
+			assert.Equal(t, test.wantCode, res.Code)
+			assert.Equal(t, test.wantAudit, res.Header().Get("X-Traefik-Mesh-Acl-Audit"))
+			assert.Equal(t, test.wantIdentity, res.Header().Get("X-Traefik-Mesh-Source-Identity"))
+		})
+	}
+}
@@ -2,16 +2,22 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/pkg/annotations"
 	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/mtls"
 	"github.com/traefik/mesh/pkg/provider"
 	"github.com/traefik/mesh/pkg/safe"
 	"github.com/traefik/mesh/pkg/topology"
@@ -19,22 +25,93 @@ import (
 	kubeerror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	listers "k8s.io/client-go/listers/core/v1"
 )
 
+// maxWatchTimeout is the maximum duration a configuration watch request waits for a new configuration.
+const maxWatchTimeout = 30 * time.Second
+
 // API is an implementation of an api.
 type API struct {
 	http.Server
 
 	readiness     *safe.Safe
-	configuration *safe.Safe
+	configuration *configurationStore
 	topology      *safe.Safe
+	history       *history
+	certificates  *mtls.NodeCertificateIssuer
+	nodeConfigs   nodeConfigurations
 
 	namespace string
 	podLister listers.PodLister
-	log       logrus.FieldLogger
+	// stopCh stops the informer keeping the mesh nodes cache up to date. It is closed on Shutdown.
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// meshNodePort is the port of the Traefik API of the mesh nodes.
+	meshNodePort string
+	// meshNodeRetryDelay is the delay before fetching again the configuration of a mesh node which seems to have
+	// diverged.
+	meshNodeRetryDelay time.Duration
+	// aclEnabled and defaultMode are the settings the policy checks are evaluated with.
+	aclEnabled  bool
+	defaultMode string
+	log         logrus.FieldLogger
+}
+
+// Option configures the API.
+type Option func(opts *options)
+
+type options struct {
+	tlsCertFile      string
+	tlsKeyFile       string
+	tokenAuthEnabled bool
+	certificates     *mtls.NodeCertificateIssuer
+	aclEnabled       bool
+	defaultMode      string
+}
+
+// TLSCertificate serves the API over TLS, using the certificate and key stored in the given files. The files are
+// reloaded when they change.
+func TLSCertificate(certFile, keyFile string) Option {
+	return func(opts *options) {
+		opts.tlsCertFile = certFile
+		opts.tlsKeyFile = keyFile
+	}
+}
+
+// TokenAuthentication requires the API requests to carry a Kubernetes bearer token, validated with a TokenReview.
+func TokenAuthentication() Option {
+	return func(opts *options) {
+		opts.tokenAuthEnabled = true
+	}
+}
+
+// MutualTLS adds the certificate issued by the given issuer to the configuration served to each mesh node, and exposes
+// the status of the certificates on the /api/status/certificates endpoint. It requires the TLSCertificate and the
+// TokenAuthentication options.
+func MutualTLS(issuer *mtls.NodeCertificateIssuer) Option {
+	return func(opts *options) {
+		opts.certificates = issuer
+	}
+}
+
+// ACL makes the /api/policy/check endpoint evaluate the requests as enforced in ACL mode. Without this option, all
+// the requests are reported as allowed.
+func ACL() Option {
+	return func(opts *options) {
+		opts.aclEnabled = true
+	}
+}
+
+// DefaultMode sets the traffic type of the services without a traffic-type annotation, used by the
+// /api/policy/check endpoint. It defaults to HTTP.
+func DefaultMode(mode string) Option {
+	return func(opts *options) {
+		opts.defaultMode = mode
+	}
 }
 
 type podInfo struct {
@@ -43,8 +120,18 @@ type podInfo struct {
 	Ready bool
 }
 
-// NewAPI creates a new api.
-func NewAPI(log logrus.FieldLogger, port int32, host string, client kubernetes.Interface, namespace string) (*API, error) {
+// NewAPI creates a new api. The metrics collected by the given gatherer are exposed on the /metrics endpoint.
+func NewAPI(log logrus.FieldLogger, port int32, host string, client kubernetes.Interface, namespace string, gatherer prometheus.Gatherer, opts ...Option) (*API, error) {
+	apiOpts := options{defaultMode: annotations.ServiceTypeHTTP}
+	for _, opt := range opts {
+		opt(&apiOpts)
+	}
+
+	// The configuration served to the mesh nodes carries their private key in mTLS mode.
+	if apiOpts.certificates != nil && (apiOpts.tlsCertFile == "" || !apiOpts.tokenAuthEnabled) {
+		return nil, errors.New("mTLS requires the API to be served over TLS with token authentication")
+	}
+
 	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
 		MatchLabels: map[string]string{"component": "maesh-mesh"},
 	})
@@ -60,43 +147,103 @@ func NewAPI(log logrus.FieldLogger, port int32, host string, client kubernetes.I
 
 	podLister := informerFactory.Core().V1().Pods().Lister()
 
+	// The informer runs for the whole lifetime of the API, only the initial cache sync is bounded.
+	stopCh := make(chan struct{})
+	informerFactory.Start(stopCh)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	informerFactory.Start(ctx.Done())
-
 	for t, ok := range informerFactory.WaitForCacheSync(ctx.Done()) {
 		if !ok {
+			close(stopCh)
+
 			return nil, fmt.Errorf("timed out while waiting for informer cache to sync: %s", t)
 		}
 	}
 
+	configuration, err := newConfigurationStore(provider.NewDefaultDynamicConfig())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the configuration store: %w", err)
+	}
+
 	router := mux.NewRouter()
 
 	api := &API{
 		Server: http.Server{
 			Addr:         fmt.Sprintf("%s:%d", host, port),
 			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 5 * time.Second,
+			WriteTimeout: maxWatchTimeout + 5*time.Second,
 			Handler:      router,
 		},
-		configuration: safe.New(provider.NewDefaultDynamicConfig()),
-		topology:      safe.New(topology.NewTopology()),
-		readiness:     safe.New(false),
-		podLister:     podLister,
-		namespace:     namespace,
-		log:           log,
+		configuration:      configuration,
+		topology:           safe.New(topology.NewTopology()),
+		history:            newHistory(historySize),
+		certificates:       apiOpts.certificates,
+		nodeConfigs:        nodeConfigurations{configs: make(map[types.UID]nodeConfiguration)},
+		readiness:          safe.New(false),
+		podLister:          podLister,
+		stopCh:             stopCh,
+		meshNodePort:       "8080",
+		meshNodeRetryDelay: meshNodeRetryDelay,
+		aclEnabled:         apiOpts.aclEnabled,
+		defaultMode:        apiOpts.defaultMode,
+		namespace:          namespace,
+		log:                log,
 	}
 
 	router.HandleFunc("/api/configuration/current", api.getCurrentConfiguration)
+	router.HandleFunc("/api/configuration/watch", api.watchConfiguration)
+	router.HandleFunc("/api/configuration/history", api.getConfigurationHistory)
+	router.HandleFunc("/api/configuration/history/{version}", api.getConfigurationHistoryEntry)
+	router.HandleFunc("/api/configuration/diff", api.getConfigurationDiff)
 	router.HandleFunc("/api/topology/current", api.getCurrentTopology)
+	router.HandleFunc("/api/policy/check", api.checkPolicy)
 	router.HandleFunc("/api/status/nodes", api.getMeshNodes)
 	router.HandleFunc("/api/status/node/{node}/configuration", api.getMeshNodeConfiguration)
+	router.HandleFunc("/api/status/sync", api.getSyncStatus)
+	router.HandleFunc("/api/status/certificates", api.getCertificates)
 	router.HandleFunc("/api/status/readiness", api.getReadiness)
+	router.HandleFunc(provider.ACLIdentityAuthorizePath, api.authorizeIdentity)
+	router.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+
+	if apiOpts.tokenAuthEnabled {
+		api.Handler = newAuthenticator(client, podLister, log).Wrap(router)
+	}
+
+	if apiOpts.tlsCertFile != "" || apiOpts.tlsKeyFile != "" {
+		loader, err := newCertificateLoader(apiOpts.tlsCertFile, apiOpts.tlsKeyFile, log)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the API certificate: %w", err)
+		}
+
+		api.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: loader.GetCertificate,
+		}
+	}
 
 	return api, nil
 }
 
+// Shutdown stops the informer of the API and gracefully shuts down the server.
+func (a *API) Shutdown(ctx context.Context) error {
+	a.stopOnce.Do(func() {
+		close(a.stopCh)
+	})
+
+	return a.Server.Shutdown(ctx)
+}
+
+// ListenAndServe listens on the API address and serves the API, over TLS if a certificate has been configured.
+func (a *API) ListenAndServe() error {
+	if a.TLSConfig != nil {
+		return a.Server.ListenAndServeTLS("", "")
+	}
+
+	return a.Server.ListenAndServe()
+}
+
 // SetReadiness sets the readiness flag in the API.
 func (a *API) SetReadiness(isReady bool) {
 	a.readiness.Set(isReady)
@@ -105,7 +252,16 @@ func (a *API) SetReadiness(isReady bool) {
 
 // SetConfig sets the current dynamic configuration.
 func (a *API) SetConfig(cfg *dynamic.Configuration) {
-	a.configuration.Set(cfg)
+	changed, err := a.configuration.Set(cfg)
+	if err != nil {
+		a.log.Errorf("Unable to set dynamic configuration: %v", err)
+		return
+	}
+
+	if changed {
+		_, version, _ := a.configuration.Get()
+		a.log.Debugf("Dynamic configuration version: %s", version)
+	}
 }
 
 // SetTopology sets the current topology.
@@ -113,12 +269,178 @@ func (a *API) SetTopology(topo *topology.Topology) {
 	a.topology.Set(topo)
 }
 
-// getCurrentConfiguration returns the current configuration.
-func (a *API) getCurrentConfiguration(w http.ResponseWriter, _ *http.Request) {
+// RecordHistory records the current configuration and topology in the configuration history, unless the
+// configuration is the same as the latest recorded one.
+func (a *API) RecordHistory(trigger string) {
+	data, version, _ := a.configuration.Get()
+	topo, _ := a.topology.Get().(*topology.Topology)
+
+	a.history.Add(historyEntry{
+		Version:       version,
+		Timestamp:     time.Now(),
+		Trigger:       trigger,
+		Configuration: data,
+		Topology:      topo,
+	})
+}
+
+// getCurrentConfiguration returns the current configuration. The response carries the configuration version in the
+// ETag header, and a 304 response is returned if the If-None-Match header matches the current version.
+func (a *API) getCurrentConfiguration(w http.ResponseWriter, r *http.Request) {
+	data, version, _ := a.configuration.Get()
+	data, version = a.nodeConfiguration(r, data, version)
+
+	a.writeConfiguration(w, r, data, version)
+}
+
+// watchConfiguration waits for the configuration to differ from the version given in the If-None-Match header before
+// returning it. If the configuration doesn't change before the timeout, given in the "timeout" query parameter, a 304
+// response is returned. The configuration is returned right away if the If-None-Match header is not set.
+func (a *API) watchConfiguration(w http.ResponseWriter, r *http.Request) {
+	timeout := maxWatchTimeout
+
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		var err error
+
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			http.Error(w, fmt.Sprintf("invalid timeout %q", value), http.StatusBadRequest)
+			return
+		}
+
+		if timeout > maxWatchTimeout {
+			timeout = maxWatchTimeout
+		}
+	}
+
+	data, version, changed := a.configuration.Get()
+	data, version = a.nodeConfiguration(r, data, version)
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || !matchesETag(ifNoneMatch, etag(version)) {
+		a.writeConfiguration(w, r, data, version)
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-changed:
+		data, version, _ = a.configuration.Get()
+		data, version = a.nodeConfiguration(r, data, version)
+
+		a.writeConfiguration(w, r, data, version)
+
+	case <-timer.C:
+		w.Header().Set("ETag", etag(version))
+		w.WriteHeader(http.StatusNotModified)
+
+	case <-r.Context().Done():
+	}
+}
+
+// writeConfiguration writes the given serialized configuration, or a 304 response if the If-None-Match header
+// matches its version.
+func (a *API) writeConfiguration(w http.ResponseWriter, r *http.Request, data []byte, version string) {
+	tag := etag(version)
+
+	w.Header().Set("ETag", tag)
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && matchesETag(ifNoneMatch, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(data); err != nil {
+		a.log.Errorf("Unable to write dynamic configuration: %v", err)
+	}
+}
+
+// getConfigurationHistory returns the version, timestamp and trigger of the configurations recorded in the history,
+// from the newest to the oldest.
+func (a *API) getConfigurationHistory(w http.ResponseWriter, _ *http.Request) {
+	entries := a.history.List()
+
+	for i := range entries {
+		entries[i].Configuration = nil
+		entries[i].Topology = nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		a.log.Errorf("Unable to serialize configuration history: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// getConfigurationHistoryEntry returns the configuration and the topology recorded in the history with the given
+// version.
+func (a *API) getConfigurationHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	entry, ok := a.history.Get(vars["version"])
+	if !ok {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		a.log.Errorf("Unable to serialize configuration history entry: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// getConfigurationDiff returns the difference between the routers, services and middlewares of the configurations
+// with the versions given in the "from" and "to" query parameters. When "to" is not set, the latest configuration is
+// used, and when "from" is not set, the configuration preceding "to" is used.
+func (a *API) getConfigurationDiff(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var (
+		to, from historyEntry
+		ok       bool
+	)
+
+	if version := query.Get("to"); version != "" {
+		to, ok = a.history.Get(version)
+	} else if entries := a.history.List(); len(entries) > 0 {
+		to, ok = entries[0], true
+	}
+
+	if !ok {
+		http.Error(w, "unable to find the \"to\" configuration", http.StatusNotFound)
+		return
+	}
+
+	if version := query.Get("from"); version != "" {
+		from, ok = a.history.Get(version)
+	} else {
+		from, ok = a.history.Previous(to.Version)
+	}
+
+	if !ok {
+		http.Error(w, "unable to find the \"from\" configuration", http.StatusNotFound)
+		return
+	}
+
+	diff, err := diffConfigurations(from, to)
+	if err != nil {
+		a.log.Errorf("Unable to compute configuration diff: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
-	if err := json.NewEncoder(w).Encode(a.configuration.Get()); err != nil {
-		a.log.Errorf("Unable to serialize dynamic configuration: %v", err)
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		a.log.Errorf("Unable to serialize configuration diff: %v", err)
 		http.Error(w, "", http.StatusInternalServerError)
 	}
 }
@@ -204,7 +526,7 @@ func (a *API) getMeshNodeConfiguration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := http.Get(fmt.Sprintf("http://%s/api/rawdata", net.JoinHostPort(pod.Status.PodIP, "8080")))
+	resp, err := http.Get(a.meshNodeRawDataURL(pod))
 	if err != nil {
 		a.log.Errorf("Unable to get configuration from pod %q: %v", pod.Name, err)
 		http.Error(w, "", http.StatusBadGateway)
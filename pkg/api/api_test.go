@@ -1,17 +1,21 @@
 package api
 
 import (
+	"encoding/json"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -23,7 +27,7 @@ func TestEnableReadiness(t *testing.T) {
 	log.SetLevel(logrus.DebugLevel)
 
 	client := fake.NewSimpleClientset()
-	api, err := NewAPI(log, 9000, localhost, client, "foo")
+	api, err := NewAPI(log, 9000, localhost, client, "foo", prometheus.NewRegistry())
 
 	require.NoError(t, err)
 	assert.Equal(t, false, api.readiness.Get().(bool))
@@ -61,7 +65,7 @@ func TestGetReadiness(t *testing.T) {
 			log.SetLevel(logrus.DebugLevel)
 
 			client := fake.NewSimpleClientset()
-			api, err := NewAPI(log, 9000, localhost, client, "foo")
+			api, err := NewAPI(log, 9000, localhost, client, "foo", prometheus.NewRegistry())
 
 			require.NoError(t, err)
 			api.readiness.Set(test.readiness)
@@ -87,10 +91,12 @@ func TestGetCurrentConfiguration(t *testing.T) {
 	log.SetLevel(logrus.DebugLevel)
 
 	client := fake.NewSimpleClientset()
-	api, err := NewAPI(log, 9000, localhost, client, "foo")
+	api, err := NewAPI(log, 9000, localhost, client, "foo", prometheus.NewRegistry())
 
 	require.NoError(t, err)
-	api.configuration.Set("foo")
+
+	_, err = api.configuration.Set("foo")
+	require.NoError(t, err)
 
 	res := httptest.NewRecorder()
 
@@ -103,6 +109,197 @@ func TestGetCurrentConfiguration(t *testing.T) {
 	api.getCurrentConfiguration(res, req)
 
 	assert.Equal(t, "\"foo\"\n", res.Body.String())
+
+	tag := res.Header().Get("ETag")
+	assert.NotEmpty(t, tag)
+
+	// The same configuration must not be sent again to a client which already has it.
+	res = httptest.NewRecorder()
+
+	req, err = http.NewRequest(http.MethodGet, "/api/configuration/current", nil)
+	require.NoError(t, err)
+
+	req.Header.Set("If-None-Match", tag)
+
+	api.getCurrentConfiguration(res, req)
+
+	assert.Equal(t, http.StatusNotModified, res.Code)
+	assert.Empty(t, res.Body.String())
+	assert.Equal(t, tag, res.Header().Get("ETag"))
+}
+
+func TestWatchConfiguration(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	client := fake.NewSimpleClientset()
+	api, err := NewAPI(log, 9000, localhost, client, "foo", prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	_, err = api.configuration.Set("foo")
+	require.NoError(t, err)
+
+	_, version, _ := api.configuration.Get()
+
+	// Without If-None-Match header, the current configuration is returned right away.
+	res := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodGet, "/api/configuration/watch", nil)
+	require.NoError(t, err)
+
+	api.watchConfiguration(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "\"foo\"\n", res.Body.String())
+	assert.Equal(t, etag(version), res.Header().Get("ETag"))
+
+	// If the configuration doesn't change before the timeout, a 304 response is returned.
+	res = httptest.NewRecorder()
+
+	req, err = http.NewRequest(http.MethodGet, "/api/configuration/watch?timeout=10ms", nil)
+	require.NoError(t, err)
+
+	req.Header.Set("If-None-Match", etag(version))
+
+	api.watchConfiguration(res, req)
+
+	assert.Equal(t, http.StatusNotModified, res.Code)
+
+	// The new configuration is returned as soon as it changes.
+	res = httptest.NewRecorder()
+
+	req, err = http.NewRequest(http.MethodGet, "/api/configuration/watch?timeout=10s", nil)
+	require.NoError(t, err)
+
+	req.Header.Set("If-None-Match", etag(version))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		api.watchConfiguration(res, req)
+	}()
+
+	// Setting the same configuration must not wake up the watcher.
+	_, err = api.configuration.Set("foo")
+	require.NoError(t, err)
+
+	_, err = api.configuration.Set("bar")
+	require.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "watch request did not return after a configuration change")
+	}
+
+	_, newVersion, _ := api.configuration.Get()
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "\"bar\"\n", res.Body.String())
+	assert.Equal(t, etag(newVersion), res.Header().Get("ETag"))
+
+	// An invalid timeout is rejected.
+	res = httptest.NewRecorder()
+
+	req, err = http.NewRequest(http.MethodGet, "/api/configuration/watch?timeout=foo", nil)
+	require.NoError(t, err)
+
+	api.watchConfiguration(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestConfigurationHistory(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	client := fake.NewSimpleClientset()
+	api, err := NewAPI(log, 9000, localhost, client, "foo", prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	api.SetConfig(&dynamic.Configuration{HTTP: &dynamic.HTTPConfiguration{
+		Routers: map[string]*dynamic.Router{"foo": {Rule: "Host(`foo`)"}},
+	}})
+	api.RecordHistory("ns/foo")
+
+	// Recording the same configuration again must not add a history entry.
+	api.RecordHistory("refresh")
+
+	api.SetConfig(&dynamic.Configuration{HTTP: &dynamic.HTTPConfiguration{
+		Routers: map[string]*dynamic.Router{"bar": {Rule: "Host(`bar`)"}},
+	}})
+	api.RecordHistory("ns/bar")
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/configuration/history", nil)
+
+	api.Handler.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var entries []historyEntry
+
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "ns/bar", entries[0].Trigger)
+	assert.Equal(t, "ns/foo", entries[1].Trigger)
+	assert.Nil(t, entries[0].Configuration)
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/configuration/history/"+entries[1].Version, nil)
+
+	api.Handler.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "Host(`foo`)")
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/configuration/diff", nil)
+
+	api.Handler.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var diff configurationDiff
+
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &diff))
+	assert.Equal(t, entries[1].Version, diff.From)
+	assert.Equal(t, entries[0].Version, diff.To)
+	assert.Contains(t, diff.HTTP.Routers.Removed, "foo")
+	assert.Contains(t, diff.HTTP.Routers.Added, "bar")
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/configuration/diff?from=unknown", nil)
+
+	api.Handler.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestGetMetrics(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "foo_total", Help: "Foo."}))
+
+	client := fake.NewSimpleClientset()
+	api, err := NewAPI(log, 9000, localhost, client, "foo", registry)
+	require.NoError(t, err)
+
+	res := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+
+	api.Handler.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "foo_total 0")
 }
 
 func TestGetMeshNodes(t *testing.T) {
@@ -143,7 +340,7 @@ func TestGetMeshNodes(t *testing.T) {
 			log.SetLevel(logrus.DebugLevel)
 
 			clientMock := k8s.NewClientMock(test.mockFile)
-			api, err := NewAPI(log, 9000, localhost, clientMock.KubernetesClient(), "foo")
+			api, err := NewAPI(log, 9000, localhost, clientMock.KubernetesClient(), "foo", prometheus.NewRegistry())
 
 			require.NoError(t, err)
 
@@ -195,7 +392,7 @@ func TestGetMeshNodeConfiguration(t *testing.T) {
 			log.SetLevel(logrus.DebugLevel)
 
 			clientMock := k8s.NewClientMock(test.mockFile)
-			api, err := NewAPI(log, 9000, localhost, clientMock.KubernetesClient(), "foo")
+			api, err := NewAPI(log, 9000, localhost, clientMock.KubernetesClient(), "foo", prometheus.NewRegistry())
 
 			require.NoError(t, err)
 
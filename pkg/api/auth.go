@@ -0,0 +1,291 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/provider"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	listers "k8s.io/client-go/listers/core/v1"
+)
+
+// authCacheTTL is the duration during which an authentication decision is cached, to avoid sending a TokenReview and
+// a SubjectAccessReview for each request.
+const authCacheTTL = time.Minute
+
+// serviceAccountUsernamePrefix is the prefix of the usernames of the service accounts.
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// podNameExtraKey is the key of the user extra info holding the name of the pod a service account token is bound to.
+const podNameExtraKey = "authentication.kubernetes.io/pod-name"
+
+// unauthenticatedPaths are the paths which can be accessed without authentication, like the readiness endpoint which
+// is used by the kubelet probes, and the identity ACL authorization endpoint, which is called by the proxies ForwardAuth
+// middlewares: these cannot carry the proxies token, and the authorization decision only depends on the request.
+var unauthenticatedPaths = map[string]struct{}{
+	"/api/status/readiness":           {},
+	provider.ACLIdentityAuthorizePath: {},
+}
+
+// authenticator authenticates the API requests with the token they carry, using Kubernetes TokenReviews. The tokens of
+// the service accounts used by the mesh nodes are always allowed, and the other users are authorized with a
+// SubjectAccessReview on the requested path. As the Traefik HTTP provider used by the mesh nodes to fetch the
+// configuration cannot set headers, the token can also be sent as the password of a basic authentication, which the
+// provider builds from the user info of its endpoint URL.
+type authenticator struct {
+	client    kubernetes.Interface
+	podLister listers.PodLister
+	log       logrus.FieldLogger
+
+	mu    sync.Mutex
+	cache map[string]authDecision
+}
+
+type authDecision struct {
+	allowed bool
+	user    authenticationv1.UserInfo
+	expires time.Time
+}
+
+type authenticatedUserKey struct{}
+
+func newAuthenticator(client kubernetes.Interface, podLister listers.PodLister, log logrus.FieldLogger) *authenticator {
+	return &authenticator{
+		client:    client,
+		podLister: podLister,
+		log:       log,
+		cache:     make(map[string]authDecision),
+	}
+}
+
+// Wrap returns a handler authenticating the requests before passing them to the given handler.
+func (a *authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := unauthenticatedPaths[r.URL.Path]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := requestToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "", http.StatusUnauthorized)
+
+			return
+		}
+
+		allowed, user, err := a.authorize(r, token)
+		if err != nil {
+			a.log.Errorf("Unable to authenticate request: %v", err)
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		if !allowed {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authenticatedUserKey{}, user)))
+	})
+}
+
+// authenticatedUser returns the user the given request has been authenticated as, if any.
+func authenticatedUser(r *http.Request) (authenticationv1.UserInfo, bool) {
+	user, ok := r.Context().Value(authenticatedUserKey{}).(authenticationv1.UserInfo)
+
+	return user, ok
+}
+
+// authorize returns true if the user owning the given token is allowed to access the requested path, along with this
+// user.
+func (a *authenticator) authorize(r *http.Request, token string) (bool, authenticationv1.UserInfo, error) {
+	hash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(hash[:]) + r.URL.Path
+
+	a.mu.Lock()
+	decision, ok := a.cache[key]
+	a.mu.Unlock()
+
+	if ok && time.Now().Before(decision.expires) {
+		return decision.allowed, decision.user, nil
+	}
+
+	allowed, user, err := a.review(r, token)
+	if err != nil {
+		return false, authenticationv1.UserInfo{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Drop the expired decisions, so the cache doesn't grow with tokens which are not used anymore.
+	now := time.Now()
+	for k, d := range a.cache {
+		if now.After(d.expires) {
+			delete(a.cache, k)
+		}
+	}
+
+	a.cache[key] = authDecision{allowed: allowed, user: user, expires: now.Add(authCacheTTL)}
+
+	return allowed, user, nil
+}
+
+// review authenticates the given token with a TokenReview and authorizes its user.
+func (a *authenticator) review(r *http.Request, token string) (bool, authenticationv1.UserInfo, error) {
+	tokenReview, err := a.client.AuthenticationV1().TokenReviews().Create(r.Context(), &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, authenticationv1.UserInfo{}, fmt.Errorf("unable to review token: %w", err)
+	}
+
+	if !tokenReview.Status.Authenticated {
+		a.log.Debugf("Rejecting unauthenticated API request to %q: %s", r.URL.Path, tokenReview.Status.Error)
+		return false, authenticationv1.UserInfo{}, nil
+	}
+
+	user := tokenReview.Status.User
+
+	isMeshNode, err := a.isMeshNodeServiceAccount(user.Username)
+	if err != nil {
+		return false, user, err
+	}
+
+	if isMeshNode {
+		return true, user, nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	sar, err := a.client.AuthorizationV1().SubjectAccessReviews().Create(r.Context(), &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: r.URL.Path,
+				Verb: "get",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, user, fmt.Errorf("unable to review access of user %q: %w", user.Username, err)
+	}
+
+	if !sar.Status.Allowed {
+		a.log.Debugf("Rejecting API request to %q from user %q: %s", r.URL.Path, user.Username, sar.Status.Reason)
+	}
+
+	return sar.Status.Allowed, user, nil
+}
+
+// isMeshNodeServiceAccount returns true if the given username is the one of a service account used by a mesh node.
+func (a *authenticator) isMeshNodeServiceAccount(username string) (bool, error) {
+	if !strings.HasPrefix(username, serviceAccountUsernamePrefix) {
+		return false, nil
+	}
+
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(username, serviceAccountUsernamePrefix), ":")
+	if !ok {
+		return false, nil
+	}
+
+	pods, err := a.podLister.Pods(namespace).List(labels.Everything())
+	if err != nil {
+		return false, fmt.Errorf("unable to list mesh nodes: %w", err)
+	}
+
+	for _, pod := range pods {
+		if pod.Spec.ServiceAccountName == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isAuthenticatedAsNode returns true if the given request has been authenticated with a token of the service account of
+// the given mesh node. When the token is bound to a pod, it must be bound to the mesh node itself.
+func isAuthenticatedAsNode(r *http.Request, pod *corev1.Pod) bool {
+	user, ok := authenticatedUser(r)
+	if !ok {
+		return false
+	}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+
+	if user.Username != serviceAccountUsernamePrefix+pod.Namespace+":"+serviceAccount {
+		return false
+	}
+
+	if podNames, bound := user.Extra[podNameExtraKey]; bound {
+		return len(podNames) == 1 && podNames[0] == pod.Name
+	}
+
+	return true
+}
+
+// findMeshNode returns the mesh node having the IP of the given remote address, or nil if there is none.
+func findMeshNode(podLister listers.PodLister, remoteAddr string, log logrus.FieldLogger) *corev1.Pod {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return nil
+	}
+
+	pods, err := podLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("Unable to list mesh nodes: %v", err)
+		return nil
+	}
+
+	for _, pod := range pods {
+		for _, ip := range k8s.PodIPs(pod) {
+			if ip == host {
+				return pod
+			}
+		}
+	}
+
+	return nil
+}
+
+// requestToken returns the token of the given request, sent either as a bearer token or as the password of a basic
+// authentication.
+func requestToken(r *http.Request) (string, bool) {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password, password != ""
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
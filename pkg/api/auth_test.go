@@ -0,0 +1,224 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	listers "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestAuthenticator_Wrap(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		path               string
+		remoteAddr         string
+		token              string
+		basicAuth          bool
+		expectedStatusCode int
+		expectedReviews    int
+	}{
+		{
+			desc:               "readiness endpoint without token",
+			path:               "/api/status/readiness",
+			remoteAddr:         "10.0.0.1:1234",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "identity ACL authorization endpoint with an application token",
+			path:               "/api/acl/authorize",
+			remoteAddr:         "10.0.0.1:1234",
+			token:              "invalid",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "request without token from a mesh node",
+			path:               "/api/configuration/current",
+			remoteAddr:         "10.10.10.10:1234",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "request without token",
+			path:               "/api/configuration/current",
+			remoteAddr:         "10.0.0.1:1234",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "invalid token",
+			path:               "/api/configuration/current",
+			remoteAddr:         "10.0.0.1:1234",
+			token:              "invalid",
+			expectedStatusCode: http.StatusForbidden,
+			expectedReviews:    1,
+		},
+		{
+			desc:               "token of the mesh nodes service account",
+			path:               "/api/configuration/current",
+			remoteAddr:         "10.0.0.1:1234",
+			token:              "proxy",
+			expectedStatusCode: http.StatusOK,
+			expectedReviews:    1,
+		},
+		{
+			desc:               "token of the mesh nodes service account sent with a basic authentication",
+			path:               "/api/configuration/current",
+			remoteAddr:         "10.10.10.10:1234",
+			token:              "proxy",
+			basicAuth:          true,
+			expectedStatusCode: http.StatusOK,
+			expectedReviews:    1,
+		},
+		{
+			desc:               "token of an authorized user",
+			path:               "/api/topology/current",
+			remoteAddr:         "10.0.0.1:1234",
+			token:              "admin",
+			expectedStatusCode: http.StatusOK,
+			expectedReviews:    2,
+		},
+		{
+			desc:               "token of an unauthorized user",
+			path:               "/api/topology/current",
+			remoteAddr:         "10.0.0.1:1234",
+			token:              "user",
+			expectedStatusCode: http.StatusForbidden,
+			expectedReviews:    2,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+
+				switch review.Spec.Token {
+				case "proxy":
+					review.Status.Authenticated = true
+					review.Status.User.Username = "system:serviceaccount:traefik-mesh:traefik-mesh-proxy"
+				case "admin":
+					review.Status.Authenticated = true
+					review.Status.User.Username = "admin"
+				case "user":
+					review.Status.Authenticated = true
+					review.Status.User.Username = "user"
+				}
+
+				return true, review, nil
+			})
+			client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+
+				review.Status.Allowed = review.Spec.User == "admin" && review.Spec.NonResourceAttributes.Path == "/api/topology/current"
+
+				return true, review, nil
+			})
+
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			require.NoError(t, indexer.Add(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "mesh-pod-1", Namespace: "traefik-mesh"},
+				Spec:       corev1.PodSpec{ServiceAccountName: "traefik-mesh-proxy"},
+				Status:     corev1.PodStatus{PodIP: "10.10.10.10"},
+			}))
+
+			auth := newAuthenticator(client, listers.NewPodLister(indexer), logrus.New())
+			handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			// Send the request twice, the second one must be served from the cache.
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(http.MethodGet, test.path, nil)
+				req.RemoteAddr = test.remoteAddr
+
+				switch {
+				case test.basicAuth:
+					req.SetBasicAuth("traefik-mesh-proxy", test.token)
+				case test.token != "":
+					req.Header.Set("Authorization", "Bearer "+test.token)
+				}
+
+				res := httptest.NewRecorder()
+				handler.ServeHTTP(res, req)
+
+				assert.Equal(t, test.expectedStatusCode, res.Code)
+			}
+
+			assert.Len(t, client.Actions(), test.expectedReviews)
+		})
+	}
+}
+
+func TestRequestToken(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		authorization string
+		expectedToken string
+		expectedOk    bool
+	}{
+		{
+			desc: "no authorization header",
+		},
+		{
+			desc:          "basic authorization",
+			authorization: "Basic Zm9vOmJhcg==",
+			expectedToken: "bar",
+			expectedOk:    true,
+		},
+		{
+			desc:          "basic authorization without password",
+			authorization: "Basic Zm9vOg==",
+		},
+		{
+			desc:          "unknown scheme",
+			authorization: "Digest foo",
+		},
+		{
+			desc:          "empty bearer token",
+			authorization: "Bearer ",
+		},
+		{
+			desc:          "bearer token",
+			authorization: "Bearer foo",
+			expectedToken: "foo",
+			expectedOk:    true,
+		},
+		{
+			desc:          "lower case scheme",
+			authorization: "bearer foo",
+			expectedToken: "foo",
+			expectedOk:    true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			token, ok := requestToken(req)
+
+			assert.Equal(t, test.expectedOk, ok)
+			assert.Equal(t, test.expectedToken, token)
+		})
+	}
+}
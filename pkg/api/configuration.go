@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// configurationStore holds the current configuration, serialized in JSON, along with its version. The version is
+// derived from the hash of the serialized configuration, therefore setting an identical configuration doesn't
+// change the version.
+type configurationStore struct {
+	mu      sync.RWMutex
+	data    []byte
+	version string
+	// changed is closed when the configuration changes.
+	changed chan struct{}
+}
+
+// newConfigurationStore creates and returns a new configurationStore holding the given configuration.
+func newConfigurationStore(cfg interface{}) (*configurationStore, error) {
+	store := &configurationStore{changed: make(chan struct{})}
+
+	if _, err := store.Set(cfg); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Set sets the current configuration. It returns true if the configuration version has changed.
+func (s *configurationStore) Set(cfg interface{}) (bool, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return false, fmt.Errorf("unable to serialize configuration: %w", err)
+	}
+
+	// End the document with a newline, like a json.Encoder would do.
+	data = append(data, '\n')
+
+	hash := sha256.Sum256(data)
+	version := hex.EncodeToString(hash[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if version == s.version {
+		return false, nil
+	}
+
+	s.data = data
+	s.version = version
+
+	// Wake up the watchers and prepare the channel for the next change.
+	close(s.changed)
+	s.changed = make(chan struct{})
+
+	return true, nil
+}
+
+// Get returns the current configuration, its version and a channel which is closed when the configuration changes.
+func (s *configurationStore) Get() (data []byte, version string, changed <-chan struct{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data, s.version, s.changed
+}
+
+// etag returns the ETag header value for the given configuration version.
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+// matchesETag returns true if the given If-None-Match header value matches the given ETag.
+func matchesETag(ifNoneMatch, tag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
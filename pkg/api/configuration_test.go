@@ -0,0 +1,83 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigurationStore_Set(t *testing.T) {
+	store, err := newConfigurationStore("foo")
+	require.NoError(t, err)
+
+	data, version, changed := store.Get()
+	assert.Equal(t, "\"foo\"\n", string(data))
+	assert.NotEmpty(t, version)
+
+	ok, err := store.Set("foo")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	select {
+	case <-changed:
+		assert.Fail(t, "changed channel closed while the configuration is the same")
+	default:
+	}
+
+	ok, err = store.Set("bar")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	select {
+	case <-changed:
+	default:
+		assert.Fail(t, "changed channel not closed while the configuration has changed")
+	}
+
+	data, newVersion, _ := store.Get()
+	assert.Equal(t, "\"bar\"\n", string(data))
+	assert.NotEqual(t, version, newVersion)
+}
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		desc        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{
+			desc:        "same ETag",
+			ifNoneMatch: `"foo"`,
+			want:        true,
+		},
+		{
+			desc:        "weak ETag",
+			ifNoneMatch: `W/"foo"`,
+			want:        true,
+		},
+		{
+			desc:        "list of ETags",
+			ifNoneMatch: `"bar", "foo"`,
+			want:        true,
+		},
+		{
+			desc:        "wildcard",
+			ifNoneMatch: "*",
+			want:        true,
+		},
+		{
+			desc:        "different ETag",
+			ifNoneMatch: `"bar"`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, matchesETag(test.ifNoneMatch, `"foo"`))
+		})
+	}
+}
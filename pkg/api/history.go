@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/traefik/mesh/pkg/topology"
+)
+
+// historySize is the number of configurations kept in the configuration history.
+const historySize = 10
+
+// historyEntry is a configuration and the topology it has been built from, recorded in the configuration history.
+type historyEntry struct {
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	// Trigger is the work queue key which triggered the configuration build.
+	Trigger string `json:"trigger"`
+
+	Configuration json.RawMessage    `json:"configuration,omitempty"`
+	Topology      *topology.Topology `json:"topology,omitempty"`
+}
+
+// history is a bounded ring buffer holding the last configurations.
+type history struct {
+	mu      sync.RWMutex
+	entries []historyEntry
+	next    int
+	size    int
+}
+
+// newHistory creates and returns a new history holding at most the given number of entries.
+func newHistory(capacity int) *history {
+	return &history{entries: make([]historyEntry, capacity)}
+}
+
+// Add adds the given entry to the history, replacing the oldest entry if the history is full. The entry is not added
+// if its version is the version of the latest entry. It returns true if the entry has been added.
+func (h *history) Add(entry historyEntry) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if latest, ok := h.latest(); ok && latest.Version == entry.Version {
+		return false
+	}
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+
+	if h.size < len(h.entries) {
+		h.size++
+	}
+
+	return true
+}
+
+// List returns the entries of the history, from the newest to the oldest.
+func (h *history) List() []historyEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	entries := make([]historyEntry, 0, h.size)
+
+	for i := 1; i <= h.size; i++ {
+		entries = append(entries, h.entries[(h.next-i+len(h.entries))%len(h.entries)])
+	}
+
+	return entries
+}
+
+// Get returns the entry with the given version.
+func (h *history) Get(version string) (historyEntry, bool) {
+	for _, entry := range h.List() {
+		if entry.Version == version {
+			return entry, true
+		}
+	}
+
+	return historyEntry{}, false
+}
+
+// Previous returns the entry preceding the entry with the given version.
+func (h *history) Previous(version string) (historyEntry, bool) {
+	entries := h.List()
+
+	for i, entry := range entries {
+		if entry.Version == version && i+1 < len(entries) {
+			return entries[i+1], true
+		}
+	}
+
+	return historyEntry{}, false
+}
+
+func (h *history) latest() (historyEntry, bool) {
+	if h.size == 0 {
+		return historyEntry{}, false
+	}
+
+	return h.entries[(h.next-1+len(h.entries))%len(h.entries)], true
+}
+
+// configurationDiff is the difference between two configurations.
+type configurationDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	HTTP sectionDiff `json:"http"`
+	TCP  sectionDiff `json:"tcp"`
+	UDP  sectionDiff `json:"udp"`
+}
+
+// sectionDiff is the difference between two sections (HTTP, TCP or UDP) of a configuration.
+type sectionDiff struct {
+	Routers     objectsDiff `json:"routers"`
+	Services    objectsDiff `json:"services"`
+	Middlewares objectsDiff `json:"middlewares"`
+}
+
+// objectsDiff is the difference between two sets of named objects.
+type objectsDiff struct {
+	Added   map[string]json.RawMessage `json:"added,omitempty"`
+	Removed map[string]json.RawMessage `json:"removed,omitempty"`
+	Changed map[string]objectChange    `json:"changed,omitempty"`
+}
+
+// objectChange holds the two versions of a changed object.
+type objectChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// rawConfiguration is a configuration which objects are kept serialized, to compare them.
+type rawConfiguration struct {
+	HTTP rawSection `json:"http"`
+	TCP  rawSection `json:"tcp"`
+	UDP  rawSection `json:"udp"`
+}
+
+type rawSection struct {
+	Routers     map[string]json.RawMessage `json:"routers"`
+	Services    map[string]json.RawMessage `json:"services"`
+	Middlewares map[string]json.RawMessage `json:"middlewares"`
+}
+
+// diffConfigurations returns the difference between the configurations of the given entries.
+func diffConfigurations(from, to historyEntry) (*configurationDiff, error) {
+	var fromCfg, toCfg rawConfiguration
+
+	if err := json.Unmarshal(from.Configuration, &fromCfg); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration %q: %w", from.Version, err)
+	}
+
+	if err := json.Unmarshal(to.Configuration, &toCfg); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration %q: %w", to.Version, err)
+	}
+
+	return diffRawConfigurations(from.Version, to.Version, fromCfg, toCfg), nil
+}
+
+// diffRawConfigurations returns the difference between the given configurations.
+func diffRawConfigurations(fromVersion, toVersion string, from, to rawConfiguration) *configurationDiff {
+	return &configurationDiff{
+		From: fromVersion,
+		To:   toVersion,
+		HTTP: diffSections(from.HTTP, to.HTTP),
+		TCP:  diffSections(from.TCP, to.TCP),
+		UDP:  diffSections(from.UDP, to.UDP),
+	}
+}
+
+// Empty returns true if the configurations are identical.
+func (d *configurationDiff) Empty() bool {
+	return d.HTTP.empty() && d.TCP.empty() && d.UDP.empty()
+}
+
+func diffSections(from, to rawSection) sectionDiff {
+	return sectionDiff{
+		Routers:     diffObjects(from.Routers, to.Routers),
+		Services:    diffObjects(from.Services, to.Services),
+		Middlewares: diffObjects(from.Middlewares, to.Middlewares),
+	}
+}
+
+func (d sectionDiff) empty() bool {
+	return d.Routers.empty() && d.Services.empty() && d.Middlewares.empty()
+}
+
+func (d objectsDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func diffObjects(from, to map[string]json.RawMessage) objectsDiff {
+	var diff objectsDiff
+
+	for name, fromObject := range from {
+		toObject, ok := to[name]
+		if !ok {
+			if diff.Removed == nil {
+				diff.Removed = make(map[string]json.RawMessage)
+			}
+
+			diff.Removed[name] = fromObject
+
+			continue
+		}
+
+		// Objects are serialized by the controller, which always produces the same output for the same object.
+		if !bytes.Equal(fromObject, toObject) {
+			if diff.Changed == nil {
+				diff.Changed = make(map[string]objectChange)
+			}
+
+			diff.Changed[name] = objectChange{From: fromObject, To: toObject}
+		}
+	}
+
+	for name, toObject := range to {
+		if _, ok := from[name]; ok {
+			continue
+		}
+
+		if diff.Added == nil {
+			diff.Added = make(map[string]json.RawMessage)
+		}
+
+		diff.Added[name] = toObject
+	}
+
+	return diff
+}
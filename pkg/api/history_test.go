@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory_Add(t *testing.T) {
+	h := newHistory(3)
+
+	assert.Empty(t, h.List())
+
+	for i := 1; i <= 4; i++ {
+		assert.True(t, h.Add(historyEntry{Version: fmt.Sprintf("v%d", i)}))
+	}
+
+	// The same configuration must not be recorded twice in a row.
+	assert.False(t, h.Add(historyEntry{Version: "v4"}))
+
+	var versions []string
+	for _, entry := range h.List() {
+		versions = append(versions, entry.Version)
+	}
+
+	assert.Equal(t, []string{"v4", "v3", "v2"}, versions)
+
+	_, ok := h.Get("v1")
+	assert.False(t, ok)
+
+	entry, ok := h.Get("v3")
+	require.True(t, ok)
+	assert.Equal(t, "v3", entry.Version)
+
+	entry, ok = h.Previous("v3")
+	require.True(t, ok)
+	assert.Equal(t, "v2", entry.Version)
+
+	_, ok = h.Previous("v2")
+	assert.False(t, ok)
+}
+
+func TestDiffConfigurations(t *testing.T) {
+	from := historyEntry{
+		Version: "v1",
+		Configuration: json.RawMessage(`{
+			"http": {
+				"routers": {"removed": {"rule": "Host(` + "`a`" + `)"}, "changed": {"rule": "Host(` + "`b`" + `)"}, "same": {"rule": "Host(` + "`c`" + `)"}},
+				"services": {"svc": {"loadBalancer": {}}}
+			}
+		}`),
+	}
+	to := historyEntry{
+		Version: "v2",
+		Configuration: json.RawMessage(`{
+			"http": {
+				"routers": {"changed": {"rule": "Host(` + "`d`" + `)"}, "same": {"rule": "Host(` + "`c`" + `)"}},
+				"services": {"svc": {"loadBalancer": {}}},
+				"middlewares": {"added": {"retry": {"attempts": 2}}}
+			},
+			"tcp": {
+				"routers": {"added": {"rule": "HostSNI(` + "`*`" + `)"}}
+			}
+		}`),
+	}
+
+	diff, err := diffConfigurations(from, to)
+	require.NoError(t, err)
+
+	got, err := json.Marshal(diff)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"from": "v1",
+		"to": "v2",
+		"http": {
+			"routers": {
+				"removed": {"removed": {"rule": "Host(`+"`a`"+`)"}},
+				"changed": {"changed": {"from": {"rule": "Host(`+"`b`"+`)"}, "to": {"rule": "Host(`+"`d`"+`)"}}}
+			},
+			"services": {},
+			"middlewares": {
+				"added": {"added": {"retry": {"attempts": 2}}}
+			}
+		},
+		"tcp": {
+			"routers": {
+				"added": {"added": {"rule": "HostSNI(`+"`*`"+`)"}}
+			},
+			"services": {},
+			"middlewares": {}
+		},
+		"udp": {
+			"routers": {},
+			"services": {},
+			"middlewares": {}
+		}
+	}`, string(got))
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/traefik/mesh/pkg/mtls"
+	"github.com/traefik/mesh/pkg/topology"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	traefiktls "github.com/traefik/traefik/v2/pkg/tls"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// certificatesStatus is the status of the mesh CA and of the certificates issued to the mesh nodes.
+type certificatesStatus struct {
+	CA    mtls.CertificateInfo         `json:"ca"`
+	Nodes []mtls.NodeCertificateStatus `json:"nodes"`
+}
+
+// nodeConfigurations caches the configurations served to the mesh nodes, which carry their own certificate.
+type nodeConfigurations struct {
+	mu      sync.Mutex
+	configs map[types.UID]nodeConfiguration
+}
+
+type nodeConfiguration struct {
+	// version is the version of the configuration the node configuration has been built from.
+	version string
+	// serialNumber is the serial number of the node certificate.
+	serialNumber string
+
+	data        []byte
+	nodeVersion string
+}
+
+// nodeConfiguration returns the given configuration, completed with the certificate of the mesh node which sent the
+// request when mTLS is enabled, along with its version. As the certificate comes with its private key, it is only sent
+// to requests authenticated with the service account of the mesh node.
+func (a *API) nodeConfiguration(r *http.Request, data []byte, version string) ([]byte, string) {
+	if a.certificates == nil {
+		return data, version
+	}
+
+	pod := findMeshNode(a.podLister, r.RemoteAddr, a.log)
+	if pod == nil {
+		return data, version
+	}
+
+	if !isAuthenticatedAsNode(r, pod) {
+		a.log.Warnf("Not sending the certificate of mesh node %q to a request which is not authenticated with its service account", pod.Name)
+		return data, version
+	}
+
+	cert, err := a.certificates.Get(pod, a.meshNamespaces())
+	if err != nil {
+		a.log.Errorf("Unable to get certificate of mesh node %q: %v", pod.Name, err)
+		return data, version
+	}
+
+	a.nodeConfigs.mu.Lock()
+	defer a.nodeConfigs.mu.Unlock()
+
+	cached, ok := a.nodeConfigs.configs[pod.UID]
+	if ok && cached.version == version && cached.serialNumber == cert.Info.SerialNumber {
+		return cached.data, cached.nodeVersion
+	}
+
+	if ok && cached.serialNumber != cert.Info.SerialNumber {
+		a.log.Infof("Certificate of mesh node %q rotated, new serial number: %s", pod.Name, cert.Info.SerialNumber)
+	}
+
+	nodeData, err := withNodeCertificate(data, cert)
+	if err != nil {
+		a.log.Errorf("Unable to add certificate to the configuration of mesh node %q: %v", pod.Name, err)
+		return data, version
+	}
+
+	if nodeData == nil {
+		// The configuration has neither servers transports nor TLS options.
+		return data, version
+	}
+
+	hash := sha256.Sum256([]byte(version + cert.Info.SerialNumber))
+	nodeVersion := hex.EncodeToString(hash[:])
+
+	a.pruneNodeConfigurations()
+
+	a.nodeConfigs.configs[pod.UID] = nodeConfiguration{
+		version:      version,
+		serialNumber: cert.Info.SerialNumber,
+		data:         nodeData,
+		nodeVersion:  nodeVersion,
+	}
+
+	return nodeData, nodeVersion
+}
+
+// meshNamespaces returns the sorted namespaces of the services of the current topology.
+func (a *API) meshNamespaces() []string {
+	topo, ok := a.topology.Get().(*topology.Topology)
+	if !ok || topo == nil {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	namespaces := make([]string, 0)
+
+	for key := range topo.Services {
+		if _, ok := seen[key.Namespace]; ok {
+			continue
+		}
+
+		seen[key.Namespace] = struct{}{}
+		namespaces = append(namespaces, key.Namespace)
+	}
+
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// withNodeCertificate returns the given serialized configuration, with the given certificate set as client
+// certificate of all its servers transports, and as default certificate when it has TLS options. It returns nil if the
+// configuration has neither servers transports nor TLS options.
+func withNodeCertificate(data []byte, cert *mtls.Certificate) ([]byte, error) {
+	var cfg dynamic.Configuration
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration: %w", err)
+	}
+
+	hasTransports := cfg.HTTP != nil && len(cfg.HTTP.ServersTransports) > 0
+	hasTLSOptions := cfg.TLS != nil && len(cfg.TLS.Options) > 0
+
+	if !hasTransports && !hasTLSOptions {
+		return nil, nil
+	}
+
+	nodeCert := traefiktls.Certificate{
+		CertFile: traefiktls.FileOrContent(cert.CertPEM),
+		KeyFile:  traefiktls.FileOrContent(cert.KeyPEM),
+	}
+
+	if hasTransports {
+		for _, transport := range cfg.HTTP.ServersTransports {
+			transport.Certificates = traefiktls.Certificates{nodeCert}
+		}
+	}
+
+	// The routers requiring a client certificate, in identity ACL mode, are served with the node certificate.
+	if hasTLSOptions {
+		cfg.TLS.Stores = map[string]traefiktls.Store{
+			"default": {DefaultCertificate: &nodeCert},
+		}
+	}
+
+	nodeData, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize configuration: %w", err)
+	}
+
+	return append(nodeData, '\n'), nil
+}
+
+// getCertificates returns the status of the mesh CA and of the certificates issued to the mesh nodes.
+func (a *API) getCertificates(w http.ResponseWriter, _ *http.Request) {
+	if a.certificates == nil {
+		http.Error(w, "mTLS is not enabled", http.StatusNotFound)
+		return
+	}
+
+	status := certificatesStatus{
+		CA:    a.certificates.CA(),
+		Nodes: a.certificates.Status(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		a.log.Errorf("Unable to serialize certificates status: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// pruneNodeConfigurations drops the cached configurations of the mesh nodes which are gone. It must be called with the
+// node configurations lock held.
+func (a *API) pruneNodeConfigurations() {
+	pods, err := a.podLister.List(labels.Everything())
+	if err != nil {
+		a.log.Errorf("Unable to list mesh nodes: %v", err)
+		return
+	}
+
+	uids := make(map[types.UID]struct{}, len(pods))
+	for _, pod := range pods {
+		uids[pod.UID] = struct{}{}
+	}
+
+	for uid := range a.nodeConfigs.configs {
+		if _, ok := uids[uid]; !ok {
+			delete(a.nodeConfigs.configs, uid)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/mtls"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	traefiktls "github.com/traefik/traefik/v2/pkg/tls"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestMutualTLS(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	ca, err := mtls.NewCertificateAuthority()
+	require.NoError(t, err)
+
+	issuer := mtls.NewNodeCertificateIssuer(ca, time.Hour, "cluster.local", []string{"traefik.mesh"})
+
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mesh-pod-1",
+			Namespace: "foo",
+			UID:       "uid-1",
+			Labels:    map[string]string{"component": "maesh-mesh"},
+		},
+		Spec:   corev1.PodSpec{ServiceAccountName: "traefik-mesh-proxy"},
+		Status: corev1.PodStatus{PodIP: "10.10.10.10"},
+	})
+
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		review.Status.Authenticated = true
+
+		switch review.Spec.Token {
+		case "proxy":
+			review.Status.User.Username = "system:serviceaccount:foo:traefik-mesh-proxy"
+		case "proxy-bound-to-other-pod":
+			review.Status.User.Username = "system:serviceaccount:foo:traefik-mesh-proxy"
+			review.Status.User.Extra = map[string]authenticationv1.ExtraValue{podNameExtraKey: {"mesh-pod-2"}}
+		default:
+			review.Status.User.Username = review.Spec.Token
+		}
+
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		review.Status.Allowed = true
+
+		return true, review, nil
+	})
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	writeCertificate(t, certFile, keyFile, "traefik-mesh-controller", time.Now())
+
+	api, err := NewAPI(log, 9000, localhost, client, "foo", prometheus.NewRegistry(), TLSCertificate(certFile, keyFile), TokenAuthentication(), MutualTLS(issuer))
+	require.NoError(t, err)
+
+	defer func() { _ = api.Shutdown(context.Background()) }()
+
+	api.SetConfig(&dynamic.Configuration{HTTP: &dynamic.HTTPConfiguration{
+		ServersTransports: map[string]*dynamic.ServersTransport{
+			"my-ns-svc-a-mtls": {
+				ServerName: "svc-a.my-ns.svc",
+				RootCAs:    []traefiktls.FileOrContent{traefiktls.FileOrContent(ca.CertificatePEM())},
+			},
+		},
+	}})
+
+	_, version, _ := api.configuration.Get()
+
+	getConfiguration := func(remoteAddr, token string) (*dynamic.Configuration, string) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/configuration/current", nil)
+		req.RemoteAddr = remoteAddr
+		req.SetBasicAuth("traefik-mesh-proxy", token)
+
+		api.Handler.ServeHTTP(res, req)
+
+		require.Equal(t, http.StatusOK, res.Code)
+
+		var cfg dynamic.Configuration
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &cfg))
+
+		return &cfg, res.Header().Get("ETag")
+	}
+
+	// Other clients get the configuration without certificate.
+	cfg, tag := getConfiguration("10.0.0.1:1234", "proxy")
+	assert.Equal(t, etag(version), tag)
+	assert.Empty(t, cfg.HTTP.ServersTransports["my-ns-svc-a-mtls"].Certificates)
+
+	// Requests coming from a mesh node but not authenticated with its service account get no certificate either.
+	cfg, tag = getConfiguration("10.10.10.10:1234", "admin")
+	assert.Equal(t, etag(version), tag)
+	assert.Empty(t, cfg.HTTP.ServersTransports["my-ns-svc-a-mtls"].Certificates)
+
+	cfg, tag = getConfiguration("10.10.10.10:1234", "proxy-bound-to-other-pod")
+	assert.Equal(t, etag(version), tag)
+	assert.Empty(t, cfg.HTTP.ServersTransports["my-ns-svc-a-mtls"].Certificates)
+
+	// Mesh nodes get the configuration with their certificate.
+	cfg, tag = getConfiguration("10.10.10.10:1234", "proxy")
+	assert.NotEqual(t, etag(version), tag)
+
+	certs := cfg.HTTP.ServersTransports["my-ns-svc-a-mtls"].Certificates
+	require.Len(t, certs, 1)
+
+	statuses := issuer.Status()
+	require.Len(t, statuses, 1)
+
+	nodeCert, err := issuer.Get(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-pod-1", Namespace: "foo", UID: "uid-1"},
+		Status:     corev1.PodStatus{PodIP: "10.10.10.10"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, string(nodeCert.CertPEM), certs[0].CertFile.String())
+	assert.Equal(t, string(nodeCert.KeyPEM), certs[0].KeyFile.String())
+
+	// The node configuration must be stable while the certificate is not renewed.
+	_, nodeTag := getConfiguration("10.10.10.10:1234", "proxy")
+	assert.Equal(t, tag, nodeTag)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/status/certificates", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+
+	api.Handler.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var status certificatesStatus
+
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &status))
+	assert.Equal(t, ca.Info().SerialNumber, status.CA.SerialNumber)
+	require.Len(t, status.Nodes, 1)
+	assert.Equal(t, "mesh-pod-1", status.Nodes[0].Node)
+	assert.Equal(t, statuses[0].Certificate.SerialNumber, status.Nodes[0].Certificate.SerialNumber)
+}
+
+func TestNewAPI_mutualTLSRequirements(t *testing.T) {
+	ca, err := mtls.NewCertificateAuthority()
+	require.NoError(t, err)
+
+	issuer := mtls.NewNodeCertificateIssuer(ca, time.Hour, "cluster.local", []string{"traefik.mesh"})
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	writeCertificate(t, certFile, keyFile, "traefik-mesh-controller", time.Now())
+
+	_, err = NewAPI(logrus.New(), 9000, localhost, fake.NewSimpleClientset(), "foo", prometheus.NewRegistry(), MutualTLS(issuer))
+	assert.Error(t, err)
+
+	_, err = NewAPI(logrus.New(), 9000, localhost, fake.NewSimpleClientset(), "foo", prometheus.NewRegistry(), TokenAuthentication(), MutualTLS(issuer))
+	assert.Error(t, err)
+
+	_, err = NewAPI(logrus.New(), 9000, localhost, fake.NewSimpleClientset(), "foo", prometheus.NewRegistry(), TLSCertificate(certFile, keyFile), MutualTLS(issuer))
+	assert.Error(t, err)
+}
+
+func TestWithNodeCertificate(t *testing.T) {
+	ca, err := mtls.NewCertificateAuthority()
+	require.NoError(t, err)
+
+	cert, err := ca.Issue("mesh-pod-1", nil, nil, nil, time.Hour)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{},
+		TLS: &dynamic.TLSConfiguration{
+			Options: map[string]traefiktls.Options{
+				"traefik-mesh-acl-identity": {MinVersion: "VersionTLS12"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	nodeData, err := withNodeCertificate(data, cert)
+	require.NoError(t, err)
+
+	var cfg dynamic.Configuration
+	require.NoError(t, json.Unmarshal(nodeData, &cfg))
+
+	// Routers requiring a client certificate are served with the node certificate.
+	require.NotNil(t, cfg.TLS.Stores["default"].DefaultCertificate)
+	assert.Equal(t, string(cert.CertPEM), cfg.TLS.Stores["default"].DefaultCertificate.CertFile.String())
+	assert.Equal(t, string(cert.KeyPEM), cfg.TLS.Stores["default"].DefaultCertificate.KeyFile.String())
+
+	// Configurations without servers transports nor TLS options are left untouched.
+	data, err = json.Marshal(&dynamic.Configuration{HTTP: &dynamic.HTTPConfiguration{}})
+	require.NoError(t, err)
+
+	nodeData, err = withNodeCertificate(data, cert)
+	require.NoError(t, err)
+	assert.Nil(t, nodeData)
+}
+
+func TestGetCertificates_mTLSDisabled(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+
+	api, err := NewAPI(log, 9000, localhost, fake.NewSimpleClientset(), "foo", prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/status/certificates", nil)
+
+	api.Handler.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
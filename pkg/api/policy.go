@@ -0,0 +1,376 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
+	"github.com/traefik/mesh/pkg/annotations"
+	"github.com/traefik/mesh/pkg/topology"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	policySourcePod            = "pod"
+	policySourceServiceAccount = "serviceAccount"
+)
+
+// policyRequest is a request evaluated by the policy check endpoint.
+type policyRequest struct {
+	port   int32
+	path   string
+	method string
+	// trafficType is the traffic type of the destination service. The path and method are ignored for TCP services.
+	trafficType string
+}
+
+// policySource is the source of a request evaluated by the policy check endpoint.
+type policySource struct {
+	Kind           string `json:"kind"`
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace"`
+	ServiceAccount string `json:"serviceAccount"`
+
+	pods []topology.Key
+}
+
+// policyDecision is the result of a policy check.
+type policyDecision struct {
+	// ACL is false if ACL mode is disabled, in which case all the requests are allowed.
+	ACL         bool         `json:"acl"`
+	Allowed     bool         `json:"allowed"`
+	Reason      string       `json:"reason"`
+	Source      policySource `json:"source"`
+	Destination topology.Key `json:"destination"`
+
+	// TrafficSplit is the TrafficSplit through which the destination is accessed, if any.
+	TrafficSplit *topology.Key `json:"trafficSplit,omitempty"`
+	// TrafficTarget is the TrafficTarget allowing the request, if any.
+	TrafficTarget *topology.Key `json:"trafficTarget,omitempty"`
+	// Rule is the rule matching the request.
+	Rule *policyRule `json:"rule,omitempty"`
+}
+
+// policyRule is a TrafficTarget or TrafficSplit rule matching a request.
+type policyRule struct {
+	HTTPRouteGroup *topology.Key    `json:"httpRouteGroup,omitempty"`
+	Match          *specs.HTTPMatch `json:"match,omitempty"`
+	TCPRoute       *topology.Key    `json:"tcpRoute,omitempty"`
+}
+
+// checkPolicy evaluates, against the current topology, if the given source can send the given request to the given
+// destination service, as enforced in ACL mode. Sources are given as <name>@<namespace>, and are pods if such a pod
+// exists, service accounts otherwise.
+func (a *API) checkPolicy(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var sourceKey, destinationKey topology.Key
+
+	if err := sourceKey.UnmarshalText([]byte(query.Get("source"))); err != nil {
+		http.Error(w, fmt.Sprintf("invalid source %q", query.Get("source")), http.StatusBadRequest)
+		return
+	}
+
+	if err := destinationKey.UnmarshalText([]byte(query.Get("destination"))); err != nil {
+		http.Error(w, fmt.Sprintf("invalid destination %q", query.Get("destination")), http.StatusBadRequest)
+		return
+	}
+
+	req := policyRequest{
+		path:   query.Get("path"),
+		method: strings.ToUpper(query.Get("method")),
+	}
+
+	if req.path == "" {
+		req.path = "/"
+	}
+
+	if req.method == "" {
+		req.method = http.MethodGet
+	}
+
+	if value := query.Get("port"); value != "" {
+		port, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || port <= 0 {
+			http.Error(w, fmt.Sprintf("invalid port %q", value), http.StatusBadRequest)
+			return
+		}
+
+		req.port = int32(port)
+	}
+
+	topo, _ := a.topology.Get().(*topology.Topology)
+	if topo == nil {
+		topo = topology.NewTopology()
+	}
+
+	svc, ok := topo.Services[destinationKey]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unable to find destination service %q", destinationKey), http.StatusNotFound)
+		return
+	}
+
+	trafficType, err := annotations.GetTrafficType(a.defaultMode, svc.Annotations)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to evaluate traffic type of service %q: %v", destinationKey, err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	req.trafficType = trafficType
+
+	decision := evaluatePolicy(topo, findPolicySource(topo, sourceKey), svc, req, a.aclEnabled)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(decision); err != nil {
+		a.log.Errorf("Unable to serialize policy decision: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// findPolicySource returns the pod having the given key if any, or the service account having the given key along
+// with its pods.
+func findPolicySource(topo *topology.Topology, key topology.Key) policySource {
+	if pod, ok := topo.Pods[key]; ok {
+		return policySource{
+			Kind:           policySourcePod,
+			Name:           pod.Name,
+			Namespace:      pod.Namespace,
+			ServiceAccount: serviceAccountName(pod.ServiceAccount),
+			pods:           []topology.Key{key},
+		}
+	}
+
+	source := policySource{
+		Kind:           policySourceServiceAccount,
+		Name:           key.Name,
+		Namespace:      key.Namespace,
+		ServiceAccount: key.Name,
+	}
+
+	for podKey, pod := range topo.Pods {
+		if pod.Namespace == key.Namespace && serviceAccountName(pod.ServiceAccount) == key.Name {
+			source.pods = append(source.pods, podKey)
+		}
+	}
+
+	sort.Slice(source.pods, func(i, j int) bool {
+		return source.pods[i].String() < source.pods[j].String()
+	})
+
+	return source
+}
+
+// evaluatePolicy evaluates if the given source can send the given request to the given service. As the TrafficSplit
+// routers take precedence over the TrafficTarget ones, a request matching a TrafficSplit is decided by the TrafficSplit
+// incoming pods. When ACL mode is disabled, all the requests are allowed.
+func evaluatePolicy(topo *topology.Topology, source policySource, svc *topology.Service, req policyRequest, aclEnabled bool) policyDecision {
+	decision := policyDecision{
+		ACL:         aclEnabled,
+		Source:      source,
+		Destination: topology.Key{Name: svc.Name, Namespace: svc.Namespace},
+	}
+
+	if req.port != 0 && !hasServicePort(svc.Ports, req.port) {
+		decision.Reason = fmt.Sprintf("service %s has no port %d", decision.Destination, req.port)
+		return decision
+	}
+
+	if !aclEnabled {
+		decision.Allowed = true
+		decision.Reason = "ACL mode is disabled, all the requests are allowed"
+
+		return decision
+	}
+
+	if req.trafficType == annotations.ServiceTypeUDP {
+		return evaluateUDPPolicy(topo, svc, decision)
+	}
+
+	for _, tsKey := range svc.TrafficSplits {
+		ts, ok := topo.TrafficSplits[tsKey]
+		if !ok {
+			continue
+		}
+
+		rule, ok := matchTrafficSpecs(ts.Rules, req)
+		if !ok {
+			continue
+		}
+
+		tsKey := tsKey
+		decision.TrafficSplit = &tsKey
+		decision.Rule = rule
+
+		for _, podKey := range source.pods {
+			if containsKey(ts.Incoming, podKey) {
+				decision.Allowed = true
+				decision.Reason = fmt.Sprintf("pod %s is allowed to access all the backends of TrafficSplit %s", podKey, tsKey)
+
+				return decision
+			}
+		}
+
+		decision.Reason = fmt.Sprintf("source is not allowed to access all the backends of TrafficSplit %s", tsKey)
+
+		return decision
+	}
+
+	for _, ttKey := range svc.TrafficTargets {
+		tt, ok := topo.ServiceTrafficTargets[ttKey]
+		if !ok {
+			continue
+		}
+
+		if req.port != 0 && !hasServicePort(tt.Destination.Ports, req.port) {
+			continue
+		}
+
+		if !hasTrafficTargetSource(tt, source) {
+			continue
+		}
+
+		rule, ok := matchTrafficSpecs(tt.Rules, req)
+		if !ok {
+			continue
+		}
+
+		decision.Allowed = true
+		decision.TrafficTarget = &topology.Key{Name: tt.Name, Namespace: tt.Namespace}
+		decision.Rule = rule
+		decision.Reason = fmt.Sprintf("allowed by TrafficTarget %s", decision.TrafficTarget)
+
+		return decision
+	}
+
+	decision.Reason = "no TrafficTarget allows the request"
+
+	return decision
+}
+
+// evaluateUDPPolicy evaluates the requests to the given UDP service. As UDP traffic cannot be tagged, the provider
+// doesn't build routers for the TrafficTargets of the UDP services, and the UDP routers of their TrafficSplits don't
+// restrict the sources.
+func evaluateUDPPolicy(topo *topology.Topology, svc *topology.Service, decision policyDecision) policyDecision {
+	for _, tsKey := range svc.TrafficSplits {
+		if _, ok := topo.TrafficSplits[tsKey]; !ok {
+			continue
+		}
+
+		tsKey := tsKey
+		decision.TrafficSplit = &tsKey
+		decision.Allowed = true
+		decision.Reason = fmt.Sprintf("UDP traffic through TrafficSplit %s is allowed for all the sources", tsKey)
+
+		return decision
+	}
+
+	decision.Reason = "TrafficTargets are not supported for UDP services, all the UDP requests are denied"
+
+	return decision
+}
+
+// matchTrafficSpecs returns the rule of the given specs matching the given request. Specs without rules match all
+// requests, TCPRoutes only match the requests to TCP services, UDP services are never matched, and the header
+// conditions of the HTTP matches are not evaluated.
+func matchTrafficSpecs(trafficSpecs []topology.TrafficSpec, req policyRequest) (*policyRule, bool) {
+	if req.trafficType == annotations.ServiceTypeUDP {
+		return nil, false
+	}
+
+	if len(trafficSpecs) == 0 {
+		return nil, true
+	}
+
+	for _, spec := range trafficSpecs {
+		if req.trafficType == annotations.ServiceTypeTCP {
+			if spec.TCPRoute != nil {
+				return &policyRule{TCPRoute: &topology.Key{Name: spec.TCPRoute.Name, Namespace: spec.TCPRoute.Namespace}}, true
+			}
+
+			continue
+		}
+
+		for _, match := range spec.HTTPMatches {
+			if !matchHTTPMatch(match, req) {
+				continue
+			}
+
+			rule := &policyRule{Match: match}
+			if spec.HTTPRouteGroup != nil {
+				rule.HTTPRouteGroup = &topology.Key{Name: spec.HTTPRouteGroup.Name, Namespace: spec.HTTPRouteGroup.Namespace}
+			}
+
+			return rule, true
+		}
+	}
+
+	return nil, false
+}
+
+// matchHTTPMatch returns true if the given request matches the path and methods of the given HTTPMatch, the same way
+// the routers built by the provider do.
+func matchHTTPMatch(match *specs.HTTPMatch, req policyRequest) bool {
+	if match.PathRegex != "" {
+		pathRegex, err := regexp.Compile("^/(?:" + strings.TrimPrefix(match.PathRegex, "/") + ")")
+		if err != nil || !pathRegex.MatchString(req.path) {
+			return false
+		}
+	}
+
+	if len(match.Methods) == 0 {
+		return true
+	}
+
+	for _, method := range match.Methods {
+		if method == "*" || strings.EqualFold(method, req.method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasTrafficTargetSource(tt *topology.ServiceTrafficTarget, source policySource) bool {
+	for _, ttSource := range tt.Sources {
+		if ttSource.Namespace == source.Namespace && ttSource.ServiceAccount == source.ServiceAccount {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasServicePort(ports []corev1.ServicePort, port int32) bool {
+	for _, svcPort := range ports {
+		if svcPort.Port == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsKey(keys []topology.Key, key topology.Key) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serviceAccountName returns the given service account name, defaulting to the one Kubernetes assigns to pods.
+func serviceAccountName(name string) string {
+	if name == "" {
+		return "default"
+	}
+
+	return name
+}
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	specs "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/specs/v1alpha3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/topology"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	tests := []struct {
+		desc              string
+		query             url.Values
+		aclDisabled       bool
+		wantCode          int
+		wantAllowed       bool
+		wantSourceKind    string
+		wantTrafficTarget string
+		wantTrafficSplit  string
+		wantMatch         string
+		wantTCPRoute      string
+	}{
+		{
+			desc:              "pod allowed by a TrafficTarget",
+			query:             url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-b@my-ns"}, "port": {"8080"}, "path": {"/api/users"}, "method": {"get"}},
+			wantCode:          http.StatusOK,
+			wantAllowed:       true,
+			wantSourceKind:    "pod",
+			wantTrafficTarget: "tt@my-ns",
+			wantMatch:         "api",
+		},
+		{
+			desc:              "service account allowed by a TrafficTarget",
+			query:             url.Values{"source": {"client@my-ns"}, "destination": {"svc-b@my-ns"}, "path": {"/api"}},
+			wantCode:          http.StatusOK,
+			wantAllowed:       true,
+			wantSourceKind:    "serviceAccount",
+			wantTrafficTarget: "tt@my-ns",
+			wantMatch:         "api",
+		},
+		{
+			desc:           "path not matching the TrafficTarget rules",
+			query:          url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-b@my-ns"}, "path": {"/admin"}},
+			wantCode:       http.StatusOK,
+			wantSourceKind: "pod",
+		},
+		{
+			desc:           "method not matching the TrafficTarget rules",
+			query:          url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-b@my-ns"}, "path": {"/api"}, "method": {"DELETE"}},
+			wantCode:       http.StatusOK,
+			wantSourceKind: "pod",
+		},
+		{
+			desc:           "source not listed in the TrafficTarget",
+			query:          url.Values{"source": {"pod-c@my-ns"}, "destination": {"svc-b@my-ns"}, "path": {"/api"}},
+			wantCode:       http.StatusOK,
+			wantSourceKind: "pod",
+		},
+		{
+			desc:           "port not exposed by the service",
+			query:          url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-b@my-ns"}, "port": {"9090"}, "path": {"/api"}},
+			wantCode:       http.StatusOK,
+			wantSourceKind: "pod",
+		},
+		{
+			desc:             "pod allowed through a TrafficSplit",
+			query:            url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-a@my-ns"}},
+			wantCode:         http.StatusOK,
+			wantAllowed:      true,
+			wantSourceKind:   "pod",
+			wantTrafficSplit: "ts@my-ns",
+		},
+		{
+			desc:             "pod not incoming of a TrafficSplit",
+			query:            url.Values{"source": {"pod-c@my-ns"}, "destination": {"svc-a@my-ns"}},
+			wantCode:         http.StatusOK,
+			wantSourceKind:   "pod",
+			wantTrafficSplit: "ts@my-ns",
+		},
+		{
+			desc:              "TCP service allowed by a TCPRoute",
+			query:             url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-tcp@my-ns"}, "path": {"/admin"}},
+			wantCode:          http.StatusOK,
+			wantAllowed:       true,
+			wantSourceKind:    "pod",
+			wantTrafficTarget: "tt-tcp@my-ns",
+			wantTCPRoute:      "tcp-route@my-ns",
+		},
+		{
+			desc:           "HTTP service not allowed by a TCPRoute",
+			query:          url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-c@my-ns"}},
+			wantCode:       http.StatusOK,
+			wantSourceKind: "pod",
+		},
+		{
+			desc:           "UDP service not allowed by a TrafficTarget",
+			query:          url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-udp@my-ns"}},
+			wantCode:       http.StatusOK,
+			wantSourceKind: "pod",
+		},
+		{
+			desc:             "UDP service allowed through a TrafficSplit for all the sources",
+			query:            url.Values{"source": {"pod-c@my-ns"}, "destination": {"svc-udp-split@my-ns"}},
+			wantCode:         http.StatusOK,
+			wantAllowed:      true,
+			wantSourceKind:   "pod",
+			wantTrafficSplit: "ts-udp@my-ns",
+		},
+		{
+			desc:           "ACL disabled",
+			query:          url.Values{"source": {"pod-c@my-ns"}, "destination": {"svc-b@my-ns"}, "path": {"/admin"}},
+			aclDisabled:    true,
+			wantCode:       http.StatusOK,
+			wantAllowed:    true,
+			wantSourceKind: "pod",
+		},
+		{
+			desc:     "unknown destination",
+			query:    url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-z@my-ns"}},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			desc:     "invalid source",
+			query:    url.Values{"source": {"pod-a"}, "destination": {"svc-b@my-ns"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			desc:     "invalid port",
+			query:    url.Values{"source": {"pod-a@my-ns"}, "destination": {"svc-b@my-ns"}, "port": {"http"}},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+
+			var opts []Option
+			if !test.aclDisabled {
+				opts = append(opts, ACL())
+			}
+
+			api, err := NewAPI(log, 9000, localhost, fake.NewSimpleClientset(), "foo", prometheus.NewRegistry(), opts...)
+			require.NoError(t, err)
+
+			api.SetTopology(newPolicyTopology())
+
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/policy/check?"+test.query.Encode(), nil)
+
+			api.Handler.ServeHTTP(res, req)
+
+			require.Equal(t, test.wantCode, res.Code)
+
+			if test.wantCode != http.StatusOK {
+				return
+			}
+
+			var decision policyDecision
+			require.NoError(t, json.Unmarshal(res.Body.Bytes(), &decision))
+
+			assert.Equal(t, !test.aclDisabled, decision.ACL)
+			assert.Equal(t, test.wantAllowed, decision.Allowed, decision.Reason)
+			assert.NotEmpty(t, decision.Reason)
+			assert.Equal(t, test.wantSourceKind, decision.Source.Kind)
+
+			if test.wantTrafficTarget != "" {
+				require.NotNil(t, decision.TrafficTarget)
+				assert.Equal(t, test.wantTrafficTarget, decision.TrafficTarget.String())
+			} else {
+				assert.Nil(t, decision.TrafficTarget)
+			}
+
+			if test.wantTrafficSplit != "" {
+				require.NotNil(t, decision.TrafficSplit)
+				assert.Equal(t, test.wantTrafficSplit, decision.TrafficSplit.String())
+			} else {
+				assert.Nil(t, decision.TrafficSplit)
+			}
+
+			if test.wantMatch != "" {
+				require.NotNil(t, decision.Rule)
+				require.NotNil(t, decision.Rule.Match)
+				assert.Equal(t, test.wantMatch, decision.Rule.Match.Name)
+				assert.Equal(t, "rg@my-ns", decision.Rule.HTTPRouteGroup.String())
+			}
+
+			if test.wantTCPRoute != "" {
+				require.NotNil(t, decision.Rule)
+				require.NotNil(t, decision.Rule.TCPRoute)
+				assert.Equal(t, test.wantTCPRoute, decision.Rule.TCPRoute.String())
+			}
+		})
+	}
+}
+
+func newPolicyTopology() *topology.Topology {
+	port := corev1.ServicePort{Name: "http", Port: 8080}
+
+	podA := topology.Key{Name: "pod-a", Namespace: "my-ns"}
+	podB := topology.Key{Name: "pod-b", Namespace: "my-ns"}
+	podC := topology.Key{Name: "pod-c", Namespace: "my-ns"}
+
+	svcA := topology.Key{Name: "svc-a", Namespace: "my-ns"}
+	svcB := topology.Key{Name: "svc-b", Namespace: "my-ns"}
+	svcC := topology.Key{Name: "svc-c", Namespace: "my-ns"}
+	svcTCP := topology.Key{Name: "svc-tcp", Namespace: "my-ns"}
+
+	ttKey := topology.ServiceTrafficTargetKey{Service: svcB, TrafficTarget: topology.Key{Name: "tt", Namespace: "my-ns"}}
+	ttHTTPKey := topology.ServiceTrafficTargetKey{Service: svcC, TrafficTarget: topology.Key{Name: "tt-tcp", Namespace: "my-ns"}}
+	ttTCPKey := topology.ServiceTrafficTargetKey{Service: svcTCP, TrafficTarget: topology.Key{Name: "tt-tcp", Namespace: "my-ns"}}
+	tsKey := topology.Key{Name: "ts", Namespace: "my-ns"}
+
+	apiMatch := &specs.HTTPMatch{Name: "api", PathRegex: "/api", Methods: []string{"GET", "POST"}}
+
+	topo := topology.NewTopology()
+
+	topo.Pods[podA] = &topology.Pod{Name: "pod-a", Namespace: "my-ns", ServiceAccount: "client", SourceOf: []topology.ServiceTrafficTargetKey{ttKey}}
+	topo.Pods[podB] = &topology.Pod{Name: "pod-b", Namespace: "my-ns", ServiceAccount: "server", DestinationOf: []topology.ServiceTrafficTargetKey{ttKey}}
+	topo.Pods[podC] = &topology.Pod{Name: "pod-c", Namespace: "my-ns", ServiceAccount: "other"}
+
+	topo.Services[svcA] = &topology.Service{Name: "svc-a", Namespace: "my-ns", Ports: []corev1.ServicePort{port}, TrafficSplits: []topology.Key{tsKey}}
+	topo.Services[svcB] = &topology.Service{
+		Name:           "svc-b",
+		Namespace:      "my-ns",
+		Ports:          []corev1.ServicePort{port},
+		Pods:           []topology.Key{podB},
+		TrafficTargets: []topology.ServiceTrafficTargetKey{ttKey},
+		BackendOf:      []topology.Key{tsKey},
+	}
+
+	topo.ServiceTrafficTargets[ttKey] = &topology.ServiceTrafficTarget{
+		Service:   svcB,
+		Name:      "tt",
+		Namespace: "my-ns",
+		Sources: []topology.ServiceTrafficTargetSource{
+			{ServiceAccount: "client", Namespace: "my-ns", Pods: []topology.Key{podA}},
+		},
+		Destination: topology.ServiceTrafficTargetDestination{
+			ServiceAccount: "server",
+			Namespace:      "my-ns",
+			Ports:          []corev1.ServicePort{port},
+			Pods:           []topology.Key{podB},
+		},
+		Rules: []topology.TrafficSpec{
+			{
+				HTTPRouteGroup: &specs.HTTPRouteGroup{ObjectMeta: metav1.ObjectMeta{Name: "rg", Namespace: "my-ns"}},
+				HTTPMatches:    []*specs.HTTPMatch{apiMatch},
+			},
+		},
+	}
+
+	// The same TrafficTarget, allowing TCP traffic only, applies on an HTTP and on a TCP service.
+	tcpRule := topology.TrafficSpec{TCPRoute: &specs.TCPRoute{ObjectMeta: metav1.ObjectMeta{Name: "tcp-route", Namespace: "my-ns"}}}
+
+	topo.Services[svcC] = &topology.Service{Name: "svc-c", Namespace: "my-ns", Ports: []corev1.ServicePort{port}, TrafficTargets: []topology.ServiceTrafficTargetKey{ttHTTPKey}}
+	topo.Services[svcTCP] = &topology.Service{
+		Name:           "svc-tcp",
+		Namespace:      "my-ns",
+		Annotations:    map[string]string{"mesh.traefik.io/traffic-type": "tcp"},
+		Ports:          []corev1.ServicePort{port},
+		TrafficTargets: []topology.ServiceTrafficTargetKey{ttTCPKey},
+	}
+
+	for _, key := range []topology.ServiceTrafficTargetKey{ttHTTPKey, ttTCPKey} {
+		topo.ServiceTrafficTargets[key] = &topology.ServiceTrafficTarget{
+			Service:   key.Service,
+			Name:      "tt-tcp",
+			Namespace: "my-ns",
+			Sources: []topology.ServiceTrafficTargetSource{
+				{ServiceAccount: "client", Namespace: "my-ns", Pods: []topology.Key{podA}},
+			},
+			Destination: topology.ServiceTrafficTargetDestination{ServiceAccount: "server", Namespace: "my-ns"},
+			Rules:       []topology.TrafficSpec{tcpRule},
+		}
+	}
+
+	// UDP services are not covered by the TrafficTargets, and the sources of their TrafficSplits are not restricted.
+	svcUDP := topology.Key{Name: "svc-udp", Namespace: "my-ns"}
+	svcUDPSplit := topology.Key{Name: "svc-udp-split", Namespace: "my-ns"}
+	ttUDPKey := topology.ServiceTrafficTargetKey{Service: svcUDP, TrafficTarget: topology.Key{Name: "tt-udp", Namespace: "my-ns"}}
+	tsUDPKey := topology.Key{Name: "ts-udp", Namespace: "my-ns"}
+	udpAnnotations := map[string]string{"mesh.traefik.io/traffic-type": "udp"}
+
+	topo.Services[svcUDP] = &topology.Service{
+		Name:           "svc-udp",
+		Namespace:      "my-ns",
+		Annotations:    udpAnnotations,
+		Ports:          []corev1.ServicePort{port},
+		TrafficTargets: []topology.ServiceTrafficTargetKey{ttUDPKey},
+		BackendOf:      []topology.Key{tsUDPKey},
+	}
+	topo.Services[svcUDPSplit] = &topology.Service{
+		Name:          "svc-udp-split",
+		Namespace:     "my-ns",
+		Annotations:   udpAnnotations,
+		Ports:         []corev1.ServicePort{port},
+		TrafficSplits: []topology.Key{tsUDPKey},
+	}
+
+	topo.ServiceTrafficTargets[ttUDPKey] = &topology.ServiceTrafficTarget{
+		Service:   svcUDP,
+		Name:      "tt-udp",
+		Namespace: "my-ns",
+		Sources: []topology.ServiceTrafficTargetSource{
+			{ServiceAccount: "client", Namespace: "my-ns", Pods: []topology.Key{podA}},
+		},
+		Destination: topology.ServiceTrafficTargetDestination{ServiceAccount: "server", Namespace: "my-ns"},
+	}
+
+	topo.TrafficSplits[tsUDPKey] = &topology.TrafficSplit{
+		Name:      "ts-udp",
+		Namespace: "my-ns",
+		Service:   svcUDPSplit,
+		Backends:  []topology.TrafficSplitBackend{{Weight: 100, Service: svcUDP}},
+	}
+
+	topo.TrafficSplits[tsKey] = &topology.TrafficSplit{
+		Name:      "ts",
+		Namespace: "my-ns",
+		Service:   svcA,
+		Backends:  []topology.TrafficSplitBackend{{Weight: 100, Service: svcB}},
+		Incoming:  []topology.Key{podA},
+	}
+
+	return topo
+}
@@ -0,0 +1,348 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traefik/paerser/file"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// meshNodeTimeout is the maximum duration to wait for a mesh node to return its configuration.
+const meshNodeTimeout = 5 * time.Second
+
+// meshNodeConcurrency is the maximum number of mesh nodes queried concurrently.
+const meshNodeConcurrency = 10
+
+// meshNodeRetryDelay is the default delay before fetching again the configuration of a mesh node which seems to have
+// diverged, as it may have been fetched while applying a new configuration.
+const meshNodeRetryDelay = time.Second
+
+// meshNodeProvider is the name of the provider used by the mesh nodes to fetch the configuration from the controller.
+const meshNodeProvider = "http"
+
+// Synchronization statuses of the mesh nodes.
+const (
+	syncStatusSynced      = "synced"
+	syncStatusStale       = "stale"
+	syncStatusDiverged    = "diverged"
+	syncStatusUnreachable = "unreachable"
+)
+
+// syncStatus is the synchronization status of the mesh nodes with the controller configuration.
+type syncStatus struct {
+	Version string           `json:"version"`
+	Nodes   []nodeSyncStatus `json:"nodes"`
+}
+
+// nodeSyncStatus is the synchronization status of a mesh node.
+type nodeSyncStatus struct {
+	Name   string `json:"name"`
+	IP     string `json:"ip"`
+	Status string `json:"status"`
+	// Version is the version of the configuration applied by the node, when it matches a known configuration.
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Diff is the difference between the configuration applied by the node and the current configuration.
+	Diff *configurationDiff `json:"diff,omitempty"`
+}
+
+// rawDataSections maps the sections of the raw data returned by the Traefik API of the mesh nodes to the sections
+// of a dynamic configuration.
+var rawDataSections = map[string][2]string{
+	"routers":        {"http", "routers"},
+	"services":       {"http", "services"},
+	"middlewares":    {"http", "middlewares"},
+	"tcpRouters":     {"tcp", "routers"},
+	"tcpServices":    {"tcp", "services"},
+	"tcpMiddlewares": {"tcp", "middlewares"},
+	"udpRouters":     {"udp", "routers"},
+	"udpServices":    {"udp", "services"},
+}
+
+// getSyncStatus fetches the configuration applied by each mesh node and compares it with the current configuration.
+// Nodes which can't be reached are reported as unreachable, nodes running a previous configuration of the history
+// as stale and nodes running any other configuration as diverged.
+func (a *API) getSyncStatus(w http.ResponseWriter, r *http.Request) {
+	pods, err := a.podLister.List(labels.Everything())
+	if err != nil {
+		a.log.Errorf("Unable to retrieve pod list: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	data, version, _ := a.configuration.Get()
+
+	current, err := parseRawConfiguration(data)
+	if err != nil {
+		a.log.Errorf("Unable to parse current configuration: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+
+		return
+	}
+
+	previous := a.previousRawConfigurations(version)
+
+	status := syncStatus{
+		Version: version,
+		Nodes:   make([]nodeSyncStatus, len(pods)),
+	}
+
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, meshNodeConcurrency)
+
+	for i, pod := range pods {
+		wg.Add(1)
+
+		sem <- struct{}{}
+
+		go func(i int, pod *corev1.Pod) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			status.Nodes[i] = a.getNodeSyncStatus(r.Context(), pod, version, current, previous)
+		}(i, pod)
+	}
+
+	wg.Wait()
+
+	sort.Slice(status.Nodes, func(i, j int) bool {
+		return status.Nodes[i].Name < status.Nodes[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		a.log.Errorf("Unable to serialize sync status: %v", err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// versionedRawConfiguration is a configuration of the history, kept serialized to be compared.
+type versionedRawConfiguration struct {
+	version string
+	cfg     rawConfiguration
+}
+
+// previousRawConfigurations returns the configurations of the history which are not the current one, from the newest
+// to the oldest.
+func (a *API) previousRawConfigurations(currentVersion string) []versionedRawConfiguration {
+	var cfgs []versionedRawConfiguration
+
+	for _, entry := range a.history.List() {
+		if entry.Version == currentVersion {
+			continue
+		}
+
+		cfg, err := parseRawConfiguration(entry.Configuration)
+		if err != nil {
+			a.log.Errorf("Unable to parse configuration %q: %v", entry.Version, err)
+			continue
+		}
+
+		cfgs = append(cfgs, versionedRawConfiguration{version: entry.Version, cfg: cfg})
+	}
+
+	return cfgs
+}
+
+// getNodeSyncStatus returns the synchronization status of the given mesh node. A node which seems to have diverged is
+// fetched again after a delay before being reported as diverged.
+func (a *API) getNodeSyncStatus(ctx context.Context, pod *corev1.Pod, version string, current rawConfiguration, previous []versionedRawConfiguration) nodeSyncStatus {
+	status := nodeSyncStatus{
+		Name: pod.Name,
+		IP:   pod.Status.PodIP,
+	}
+
+	nodeCfg, err := a.fetchNodeConfiguration(ctx, pod)
+	if err != nil {
+		a.log.Debugf("Unable to get configuration from pod %q: %v", pod.Name, err)
+
+		status.Status = syncStatusUnreachable
+		status.Error = err.Error()
+
+		return status
+	}
+
+	status = compareNodeConfiguration(status, nodeCfg, version, current, previous)
+	if status.Status != syncStatusDiverged {
+		return status
+	}
+
+	select {
+	case <-ctx.Done():
+		return status
+	case <-time.After(a.meshNodeRetryDelay):
+	}
+
+	nodeCfg, err = a.fetchNodeConfiguration(ctx, pod)
+	if err != nil {
+		a.log.Debugf("Unable to get configuration from pod %q: %v", pod.Name, err)
+
+		return status
+	}
+
+	return compareNodeConfiguration(status, nodeCfg, version, current, previous)
+}
+
+// compareNodeConfiguration sets the synchronization status of a mesh node from the configuration it applies.
+func compareNodeConfiguration(status nodeSyncStatus, nodeCfg rawConfiguration, version string, current rawConfiguration, previous []versionedRawConfiguration) nodeSyncStatus {
+	status.Version = ""
+	status.Diff = nil
+
+	diff := diffRawConfigurations("", version, nodeCfg, current)
+	if diff.Empty() {
+		status.Status = syncStatusSynced
+		status.Version = version
+
+		return status
+	}
+
+	status.Status = syncStatusDiverged
+	status.Diff = diff
+
+	for _, prev := range previous {
+		if diffRawConfigurations(prev.version, version, nodeCfg, prev.cfg).Empty() {
+			status.Status = syncStatusStale
+			status.Version = prev.version
+			status.Diff.From = prev.version
+
+			break
+		}
+	}
+
+	return status
+}
+
+// fetchNodeConfiguration returns the configuration applied by the given mesh node. Only the objects provided by the
+// controller are kept.
+func (a *API) fetchNodeConfiguration(ctx context.Context, pod *corev1.Pod) (rawConfiguration, error) {
+	if pod.Status.PodIP == "" {
+		return rawConfiguration{}, errors.New("pod has no IP")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, meshNodeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.meshNodeRawDataURL(pod), http.NoBody)
+	if err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to get configuration: %w", err)
+	}
+
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			a.log.Errorf("Unable to close response body: %v", closeErr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return rawConfiguration{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to read configuration: %w", err)
+	}
+
+	return parseNodeRawData(body)
+}
+
+// meshNodeRawDataURL returns the URL of the Traefik API endpoint exposing the configuration of the given mesh node.
+func (a *API) meshNodeRawDataURL(pod *corev1.Pod) string {
+	return fmt.Sprintf("http://%s/api/rawdata", net.JoinHostPort(pod.Status.PodIP, a.meshNodePort))
+}
+
+// parseNodeRawData converts the raw data returned by the Traefik API of a mesh node into a configuration. Objects
+// which are not provided by the controller are dropped, and the provider suffix is removed from the object names.
+func parseNodeRawData(data []byte) (rawConfiguration, error) {
+	var rawData map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rawData); err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to parse raw data: %w", err)
+	}
+
+	cfg := make(map[string]map[string]map[string]json.RawMessage)
+
+	for name, objects := range rawData {
+		section, ok := rawDataSections[name]
+		if !ok {
+			continue
+		}
+
+		for qualifiedName, object := range objects {
+			objectName := strings.TrimSuffix(qualifiedName, "@"+meshNodeProvider)
+			if objectName == qualifiedName {
+				continue
+			}
+
+			if cfg[section[0]] == nil {
+				cfg[section[0]] = make(map[string]map[string]json.RawMessage)
+			}
+
+			if cfg[section[0]][section[1]] == nil {
+				cfg[section[0]][section[1]] = make(map[string]json.RawMessage)
+			}
+
+			cfg[section[0]][section[1]][objectName] = object
+		}
+	}
+
+	cfgData, err := json.Marshal(cfg)
+	if err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to serialize configuration: %w", err)
+	}
+
+	return parseRawConfiguration(cfgData)
+}
+
+// parseRawConfiguration parses the given serialized dynamic configuration. The configuration goes through the dynamic
+// configuration types, which drops the runtime fields (status, errors...) added by Traefik and makes the objects
+// serialized the same way whatever their origin. It is then decoded the way the HTTP provider of the mesh nodes
+// decodes it, so the default values the nodes apply (e.g. the TCP termination delay) are set on both sides.
+func parseRawConfiguration(data []byte) (rawConfiguration, error) {
+	var cfg dynamic.Configuration
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to parse configuration: %w", err)
+	}
+
+	stripped, err := json.Marshal(cfg)
+	if err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to serialize configuration: %w", err)
+	}
+
+	var decoded dynamic.Configuration
+	if err = file.DecodeContent(string(stripped), ".json", &decoded); err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to decode configuration: %w", err)
+	}
+
+	normalized, err := json.Marshal(decoded)
+	if err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to serialize configuration: %w", err)
+	}
+
+	var raw rawConfiguration
+	if err := json.Unmarshal(normalized, &raw); err != nil {
+		return rawConfiguration{}, fmt.Errorf("unable to parse configuration: %w", err)
+	}
+
+	return raw, nil
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	fooRawData = `{
+		"routers": {
+			"foo@http": {"entryPoints": ["http-5000"], "service": "foo", "rule": "Host(` + "`foo`" + `)", "status": "enabled", "using": ["http-5000"]},
+			"api@internal": {"entryPoints": ["traefik"], "service": "api@internal", "rule": "PathPrefix(` + "`/api`" + `)", "status": "enabled"}
+		},
+		"services": {
+			"foo@http": {"loadBalancer": {"servers": [{"url": "http://10.10.10.10:80"}]}, "status": "enabled", "usedBy": ["foo@http"], "serverStatus": {"http://10.10.10.10:80": "UP"}},
+			"api@internal": {"status": "enabled", "usedBy": ["api@internal"]}
+		}
+	}`
+	barRawData = `{
+		"routers": {
+			"bar@http": {"entryPoints": ["http-5000"], "service": "bar", "rule": "Host(` + "`bar`" + `)", "status": "enabled"}
+		},
+		"services": {
+			"bar@http": {"loadBalancer": {"servers": [{"url": "http://10.10.10.11:80"}]}, "status": "enabled"}
+		}
+	}`
+)
+
+func TestGetSyncStatus(t *testing.T) {
+	fooCfg := &dynamic.Configuration{HTTP: &dynamic.HTTPConfiguration{
+		Routers: map[string]*dynamic.Router{
+			"foo": {EntryPoints: []string{"http-5000"}, Service: "foo", Rule: "Host(`foo`)"},
+		},
+		Services: map[string]*dynamic.Service{
+			"foo": {LoadBalancer: &dynamic.ServersLoadBalancer{Servers: []dynamic.Server{{URL: "http://10.10.10.10:80"}}}},
+		},
+	}}
+	barCfg := &dynamic.Configuration{HTTP: &dynamic.HTTPConfiguration{
+		Routers: map[string]*dynamic.Router{
+			"bar": {EntryPoints: []string{"http-5000"}, Service: "bar", Rule: "Host(`bar`)"},
+		},
+		Services: map[string]*dynamic.Service{
+			"bar": {LoadBalancer: &dynamic.ServersLoadBalancer{Servers: []dynamic.Server{{URL: "http://10.10.10.11:80"}}}},
+		},
+	}}
+
+	testCases := []struct {
+		desc            string
+		statusCode      int
+		rawData         string
+		retryRawData    string
+		expectedStatus  string
+		expectedVersion string
+		expectedDiff    bool
+	}{
+		{
+			desc:            "synced node",
+			statusCode:      http.StatusOK,
+			rawData:         barRawData,
+			expectedStatus:  syncStatusSynced,
+			expectedVersion: "bar",
+		},
+		{
+			desc:            "stale node",
+			statusCode:      http.StatusOK,
+			rawData:         fooRawData,
+			expectedStatus:  syncStatusStale,
+			expectedVersion: "foo",
+			expectedDiff:    true,
+		},
+		{
+			desc:           "diverged node",
+			statusCode:     http.StatusOK,
+			rawData:        `{"routers": {"baz@http": {"service": "baz"}}}`,
+			expectedStatus: syncStatusDiverged,
+			expectedDiff:   true,
+		},
+		{
+			desc:            "node synced on retry",
+			statusCode:      http.StatusOK,
+			rawData:         `{"routers": {"baz@http": {"service": "baz"}}}`,
+			retryRawData:    barRawData,
+			expectedStatus:  syncStatusSynced,
+			expectedVersion: "bar",
+		},
+		{
+			desc:           "unreachable node",
+			statusCode:     http.StatusInternalServerError,
+			expectedStatus: syncStatusUnreachable,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			var requests int32
+
+			node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rawData := test.rawData
+				if atomic.AddInt32(&requests, 1) > 1 && test.retryRawData != "" {
+					rawData = test.retryRawData
+				}
+
+				w.WriteHeader(test.statusCode)
+				_, _ = w.Write([]byte(rawData))
+			}))
+			defer node.Close()
+
+			nodeURL, err := url.Parse(node.URL)
+			require.NoError(t, err)
+
+			host, port, err := net.SplitHostPort(nodeURL.Host)
+			require.NoError(t, err)
+
+			client := fake.NewSimpleClientset(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "mesh-pod-1",
+					Namespace: "foo",
+					Labels:    map[string]string{"component": "maesh-mesh"},
+				},
+				Status: corev1.PodStatus{PodIP: host},
+			})
+
+			api, err := NewAPI(log, 9000, localhost, client, "foo", prometheus.NewRegistry())
+			require.NoError(t, err)
+
+			api.meshNodePort = port
+			api.meshNodeRetryDelay = 0
+
+			versions := make(map[string]string)
+
+			api.SetConfig(fooCfg)
+			api.RecordHistory("ns/foo")
+			_, versions["foo"], _ = api.configuration.Get()
+
+			api.SetConfig(barCfg)
+			api.RecordHistory("ns/bar")
+			_, versions["bar"], _ = api.configuration.Get()
+
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/status/sync", nil)
+
+			api.Handler.ServeHTTP(res, req)
+
+			require.Equal(t, http.StatusOK, res.Code)
+
+			var status syncStatus
+
+			require.NoError(t, json.Unmarshal(res.Body.Bytes(), &status))
+			assert.Equal(t, versions["bar"], status.Version)
+			require.Len(t, status.Nodes, 1)
+
+			nodeStatus := status.Nodes[0]
+			assert.Equal(t, "mesh-pod-1", nodeStatus.Name)
+			assert.Equal(t, host, nodeStatus.IP)
+			assert.Equal(t, test.expectedStatus, nodeStatus.Status)
+			assert.Equal(t, versions[test.expectedVersion], nodeStatus.Version)
+
+			if test.expectedStatus == syncStatusUnreachable {
+				assert.NotEmpty(t, nodeStatus.Error)
+			}
+
+			if !test.expectedDiff {
+				assert.Nil(t, nodeStatus.Diff)
+				return
+			}
+
+			require.NotNil(t, nodeStatus.Diff)
+			assert.Equal(t, versions["bar"], nodeStatus.Diff.To)
+			assert.Contains(t, nodeStatus.Diff.HTTP.Routers.Added, "bar")
+		})
+	}
+}
+
+func TestGetSyncStatus_nodeDefaults(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	// The mesh nodes apply the default values of the fields left unset by the controller: the TCP termination delay,
+	// the rate limit burst and period and the pass host header option.
+	rawData := `{
+		"middlewares": {
+			"foo-rate-limit@http": {"rateLimit": {"average": 100, "burst": 1, "period": "1s"}, "status": "enabled", "usedBy": ["foo@http"]}
+		},
+		"routers": {
+			"foo@http": {"entryPoints": ["http-5000"], "middlewares": ["foo-rate-limit"], "service": "foo", "rule": "Host(` + "`foo`" + `)", "status": "enabled"}
+		},
+		"services": {
+			"foo@http": {"loadBalancer": {"servers": [{"url": "http://10.10.10.10:80"}], "passHostHeader": true}, "status": "enabled"}
+		},
+		"tcpRouters": {
+			"bar@http": {"entryPoints": ["tcp-10000"], "service": "bar", "rule": "HostSNI(` + "`*`" + `)", "status": "enabled"}
+		},
+		"tcpServices": {
+			"bar@http": {"loadBalancer": {"terminationDelay": 100, "servers": [{"address": "10.10.10.11:80"}]}, "status": "enabled"}
+		}
+	}`
+
+	cfg := &dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"foo": {EntryPoints: []string{"http-5000"}, Middlewares: []string{"foo-rate-limit"}, Service: "foo", Rule: "Host(`foo`)"},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"foo-rate-limit": {RateLimit: &dynamic.RateLimit{Average: 100}},
+			},
+			Services: map[string]*dynamic.Service{
+				"foo": {LoadBalancer: &dynamic.ServersLoadBalancer{Servers: []dynamic.Server{{URL: "http://10.10.10.10:80"}}}},
+			},
+		},
+		TCP: &dynamic.TCPConfiguration{
+			Routers: map[string]*dynamic.TCPRouter{
+				"bar": {EntryPoints: []string{"tcp-10000"}, Service: "bar", Rule: "HostSNI(`*`)"},
+			},
+			Services: map[string]*dynamic.TCPService{
+				"bar": {LoadBalancer: &dynamic.TCPServersLoadBalancer{Servers: []dynamic.TCPServer{{Address: "10.10.10.11:80"}}}},
+			},
+		},
+	}
+
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(rawData))
+	}))
+	defer node.Close()
+
+	nodeURL, err := url.Parse(node.URL)
+	require.NoError(t, err)
+
+	host, port, err := net.SplitHostPort(nodeURL.Host)
+	require.NoError(t, err)
+
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mesh-pod-1",
+			Namespace: "foo",
+			Labels:    map[string]string{"component": "maesh-mesh"},
+		},
+		Status: corev1.PodStatus{PodIP: host},
+	})
+
+	api, err := NewAPI(log, 9000, localhost, client, "foo", prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	api.meshNodePort = port
+	api.meshNodeRetryDelay = 0
+
+	api.SetConfig(cfg)
+	api.RecordHistory("ns/foo")
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/status/sync", nil)
+
+	api.Handler.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var status syncStatus
+
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &status))
+	require.Len(t, status.Nodes, 1)
+
+	assert.Equal(t, syncStatusSynced, status.Nodes[0].Status, status.Nodes[0].Diff)
+}
+
+func TestParseNodeRawData(t *testing.T) {
+	cfg, err := parseNodeRawData([]byte(fooRawData))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]json.RawMessage{
+		"foo": json.RawMessage(`{"entryPoints":["http-5000"],"service":"foo","rule":"Host(` + "`foo`" + `)"}`),
+	}, cfg.HTTP.Routers)
+	assert.Equal(t, map[string]json.RawMessage{
+		"foo": json.RawMessage(`{"loadBalancer":{"servers":[{"url":"http://10.10.10.10:80"}],"passHostHeader":true}}`),
+	}, cfg.HTTP.Services)
+	assert.Empty(t, cfg.HTTP.Middlewares)
+	assert.Empty(t, cfg.TCP.Routers)
+}
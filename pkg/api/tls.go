@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// certificateLoader loads the API server certificate and reloads it when the certificate or key files change, which
+// allows rotating the certificate without restarting the controller.
+type certificateLoader struct {
+	certFile string
+	keyFile  string
+	log      logrus.FieldLogger
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// newCertificateLoader creates a new certificateLoader and loads the certificate.
+func newCertificateLoader(certFile, keyFile string, log logrus.FieldLogger) (*certificateLoader, error) {
+	l := &certificateLoader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      log,
+	}
+
+	if err := l.reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// GetCertificate returns the current certificate, reloading it first if the files have changed. If the new files
+// can't be loaded, the previous certificate is kept.
+func (l *certificateLoader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.reload(); err != nil {
+		l.log.Errorf("Unable to reload API certificate, keeping the previous one: %v", err)
+	}
+
+	return l.cert, nil
+}
+
+// reload loads the certificate if the certificate or key files have been modified since the last load.
+func (l *certificateLoader) reload() error {
+	certInfo, err := os.Stat(l.certFile)
+	if err != nil {
+		return fmt.Errorf("unable to read certificate file: %w", err)
+	}
+
+	keyInfo, err := os.Stat(l.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to read key file: %w", err)
+	}
+
+	if l.cert != nil && certInfo.ModTime().Equal(l.certModTime) && keyInfo.ModTime().Equal(l.keyModTime) {
+		return nil
+	}
+
+	// Record the modification times even if the files can't be loaded, to not retry on each handshake. They are
+	// loaded again as soon as one of them is modified.
+	l.certModTime = certInfo.ModTime()
+	l.keyModTime = keyInfo.ModTime()
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load certificate: %w", err)
+	}
+
+	if l.cert != nil {
+		l.log.Info("API certificate reloaded")
+	}
+
+	l.cert = &cert
+
+	return nil
+}
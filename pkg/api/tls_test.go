@@ -0,0 +1,92 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateLoader_GetCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	writeCertificate(t, certFile, keyFile, "foo", time.Now().Add(-time.Minute))
+
+	loader, err := newCertificateLoader(certFile, keyFile, logrus.New())
+	require.NoError(t, err)
+
+	cert, err := loader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", parseCertificate(t, cert.Certificate[0]).Subject.CommonName)
+
+	// Invalid files must not replace the current certificate.
+	require.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0o600))
+	require.NoError(t, os.Chtimes(certFile, time.Now(), time.Now()))
+
+	cert, err = loader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", parseCertificate(t, cert.Certificate[0]).Subject.CommonName)
+
+	writeCertificate(t, certFile, keyFile, "bar", time.Now().Add(time.Minute))
+
+	cert, err = loader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", parseCertificate(t, cert.Certificate[0]).Subject.CommonName)
+}
+
+func TestNewCertificateLoader_missingFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := newCertificateLoader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), logrus.New())
+	assert.Error(t, err)
+}
+
+// writeCertificate writes a self-signed certificate with the given common name, and its key, to the given files.
+// The modification time of the files is set to the given time.
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func parseCertificate(t *testing.T, der []byte) *x509.Certificate {
+	t.Helper()
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
@@ -2,37 +2,71 @@ package cleanup
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/pkg/dns"
+	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/mtls"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // Cleanup holds the clients for the various resource controllers.
 type Cleanup struct {
-	namespace  string
-	kubeClient kubernetes.Interface
-	dnsClient  *dns.Client
-	logger     logrus.FieldLogger
+	namespace string
+	clients   k8s.Client
+	dnsClient *dns.Client
+	dnsOpts   []dns.ClientOption
+	logger    logrus.FieldLogger
+	// dryRunOut is the writer to which the changes are printed instead of being applied, in dry-run mode.
+	dryRunOut io.Writer
+	report    Report
+}
+
+// Option configures the Cleanup.
+type Option func(c *Cleanup)
+
+// WithDNSOptions configures the client restoring the DNS with the given options.
+func WithDNSOptions(opts ...dns.ClientOption) Option {
+	return func(c *Cleanup) {
+		c.dnsOpts = append(c.dnsOpts, opts...)
+	}
+}
+
+// DryRun makes the Cleanup print the changes it would make to the given writer instead of applying them.
+func DryRun(out io.Writer) Option {
+	return func(c *Cleanup) {
+		c.dryRunOut = out
+		c.report.DryRun = true
+		c.dnsOpts = append(c.dnsOpts, dns.DryRun(out))
+	}
 }
 
 // NewCleanup returns an initialized cleanup object.
-func NewCleanup(logger logrus.FieldLogger, kubeClient kubernetes.Interface, namespace string) *Cleanup {
-	dnsClient := dns.NewClient(logger, kubeClient)
+func NewCleanup(logger logrus.FieldLogger, clients k8s.Client, namespace string, opts ...Option) *Cleanup {
+	c := &Cleanup{
+		clients:   clients,
+		logger:    logger,
+		namespace: namespace,
+	}
 
-	return &Cleanup{
-		kubeClient: kubeClient,
-		logger:     logger,
-		namespace:  namespace,
-		dnsClient:  dnsClient,
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	c.dnsClient = dns.NewClient(logger, clients.KubernetesClient(), c.dnsOpts...)
+
+	return c
 }
 
 // CleanShadowServices deletes all shadow services from the cluster.
 func (c *Cleanup) CleanShadowServices(ctx context.Context) error {
-	serviceList, err := c.kubeClient.CoreV1().Services(c.namespace).List(ctx, metav1.ListOptions{
+	serviceList, err := c.clients.KubernetesClient().CoreV1().Services(c.namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: "app=maesh,type=shadow",
 	})
 	if err != nil {
@@ -40,9 +74,18 @@ func (c *Cleanup) CleanShadowServices(ctx context.Context) error {
 	}
 
 	for _, s := range serviceList.Items {
-		if err := c.kubeClient.CoreV1().Services(s.Namespace).Delete(ctx, s.Name, metav1.DeleteOptions{}); err != nil {
+		if c.dryRunOut != nil {
+			fmt.Fprintf(c.dryRunOut, "Would delete shadow Service %s/%s\n", s.Namespace, s.Name)
+			c.report.ShadowServices = append(c.report.ShadowServices, s.Namespace+"/"+s.Name)
+
+			continue
+		}
+
+		if err := c.clients.KubernetesClient().CoreV1().Services(s.Namespace).Delete(ctx, s.Name, metav1.DeleteOptions{}); err != nil {
 			return err
 		}
+
+		c.report.ShadowServices = append(c.report.ShadowServices, s.Namespace+"/"+s.Name)
 	}
 
 	return nil
@@ -58,14 +101,187 @@ func (c *Cleanup) RestoreDNSConfig(ctx context.Context) error {
 	// Restore configmaps based on DNS provider.
 	switch provider {
 	case dns.CoreDNS:
-		if err := c.dnsClient.RestoreCoreDNS(ctx); err != nil {
+		if err := c.dnsClient.RestoreCoreDNS(ctx, c.namespace); err != nil {
 			return fmt.Errorf("unable to restore CoreDNS: %w", err)
 		}
 	case dns.KubeDNS:
-		if err := c.dnsClient.RestoreKubeDNS(ctx); err != nil {
+		if err := c.dnsClient.RestoreKubeDNS(ctx, c.namespace); err != nil {
 			return fmt.Errorf("unable to restore KubeDNS: %w", err)
 		}
 	}
 
+	nodeLocalDNS, err := c.dnsClient.CheckNodeLocalDNS(ctx)
+	if err != nil {
+		return err
+	}
+
+	if nodeLocalDNS {
+		if err := c.dnsClient.RestoreNodeLocalDNS(ctx, c.namespace); err != nil {
+			return fmt.Errorf("unable to restore NodeLocal DNSCache: %w", err)
+		}
+	}
+
+	c.report.DNSRestored = true
+	c.report.DNSBackups = c.dnsClient.RemovedBackups()
+
+	return nil
+}
+
+// CleanDNSBackups deletes the backups of the DNS configuration which have not been deleted when restoring it, as they
+// belong to ConfigMaps which are not used by the DNS of the cluster anymore. It must be called after RestoreDNSConfig.
+func (c *Cleanup) CleanDNSBackups(ctx context.Context) error {
+	if err := c.dnsClient.DeleteConfigMapBackups(ctx, c.namespace); err != nil {
+		return err
+	}
+
+	c.report.DNSBackups = c.dnsClient.RemovedBackups()
+
+	return nil
+}
+
+// CleanMeshCA deletes the Secrets storing the mesh CA created by the controller in its namespace. The Secrets created
+// by the users are left untouched.
+func (c *Cleanup) CleanMeshCA(ctx context.Context) error {
+	secretList, err := c.clients.KubernetesClient().CoreV1().Secrets(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: mtls.CASecretLabelSelector,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list mesh CA Secrets in namespace %q: %w", c.namespace, err)
+	}
+
+	for _, secret := range secretList.Items {
+		if c.dryRunOut != nil {
+			fmt.Fprintf(c.dryRunOut, "Would delete mesh CA Secret %s/%s\n", secret.Namespace, secret.Name)
+			c.report.MeshCASecrets = append(c.report.MeshCASecrets, secret.Namespace+"/"+secret.Name)
+
+			continue
+		}
+
+		err = c.clients.KubernetesClient().CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete mesh CA Secret %q in namespace %q: %w", secret.Name, secret.Namespace, err)
+		}
+
+		c.report.MeshCASecrets = append(c.report.MeshCASecrets, secret.Namespace+"/"+secret.Name)
+	}
+
+	return nil
+}
+
+// CleanEvents deletes the Events emitted by the controller, in all namespaces.
+func (c *Cleanup) CleanEvents(ctx context.Context) error {
+	eventList, err := c.clients.KubernetesClient().CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("source", k8s.EventComponent).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list Events: %w", err)
+	}
+
+	for _, event := range eventList.Items {
+		// The field selector is not supported by all the API server versions.
+		if event.Source.Component != k8s.EventComponent {
+			continue
+		}
+
+		if c.dryRunOut != nil {
+			fmt.Fprintf(c.dryRunOut, "Would delete Event %s/%s\n", event.Namespace, event.Name)
+			c.report.Events = append(c.report.Events, event.Namespace+"/"+event.Name)
+
+			continue
+		}
+
+		err = c.clients.KubernetesClient().CoreV1().Events(event.Namespace).Delete(ctx, event.Name, metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete Event %q in namespace %q: %w", event.Name, event.Namespace, err)
+		}
+
+		c.report.Events = append(c.report.Events, event.Namespace+"/"+event.Name)
+	}
+
 	return nil
 }
+
+// CleanStatusAnnotations removes the status annotation written by the controller on the TrafficSplits and the
+// TrafficTargets, in all namespaces. SMI resources whose CRD is not installed are skipped.
+func (c *Cleanup) CleanStatusAnnotations(ctx context.Context) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				k8s.StatusAnnotation: nil,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to marshal status annotation patch: %w", err)
+	}
+
+	trafficSplitList, err := c.clients.SplitClient().SplitV1alpha3().TrafficSplits(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	switch {
+	case kerrors.IsNotFound(err):
+		c.logger.Debug("TrafficSplit CRD not found, skipping")
+	case err != nil:
+		return fmt.Errorf("unable to list TrafficSplits: %w", err)
+	default:
+		for _, ts := range trafficSplitList.Items {
+			if _, ok := ts.Annotations[k8s.StatusAnnotation]; !ok {
+				continue
+			}
+
+			if err = c.removeStatusAnnotation(k8s.TrafficSplitObjectKind, ts.Namespace, ts.Name, func() error {
+				_, patchErr := c.clients.SplitClient().SplitV1alpha3().TrafficSplits(ts.Namespace).Patch(ctx, ts.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+				return patchErr
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	trafficTargetList, err := c.clients.AccessClient().AccessV1alpha2().TrafficTargets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	switch {
+	case kerrors.IsNotFound(err):
+		c.logger.Debug("TrafficTarget CRD not found, skipping")
+	case err != nil:
+		return fmt.Errorf("unable to list TrafficTargets: %w", err)
+	default:
+		for _, tt := range trafficTargetList.Items {
+			if _, ok := tt.Annotations[k8s.StatusAnnotation]; !ok {
+				continue
+			}
+
+			if err = c.removeStatusAnnotation(k8s.TrafficTargetObjectKind, tt.Namespace, tt.Name, func() error {
+				_, patchErr := c.clients.AccessClient().AccessV1alpha2().TrafficTargets(tt.Namespace).Patch(ctx, tt.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+				return patchErr
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeStatusAnnotation removes the status annotation of the resource of the given kind, namespace and name, with the
+// given patch function.
+func (c *Cleanup) removeStatusAnnotation(kind, namespace, name string, patch func() error) error {
+	resource := fmt.Sprintf("%s %s/%s", kind, namespace, name)
+
+	if c.dryRunOut != nil {
+		fmt.Fprintf(c.dryRunOut, "Would remove annotation %q from %s\n", k8s.StatusAnnotation, resource)
+		c.report.StatusAnnotations = append(c.report.StatusAnnotations, resource)
+
+		return nil
+	}
+
+	if err := patch(); err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("unable to remove annotation %q from %s: %w", k8s.StatusAnnotation, resource, err)
+	}
+
+	c.report.StatusAnnotations = append(c.report.StatusAnnotations, resource)
+
+	return nil
+}
+
+// Report returns the report of the artifacts removed so far.
+func (c *Cleanup) Report() *Report {
+	return &c.report
+}
@@ -1,6 +1,7 @@
 package cleanup
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"testing"
@@ -9,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/traefik/mesh/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -19,7 +21,7 @@ func TestCleanup_New(t *testing.T) {
 	logger.SetOutput(os.Stdout)
 	logger.SetLevel(logrus.DebugLevel)
 
-	cleanup := NewCleanup(logger, clientMock.KubernetesClient(), metav1.NamespaceDefault)
+	cleanup := NewCleanup(logger, clientMock, metav1.NamespaceDefault)
 	require.NotNil(t, cleanup)
 }
 
@@ -30,7 +32,7 @@ func TestCleanup_CleanShadowServices(t *testing.T) {
 	logger.SetOutput(os.Stdout)
 	logger.SetLevel(logrus.DebugLevel)
 
-	cleanup := NewCleanup(logger, clientMock.KubernetesClient(), "traefik-mesh")
+	cleanup := NewCleanup(logger, clientMock, "traefik-mesh")
 	require.NotNil(t, cleanup)
 
 	err := cleanup.CleanShadowServices(context.Background())
@@ -46,3 +48,178 @@ func TestCleanup_CleanShadowServices(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, serviceList.Items, 2)
 }
+
+func TestCleanup_CleanShadowServicesDryRun(t *testing.T) {
+	clientMock := k8s.NewClientMock("mock.yaml")
+	logger := logrus.New()
+
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.DebugLevel)
+
+	var out bytes.Buffer
+
+	cleanup := NewCleanup(logger, clientMock, "traefik-mesh", DryRun(&out))
+	require.NotNil(t, cleanup)
+
+	serviceList, err := clientMock.KubernetesClient().CoreV1().Services(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{
+		LabelSelector: "app=maesh,type=shadow",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, serviceList.Items)
+
+	err = cleanup.CleanShadowServices(context.Background())
+	require.NoError(t, err)
+
+	for _, service := range serviceList.Items {
+		assert.Contains(t, out.String(), "Would delete shadow Service "+service.Namespace+"/"+service.Name+"\n")
+	}
+
+	remaining, err := clientMock.KubernetesClient().CoreV1().Services(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{
+		LabelSelector: "app=maesh,type=shadow",
+	})
+	require.NoError(t, err)
+	assert.Len(t, remaining.Items, len(serviceList.Items))
+}
+
+func TestCleanup_CleanAll(t *testing.T) {
+	tests := []struct {
+		desc   string
+		dryRun bool
+	}{
+		{
+			desc: "Remove the artifacts",
+		},
+		{
+			desc:   "Dry-run",
+			dryRun: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			clientMock := k8s.NewClientMock("all.yaml")
+			kubeClient := clientMock.KubernetesClient()
+
+			logger := logrus.New()
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
+
+			secrets := []*corev1.Secret{
+				{ObjectMeta: metav1.ObjectMeta{Name: "traefik-mesh-ca", Namespace: "traefik-mesh", Labels: map[string]string{"app": "maesh", "type": "mesh-ca"}}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "user-ca", Namespace: "traefik-mesh"}},
+			}
+			for _, secret := range secrets {
+				_, err := kubeClient.CoreV1().Secrets(secret.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			events := []*corev1.Event{
+				{ObjectMeta: metav1.ObjectMeta{Name: "svc.1", Namespace: "ns"}, Source: corev1.EventSource{Component: k8s.EventComponent}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "pod.1", Namespace: "ns"}, Source: corev1.EventSource{Component: "kubelet"}},
+			}
+			for _, event := range events {
+				_, err := kubeClient.CoreV1().Events(event.Namespace).Create(ctx, event, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			var out bytes.Buffer
+
+			var opts []Option
+			if test.dryRun {
+				opts = append(opts, DryRun(&out))
+			}
+
+			cleanup := NewCleanup(logger, clientMock, "traefik-mesh", opts...)
+
+			require.NoError(t, cleanup.CleanDNSBackups(ctx))
+			require.NoError(t, cleanup.CleanMeshCA(ctx))
+			require.NoError(t, cleanup.CleanEvents(ctx))
+			require.NoError(t, cleanup.CleanStatusAnnotations(ctx))
+
+			assert.Equal(t, &Report{
+				DryRun:            test.dryRun,
+				DNSBackups:        []string{"dns-backup-kube-system-node-local-dns"},
+				MeshCASecrets:     []string{"traefik-mesh/traefik-mesh-ca"},
+				Events:            []string{"ns/svc.1"},
+				StatusAnnotations: []string{"TrafficSplit ns/ts", "TrafficTarget ns/tt"},
+			}, cleanup.Report())
+
+			configMaps, err := kubeClient.CoreV1().ConfigMaps("traefik-mesh").List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+
+			secretList, err := kubeClient.CoreV1().Secrets("traefik-mesh").List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+
+			eventList, err := kubeClient.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+
+			ts, err := clientMock.SplitClient().SplitV1alpha3().TrafficSplits("ns").Get(ctx, "ts", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			tt, err := clientMock.AccessClient().AccessV1alpha2().TrafficTargets("ns").Get(ctx, "tt", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			if test.dryRun {
+				assert.Len(t, configMaps.Items, 2)
+				assert.Len(t, secretList.Items, 2)
+				assert.Len(t, eventList.Items, 2)
+				assert.Contains(t, ts.Annotations, k8s.StatusAnnotation)
+				assert.Contains(t, tt.Annotations, k8s.StatusAnnotation)
+
+				assert.Contains(t, out.String(), "Would delete backup ConfigMap traefik-mesh/dns-backup-kube-system-node-local-dns\n")
+				assert.Contains(t, out.String(), "Would delete mesh CA Secret traefik-mesh/traefik-mesh-ca\n")
+				assert.Contains(t, out.String(), "Would delete Event ns/svc.1\n")
+				assert.Contains(t, out.String(), "Would remove annotation \"mesh.traefik.io/status\" from TrafficSplit ns/ts\n")
+				assert.Contains(t, out.String(), "Would remove annotation \"mesh.traefik.io/status\" from TrafficTarget ns/tt\n")
+
+				return
+			}
+
+			require.Len(t, configMaps.Items, 1)
+			assert.Equal(t, "user-config", configMaps.Items[0].Name)
+
+			require.Len(t, secretList.Items, 1)
+			assert.Equal(t, "user-ca", secretList.Items[0].Name)
+
+			require.Len(t, eventList.Items, 1)
+			assert.Equal(t, "pod.1", eventList.Items[0].Name)
+
+			assert.Equal(t, map[string]string{"foo": "bar"}, ts.Annotations)
+			assert.NotContains(t, tt.Annotations, k8s.StatusAnnotation)
+		})
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	report := &Report{
+		ShadowServices:    []string{"traefik-mesh/test1", "traefik-mesh/test2"},
+		DNSRestored:       true,
+		DNSBackups:        []string{"dns-backup-kube-system-coredns"},
+		Events:            []string{"ns/svc.1"},
+		StatusAnnotations: []string{"TrafficSplit ns/ts"},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, report.Write(&out))
+
+	expected := "Removed:\n" +
+		"  shadow Services:        2\n" +
+		"  DNS backup ConfigMaps:  1\n" +
+		"  mesh CA Secrets:        0\n" +
+		"  Events:                 1\n" +
+		"  SMI status annotations: 1\n" +
+		"DNS configuration: restored\n"
+	assert.Equal(t, expected, out.String())
+
+	report.DryRun = true
+	out.Reset()
+	require.NoError(t, report.Write(&out))
+
+	assert.Contains(t, out.String(), "Would remove:\n")
+	assert.Contains(t, out.String(), "DNS configuration: would be restored\n")
+}
@@ -0,0 +1,61 @@
+package cleanup
+
+import (
+	"fmt"
+	"io"
+)
+
+// Report is the summary of the Traefik Mesh artifacts removed from a cluster. Each artifact is identified by its
+// namespace and name.
+type Report struct {
+	// DryRun is true if the artifacts would have been removed, but were not.
+	DryRun            bool
+	ShadowServices    []string
+	DNSRestored       bool
+	DNSBackups        []string
+	MeshCASecrets     []string
+	Events            []string
+	StatusAnnotations []string
+}
+
+// Write writes the summary of the report to the given writer.
+func (r *Report) Write(w io.Writer) error {
+	verb := "Removed"
+	if r.DryRun {
+		verb = "Would remove"
+	}
+
+	lines := []struct {
+		desc  string
+		count int
+	}{
+		{desc: "shadow Services", count: len(r.ShadowServices)},
+		{desc: "DNS backup ConfigMaps", count: len(r.DNSBackups)},
+		{desc: "mesh CA Secrets", count: len(r.MeshCASecrets)},
+		{desc: "Events", count: len(r.Events)},
+		{desc: "SMI status annotations", count: len(r.StatusAnnotations)},
+	}
+
+	if _, err := fmt.Fprintln(w, verb+":"); err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(w, "  %-24s%d\n", line.desc+":", line.count); err != nil {
+			return err
+		}
+	}
+
+	dnsStatus := "not restored"
+
+	switch {
+	case r.DNSRestored && r.DryRun:
+		dnsStatus = "would be restored"
+	case r.DNSRestored:
+		dnsStatus = "restored"
+	}
+
+	_, err := fmt.Fprintf(w, "DNS configuration: %s\n", dnsStatus)
+
+	return err
+}
@@ -3,6 +3,9 @@ package controller
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,17 +18,24 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/cmd"
 	"github.com/traefik/mesh/pkg/annotations"
+	"github.com/traefik/mesh/pkg/dns"
 	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/metrics"
 	"github.com/traefik/mesh/pkg/provider"
 	"github.com/traefik/mesh/pkg/topology"
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
@@ -44,6 +54,8 @@ type SharedStore interface {
 	SetConfig(cfg *dynamic.Configuration)
 	SetTopology(topo *topology.Topology)
 	SetReadiness(isReady bool)
+	// RecordHistory records the current configuration and topology, built after processing the given work queue key.
+	RecordHistory(trigger string)
 }
 
 // TopologyBuilder builds Topologies.
@@ -53,17 +65,29 @@ type TopologyBuilder interface {
 
 // Config holds the configuration of the controller.
 type Config struct {
-	ACLEnabled       bool
-	DefaultMode      string
-	Namespace        string
-	WatchNamespaces  []string
-	IgnoreNamespaces []string
-	MinHTTPPort      int32
-	MaxHTTPPort      int32
-	MinTCPPort       int32
-	MaxTCPPort       int32
-	MinUDPPort       int32
-	MaxUDPPort       int32
+	ACLEnabled        bool
+	DefaultMode       string
+	Namespace         string
+	WatchNamespaces   []string
+	IgnoreNamespaces  []string
+	NamespaceSelector labels.Selector
+	OptIn             bool
+	TracingHeaders    bool
+	MTLS              bool
+	MTLSRootCA        string
+	MTLSTrustDomain   string
+	ACLIdentity       bool
+	ACLAuthURL        string
+	ACLAuthCA         string
+	ACLAudit          bool
+	Domains           []string
+	EmbeddedDNS       bool
+	MinHTTPPort       int32
+	MaxHTTPPort       int32
+	MinTCPPort        int32
+	MaxTCPPort        int32
+	MinUDPPort        int32
+	MaxUDPPort        int32
 }
 
 // Controller hold controller configuration.
@@ -79,7 +103,11 @@ type Controller struct {
 	tcpStateTable        *PortMapping
 	udpStateTable        *PortMapping
 	topologyBuilder      TopologyBuilder
+	eventBroadcaster     record.EventBroadcaster
+	eventRecorder        *TopologyEventRecorder
+	statusWriter         *StatusWriter
 	store                SharedStore
+	metrics              metrics.Registry
 	logger               logrus.FieldLogger
 
 	clients              k8s.Client
@@ -88,6 +116,7 @@ type Controller struct {
 	specsFactory         specsinformer.SharedInformerFactory
 	splitFactory         splitinformer.SharedInformerFactory
 	podLister            listers.PodLister
+	namespaceLister      listers.NamespaceLister
 	serviceLister        listers.ServiceLister
 	endpointsLister      listers.EndpointsLister
 	trafficTargetLister  accesslister.TrafficTargetLister
@@ -98,33 +127,49 @@ type Controller struct {
 
 // NewMeshController builds the informers and other required components of the mesh controller, and returns an
 // initialized mesh controller object.
-func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, logger logrus.FieldLogger) *Controller {
+func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, metricsRegistry metrics.Registry, logger logrus.FieldLogger) *Controller {
+	if len(cfg.Domains) == 0 {
+		cfg.Domains = dns.DefaultDomains()
+	}
+
 	c := &Controller{
 		logger:  logger,
 		cfg:     cfg,
 		clients: clients,
 		store:   store,
+		metrics: metricsRegistry,
 		stopCh:  make(chan struct{}),
 	}
 
+	c.kubernetesFactory = informers.NewSharedInformerFactoryWithOptions(c.clients.KubernetesClient(), k8s.ResyncPeriod)
+
+	// Namespaces are always watched, as their labels and annotations may enroll their services in the mesh.
+	c.namespaceLister = c.kubernetesFactory.Core().V1().Namespaces().Lister()
+
 	// Initialize the ignored and watched resources.
-	c.resourceFilter = k8s.NewResourceFilter(
+	filterOpts := []k8s.ResourceFilterOption{
 		k8s.WatchNamespaces(cfg.WatchNamespaces...),
 		k8s.IgnoreNamespaces(cfg.IgnoreNamespaces...),
 		k8s.IgnoreNamespaces(metav1.NamespaceSystem),
 		k8s.IgnoreService(metav1.NamespaceDefault, "kubernetes"),
 		k8s.IgnoreApps("maesh", "jaeger"),
-	)
+		k8s.MeshEnabledAnnotation(cfg.OptIn, c.namespaceLister),
+	}
+
+	if cfg.NamespaceSelector != nil {
+		filterOpts = append(filterOpts, k8s.WatchNamespaceSelector(cfg.NamespaceSelector, c.namespaceLister))
+	}
+
+	c.resourceFilter = k8s.NewResourceFilter(filterOpts...)
 
 	// Create the work queue and the enqueue handler.
-	c.workQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	c.workQueue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), metrics.WorkQueueName)
 	handler := cache.FilteringResourceEventHandler{
 		FilterFunc: c.isWatchedResource,
 		Handler:    &enqueueWorkHandler{logger: c.logger, workQueue: c.workQueue},
 	}
 
 	// Create SharedInformers, listers and register the event handler to informers that are not ACL related.
-	c.kubernetesFactory = informers.NewSharedInformerFactoryWithOptions(c.clients.KubernetesClient(), k8s.ResyncPeriod)
 	c.splitFactory = splitinformer.NewSharedInformerFactoryWithOptions(c.clients.SplitClient(), k8s.ResyncPeriod)
 	c.specsFactory = specsinformer.NewSharedInformerFactoryWithOptions(c.clients.SpecsClient(), k8s.ResyncPeriod)
 
@@ -141,6 +186,13 @@ func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, logger
 	c.specsFactory.Specs().V1alpha3().HTTPRouteGroups().Informer().AddEventHandler(handler)
 	c.specsFactory.Specs().V1alpha3().TCPRoutes().Informer().AddEventHandler(handler)
 
+	// Namespace label and annotation changes may add or remove all the services of a namespace from the mesh.
+	c.kubernetesFactory.Core().V1().Namespaces().Informer().AddEventHandler(&enqueueNamespaceWorkHandler{
+		logger:        c.logger,
+		workQueue:     c.workQueue,
+		serviceLister: c.serviceLister,
+	})
+
 	// Create SharedInformers, listers and register the event handler for ACL related resources.
 	if c.cfg.ACLEnabled {
 		c.accessFactory = accessinformer.NewSharedInformerFactoryWithOptions(c.clients.AccessClient(), k8s.ResyncPeriod)
@@ -178,14 +230,34 @@ func NewMeshController(clients k8s.Client, cfg Config, store SharedStore, logger
 		c.logger,
 	)
 
+	c.eventBroadcaster = record.NewBroadcaster()
+	c.eventRecorder = NewTopologyEventRecorder(
+		c.eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: k8s.EventComponent}),
+		c.serviceLister,
+		c.trafficSplitLister,
+		c.trafficTargetLister,
+		c.logger,
+	)
+
+	c.statusWriter = NewStatusWriter(c.clients, c.trafficSplitLister, c.trafficTargetLister, c.logger)
+
 	providerCfg := provider.Config{
 		MinHTTPPort:        c.cfg.MinHTTPPort,
 		MaxHTTPPort:        c.cfg.MaxHTTPPort,
 		ACL:                c.cfg.ACLEnabled,
 		DefaultTrafficType: c.cfg.DefaultMode,
+		TracingHeaders:     c.cfg.TracingHeaders,
+		MTLS:               c.cfg.MTLS,
+		MTLSRootCA:         c.cfg.MTLSRootCA,
+		MTLSTrustDomain:    c.cfg.MTLSTrustDomain,
+		ACLIdentity:        c.cfg.ACLIdentity,
+		ACLAuthURL:         c.cfg.ACLAuthURL,
+		ACLAuthCA:          c.cfg.ACLAuthCA,
+		ACLAudit:           c.cfg.ACLAudit,
+		Domains:            c.cfg.Domains,
 	}
 
-	c.provider = provider.New(c.tcpStateTable, c.udpStateTable, annotations.BuildMiddlewares, providerCfg, c.logger)
+	c.provider = provider.New(c.tcpStateTable, c.udpStateTable, annotations.BuildMiddlewares, annotations.BuildTCPMiddlewares, providerCfg, c.logger)
 
 	return c
 }
@@ -202,10 +274,17 @@ func (c *Controller) Run() error {
 		c.workQueue.ShutDown()
 
 		waitGroup.Wait()
+
+		c.eventBroadcaster.Shutdown()
 	}()
 
 	c.logger.Debug("Initializing mesh controller")
 
+	// Start sending the recorded events to the Kubernetes API.
+	c.eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: c.clients.KubernetesClient().CoreV1().Events(""),
+	})
+
 	// Start the informers.
 	if err := c.startInformers(10 * time.Second); err != nil {
 		return fmt.Errorf("could not start informers: %w", err)
@@ -216,6 +295,14 @@ func (c *Controller) Run() error {
 		return fmt.Errorf("could not load port mapper states: %w", err)
 	}
 
+	// Start writing the statuses of the SMI resources in the background.
+	waitGroup.Add(1)
+
+	go func() {
+		defer waitGroup.Done()
+		c.statusWriter.Run(c.stopCh)
+	}()
+
 	// Enable API readiness endpoint, informers are started and default conf is available.
 	c.store.SetReadiness(true)
 
@@ -271,26 +358,20 @@ func (c *Controller) startInformers(syncTimeout time.Duration) error {
 func (c *Controller) startBaseInformers(stopCh <-chan struct{}) error {
 	c.kubernetesFactory.Start(c.stopCh)
 
-	for t, ok := range c.kubernetesFactory.WaitForCacheSync(stopCh) {
-		if !ok {
-			return fmt.Errorf("timed out waiting for controller caches to sync: %s", t)
-		}
+	if err := c.checkCacheSync(c.kubernetesFactory.WaitForCacheSync(stopCh)); err != nil {
+		return err
 	}
 
 	c.splitFactory.Start(c.stopCh)
 
-	for t, ok := range c.splitFactory.WaitForCacheSync(stopCh) {
-		if !ok {
-			return fmt.Errorf("timed out waiting for controller caches to sync: %s", t)
-		}
+	if err := c.checkCacheSync(c.splitFactory.WaitForCacheSync(stopCh)); err != nil {
+		return err
 	}
 
 	c.specsFactory.Start(c.stopCh)
 
-	for t, ok := range c.specsFactory.WaitForCacheSync(stopCh) {
-		if !ok {
-			return fmt.Errorf("timed out waiting for controller caches to sync: %s", t)
-		}
+	if err := c.checkCacheSync(c.specsFactory.WaitForCacheSync(stopCh)); err != nil {
+		return err
 	}
 
 	return nil
@@ -299,12 +380,32 @@ func (c *Controller) startBaseInformers(stopCh <-chan struct{}) error {
 func (c *Controller) startACLInformers(stopCh <-chan struct{}) error {
 	c.accessFactory.Start(c.stopCh)
 
-	for t, ok := range c.accessFactory.WaitForCacheSync(stopCh) {
+	if err := c.checkCacheSync(c.accessFactory.WaitForCacheSync(stopCh)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkCacheSync records the cache sync status of the given informers and returns an error if one of them is not
+// synced.
+func (c *Controller) checkCacheSync(synced map[reflect.Type]bool) error {
+	var notSynced []string
+
+	for t, ok := range synced {
+		c.metrics.SetInformerSynced(t.String(), ok)
+
 		if !ok {
-			return fmt.Errorf("timed out waiting for controller caches to sync: %s", t)
+			notSynced = append(notSynced, t.String())
 		}
 	}
 
+	if len(notSynced) > 0 {
+		sort.Strings(notSynced)
+
+		return fmt.Errorf("timed out waiting for controller caches to sync: %s", strings.Join(notSynced, ", "))
+	}
+
 	return nil
 }
 
@@ -350,22 +451,79 @@ func (c *Controller) processNextWorkItem() bool {
 	}
 
 	// Build and store config.
+	start := time.Now()
 	topo, err := c.topologyBuilder.Build(c.resourceFilter)
+	c.metrics.ObserveTopologyBuildDuration(time.Since(start))
+
 	if err != nil {
 		c.handleErr(key, fmt.Errorf("unable to build topology: %w", err))
 		return true
 	}
 
+	c.reportHashedShadowServices(topo)
+
 	conf := c.provider.BuildConfig(topo)
 
 	c.store.SetTopology(topo)
 	c.store.SetConfig(conf)
+	c.store.RecordHistory(fmt.Sprint(key))
+
+	c.eventRecorder.Record(topo)
+	c.statusWriter.Write(topo, c.resourceFilter)
+	c.recordMetrics(topo)
 
 	c.workQueue.Forget(key)
 
 	return true
 }
 
+// reportHashedShadowServices reports an error on the Services whose shadow service name is bounded with a hash when the
+// embedded DNS server is disabled, as the CoreDNS rewrite rules cannot resolve them.
+func (c *Controller) reportHashedShadowServices(topo *topology.Topology) {
+	if c.cfg.EmbeddedDNS {
+		return
+	}
+
+	for _, svc := range topo.Services {
+		if !k8s.IsHashedShadowServiceName(c.cfg.Namespace, svc.Namespace, svc.Name) {
+			continue
+		}
+
+		shadowSvcName := k8s.ShadowServiceName(c.cfg.Namespace, svc.Namespace, svc.Name)
+		svc.AddError(fmt.Errorf("shadow service %q is named with a hash, which the CoreDNS rewrite rules cannot resolve: enable the embedded DNS server", shadowSvcName))
+	}
+}
+
+// recordMetrics records the metrics describing the given topology and the state of the TCP and UDP port mappings.
+// HTTP ports are not mapped, they are shared by all the services.
+func (c *Controller) recordMetrics(topo *topology.Topology) {
+	trafficTargets := make(map[topology.Key]struct{})
+	for svcTTKey := range topo.ServiceTrafficTargets {
+		trafficTargets[svcTTKey.TrafficTarget] = struct{}{}
+	}
+
+	c.metrics.SetTopologyResources(metrics.ResourceKindService, len(topo.Services))
+	c.metrics.SetTopologyResources(metrics.ResourceKindPod, len(topo.Pods))
+	c.metrics.SetTopologyResources(metrics.ResourceKindTrafficTarget, len(trafficTargets))
+	c.metrics.SetTopologyResources(metrics.ResourceKindTrafficSplit, len(topo.TrafficSplits))
+
+	serviceErrors := make(map[metrics.ServiceKey]int)
+
+	for svcKey, svc := range topo.Services {
+		if len(svc.Errors) > 0 {
+			serviceErrors[metrics.ServiceKey{Namespace: svcKey.Namespace, Name: svcKey.Name}] = len(svc.Errors)
+		}
+	}
+
+	c.metrics.SetServiceConfigurationErrors(serviceErrors)
+
+	used, capacity := c.tcpStateTable.Usage()
+	c.metrics.SetPortMappingUsage(annotations.ServiceTypeTCP, used, capacity)
+
+	used, capacity = c.udpStateTable.Usage()
+	c.metrics.SetPortMappingUsage(annotations.ServiceTypeUDP, used, capacity)
+}
+
 // syncShadowService calls the shadow service manager to keep the shadow service state in sync with the service events received.
 func (c *Controller) syncShadowService(key string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -385,6 +543,11 @@ func (c *Controller) syncShadowService(key string) error {
 		return err
 	}
 
+	// The service may have been enqueued while not being watched anymore, e.g. after a namespace label change.
+	if c.resourceFilter.IsIgnored(svc) {
+		return c.shadowServiceManager.Delete(ctx, namespace, name)
+	}
+
 	_, err = c.shadowServiceManager.CreateOrUpdate(ctx, svc)
 	if err != nil {
 		return err
@@ -396,6 +559,7 @@ func (c *Controller) syncShadowService(key string) error {
 // handleErr re-queues the given work key only if the maximum number of attempts is not exceeded.
 func (c *Controller) handleErr(key interface{}, err error) {
 	if c.workQueue.NumRequeues(key) < maxRetries {
+		c.metrics.IncWorkQueueRetries()
 		c.workQueue.AddRateLimited(key)
 		return
 	}
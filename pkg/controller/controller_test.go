@@ -1,14 +1,20 @@
 package controller
 
 import (
+	"context"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/metrics"
 	"github.com/traefik/mesh/pkg/topology"
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	corev1 "k8s.io/api/core/v1"
 )
 
 const (
@@ -26,6 +32,7 @@ type storeMock struct{}
 func (a *storeMock) SetConfig(cfg *dynamic.Configuration) {}
 func (a *storeMock) SetTopology(topo *topology.Topology)  {}
 func (a *storeMock) SetReadiness(isReady bool)            {}
+func (a *storeMock) RecordHistory(trigger string)         {}
 
 func TestController_NewMeshController(t *testing.T) {
 	store := &storeMock{}
@@ -47,9 +54,50 @@ func TestController_NewMeshController(t *testing.T) {
 		MaxTCPPort:       maxTCPPort,
 		MinUDPPort:       minUDPPort,
 		MaxUDPPort:       maxUDPPort,
-	}, store, log)
+	}, store, metrics.NoopRegistry{}, log)
 
 	assert.NotNil(t, controller)
+	assert.NotNil(t, controller.namespaceLister)
+}
+
+func TestController_NewMeshControllerWithOptIn(t *testing.T) {
+	store := &storeMock{}
+	clientMock := k8s.NewClientMock("optin.yaml")
+
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	// Create a new controller in opt-in mode, without namespace selector.
+	controller := NewMeshController(clientMock, Config{
+		DefaultMode: "http",
+		Namespace:   traefikMeshNamespace,
+		OptIn:       true,
+		MinHTTPPort: minHTTPPort,
+		MaxHTTPPort: maxHTTPPort,
+		MinTCPPort:  minTCPPort,
+		MaxTCPPort:  maxTCPPort,
+		MinUDPPort:  minUDPPort,
+		MaxUDPPort:  maxUDPPort,
+	}, store, metrics.NoopRegistry{}, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	controller.kubernetesFactory.Start(ctx.Done())
+
+	for typ, ok := range controller.kubernetesFactory.WaitForCacheSync(ctx.Done()) {
+		require.True(t, ok, "timed out waiting for %s cache to sync", typ)
+	}
+
+	svcA, err := controller.serviceLister.Services("enabled").Get("svc-a")
+	require.NoError(t, err)
+
+	svcB, err := controller.serviceLister.Services("not-annotated").Get("svc-b")
+	require.NoError(t, err)
+
+	assert.True(t, controller.isWatchedResource(svcA))
+	assert.False(t, controller.isWatchedResource(svcB))
 }
 
 func TestController_NewMeshControllerWithSMI(t *testing.T) {
@@ -72,7 +120,135 @@ func TestController_NewMeshControllerWithSMI(t *testing.T) {
 		MaxTCPPort:       maxTCPPort,
 		MinUDPPort:       minUDPPort,
 		MaxUDPPort:       maxUDPPort,
-	}, store, log)
+	}, store, metrics.NoopRegistry{}, log)
 
 	assert.NotNil(t, controller)
 }
+
+type registryMock struct {
+	metrics.NoopRegistry
+
+	topologyResources          map[string]int
+	serviceConfigurationErrors map[metrics.ServiceKey]int
+	portMappingUsage           map[string][2]int
+	informerSynced             map[string]bool
+}
+
+func newRegistryMock() *registryMock {
+	return &registryMock{
+		topologyResources: make(map[string]int),
+		portMappingUsage:  make(map[string][2]int),
+		informerSynced:    make(map[string]bool),
+	}
+}
+
+func (r *registryMock) SetTopologyResources(kind string, count int) {
+	r.topologyResources[kind] = count
+}
+
+func (r *registryMock) SetServiceConfigurationErrors(errs map[metrics.ServiceKey]int) {
+	r.serviceConfigurationErrors = errs
+}
+
+func (r *registryMock) SetPortMappingUsage(protocol string, used, capacity int) {
+	r.portMappingUsage[protocol] = [2]int{used, capacity}
+}
+
+func (r *registryMock) SetInformerSynced(informer string, synced bool) {
+	r.informerSynced[informer] = synced
+}
+
+func TestController_recordMetrics(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	registry := newRegistryMock()
+
+	tcpStateTable := NewPortMapping(traefikMeshNamespace, nil, log, minTCPPort, maxTCPPort)
+	tcpStateTable.table[minTCPPort] = &servicePort{Namespace: "ns", Name: "svc-1", Port: 8080}
+
+	c := &Controller{
+		metrics:       registry,
+		tcpStateTable: tcpStateTable,
+		udpStateTable: NewPortMapping(traefikMeshNamespace, nil, log, minUDPPort, maxUDPPort),
+	}
+
+	svc1Key := topology.Key{Name: "svc-1", Namespace: "ns"}
+	svc2Key := topology.Key{Name: "svc-2", Namespace: "ns"}
+	ttKey := topology.Key{Name: "tt", Namespace: "ns"}
+
+	topo := topology.NewTopology()
+	topo.Services[svc1Key] = &topology.Service{Name: svc1Key.Name, Namespace: svc1Key.Namespace, Errors: []string{"error 1", "error 2"}}
+	topo.Services[svc2Key] = &topology.Service{Name: svc2Key.Name, Namespace: svc2Key.Namespace}
+	topo.Pods[topology.Key{Name: "pod", Namespace: "ns"}] = &topology.Pod{Name: "pod", Namespace: "ns"}
+	topo.ServiceTrafficTargets[topology.ServiceTrafficTargetKey{Service: svc1Key, TrafficTarget: ttKey}] = &topology.ServiceTrafficTarget{}
+	topo.ServiceTrafficTargets[topology.ServiceTrafficTargetKey{Service: svc2Key, TrafficTarget: ttKey}] = &topology.ServiceTrafficTarget{}
+
+	c.recordMetrics(topo)
+
+	assert.Equal(t, map[string]int{
+		metrics.ResourceKindService:       2,
+		metrics.ResourceKindPod:           1,
+		metrics.ResourceKindTrafficTarget: 1,
+		metrics.ResourceKindTrafficSplit:  0,
+	}, registry.topologyResources)
+	assert.Equal(t, map[metrics.ServiceKey]int{{Namespace: "ns", Name: "svc-1"}: 2}, registry.serviceConfigurationErrors)
+	assert.Equal(t, map[string][2]int{
+		"tcp": {1, 6},
+		"udp": {0, 6},
+	}, registry.portMappingUsage)
+}
+
+func TestController_reportHashedShadowServices(t *testing.T) {
+	longName := strings.Repeat("a", 63)
+
+	tests := []struct {
+		desc        string
+		embeddedDNS bool
+		wantErrors  int
+	}{
+		{
+			desc:       "CoreDNS rewrite rules",
+			wantErrors: 1,
+		},
+		{
+			desc:        "embedded DNS server",
+			embeddedDNS: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := &Controller{cfg: Config{Namespace: traefikMeshNamespace, EmbeddedDNS: test.embeddedDNS}}
+
+			shortKey := topology.Key{Name: "svc", Namespace: "ns"}
+			longKey := topology.Key{Name: longName, Namespace: "ns"}
+
+			topo := topology.NewTopology()
+			topo.Services[shortKey] = &topology.Service{Name: shortKey.Name, Namespace: shortKey.Namespace}
+			topo.Services[longKey] = &topology.Service{Name: longKey.Name, Namespace: longKey.Namespace}
+
+			c.reportHashedShadowServices(topo)
+
+			assert.Empty(t, topo.Services[shortKey].Errors)
+			assert.Len(t, topo.Services[longKey].Errors, test.wantErrors)
+		})
+	}
+}
+
+func TestController_checkCacheSync(t *testing.T) {
+	registry := newRegistryMock()
+	c := &Controller{metrics: registry}
+
+	err := c.checkCacheSync(map[reflect.Type]bool{
+		reflect.TypeOf(&corev1.Service{}): true,
+		reflect.TypeOf(&corev1.Pod{}):     false,
+	})
+	assert.EqualError(t, err, "timed out waiting for controller caches to sync: *v1.Pod")
+
+	assert.Equal(t, map[string]bool{"*v1.Service": true, "*v1.Pod": false}, registry.informerSynced)
+}
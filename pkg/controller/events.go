@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
+	split "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/split/v1alpha3"
+	accesslister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/access/listers/access/v1alpha2"
+	splitlister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/split/listers/split/v1alpha3"
+	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/topology"
+	corev1 "k8s.io/api/core/v1"
+	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// eventReasonConfigurationError is the reason of the events emitted for resources having configuration errors.
+	eventReasonConfigurationError = "ConfigurationError"
+
+	// eventRecordInterval is the minimum duration between two identical events emitted for the same resource.
+	eventRecordInterval = 10 * time.Minute
+)
+
+// TopologyEventRecorder emits Kubernetes Events for the configuration errors reported on the resources of a topology.
+// As the topology is rebuilt on every change, an error is emitted only once per eventRecordInterval as long as
+// it is reported on the resource.
+type TopologyEventRecorder struct {
+	recorder            record.EventRecorder
+	serviceLister       listers.ServiceLister
+	trafficSplitLister  splitlister.TrafficSplitLister
+	trafficTargetLister accesslister.TrafficTargetLister
+	logger              logrus.FieldLogger
+
+	now     func() time.Time
+	emitted map[string]time.Time
+}
+
+// NewTopologyEventRecorder creates and returns a new TopologyEventRecorder instance.
+func NewTopologyEventRecorder(
+	recorder record.EventRecorder,
+	serviceLister listers.ServiceLister,
+	trafficSplitLister splitlister.TrafficSplitLister,
+	trafficTargetLister accesslister.TrafficTargetLister,
+	logger logrus.FieldLogger,
+) *TopologyEventRecorder {
+	return &TopologyEventRecorder{
+		recorder:            recorder,
+		serviceLister:       serviceLister,
+		trafficSplitLister:  trafficSplitLister,
+		trafficTargetLister: trafficTargetLister,
+		logger:              logger,
+		now:                 time.Now,
+		emitted:             make(map[string]time.Time),
+	}
+}
+
+// Record emits an event for each error reported on the Services, TrafficSplits and TrafficTargets of the given
+// topology.
+func (r *TopologyEventRecorder) Record(topo *topology.Topology) {
+	reported := make(map[string]struct{})
+
+	for svcKey, svc := range topo.Services {
+		if len(svc.Errors) == 0 {
+			continue
+		}
+
+		ref, err := r.getServiceReference(svcKey)
+		if err != nil {
+			r.logger.Debugf("Unable to record events for Service %q: %v", svcKey, err)
+			continue
+		}
+
+		r.recordErrors(ref, svc.Errors, reported)
+	}
+
+	for tsKey, ts := range topo.TrafficSplits {
+		if len(ts.Errors) == 0 {
+			continue
+		}
+
+		ref, err := r.getTrafficSplitReference(tsKey)
+		if err != nil {
+			r.logger.Debugf("Unable to record events for TrafficSplit %q: %v", tsKey, err)
+			continue
+		}
+
+		r.recordErrors(ref, ts.Errors, reported)
+	}
+
+	for svcTTKey, stt := range topo.ServiceTrafficTargets {
+		if len(stt.Errors) == 0 {
+			continue
+		}
+
+		ref, err := r.getTrafficTargetReference(svcTTKey.TrafficTarget)
+		if err != nil {
+			r.logger.Debugf("Unable to record events for TrafficTarget %q: %v", svcTTKey.TrafficTarget, err)
+			continue
+		}
+
+		r.recordErrors(ref, stt.Errors, reported)
+	}
+
+	// Forget about the errors which are not reported anymore, this way they will be emitted again as soon as they
+	// come back.
+	for key := range r.emitted {
+		if _, ok := reported[key]; !ok {
+			delete(r.emitted, key)
+		}
+	}
+}
+
+func (r *TopologyEventRecorder) recordErrors(ref *corev1.ObjectReference, errs []string, reported map[string]struct{}) {
+	now := r.now()
+
+	for _, msg := range errs {
+		key := fmt.Sprintf("%s/%s/%s:%s", ref.Kind, ref.Namespace, ref.Name, msg)
+
+		if _, ok := reported[key]; ok {
+			continue
+		}
+
+		reported[key] = struct{}{}
+
+		if lastEmitted, ok := r.emitted[key]; ok && now.Sub(lastEmitted) < eventRecordInterval {
+			continue
+		}
+
+		r.emitted[key] = now
+		r.recorder.Event(ref, corev1.EventTypeWarning, eventReasonConfigurationError, msg)
+	}
+}
+
+func (r *TopologyEventRecorder) getServiceReference(key topology.Key) (*corev1.ObjectReference, error) {
+	svc, err := r.serviceLister.Services(key.Namespace).Get(key.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ObjectReference{
+		APIVersion:      corev1.SchemeGroupVersion.String(),
+		Kind:            "Service",
+		Namespace:       svc.Namespace,
+		Name:            svc.Name,
+		UID:             svc.UID,
+		ResourceVersion: svc.ResourceVersion,
+	}, nil
+}
+
+func (r *TopologyEventRecorder) getTrafficSplitReference(key topology.Key) (*corev1.ObjectReference, error) {
+	ts, err := r.trafficSplitLister.TrafficSplits(key.Namespace).Get(key.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ObjectReference{
+		APIVersion:      split.SchemeGroupVersion.String(),
+		Kind:            k8s.TrafficSplitObjectKind,
+		Namespace:       ts.Namespace,
+		Name:            ts.Name,
+		UID:             ts.UID,
+		ResourceVersion: ts.ResourceVersion,
+	}, nil
+}
+
+func (r *TopologyEventRecorder) getTrafficTargetReference(key topology.Key) (*corev1.ObjectReference, error) {
+	if r.trafficTargetLister == nil {
+		return nil, errors.New("TrafficTargets are not watched")
+	}
+
+	tt, err := r.trafficTargetLister.TrafficTargets(key.Namespace).Get(key.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ObjectReference{
+		APIVersion:      access.SchemeGroupVersion.String(),
+		Kind:            k8s.TrafficTargetObjectKind,
+		Namespace:       tt.Namespace,
+		Name:            tt.Name,
+		UID:             tt.UID,
+		ResourceVersion: tt.ResourceVersion,
+	}, nil
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
+	split "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/split/v1alpha3"
+	accesslister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/access/listers/access/v1alpha2"
+	splitlister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/split/listers/split/v1alpha3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/topology"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestTopologyEventRecorder_Record(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	svcKey := topology.Key{Name: "svc", Namespace: "ns"}
+	tsKey := topology.Key{Name: "ts", Namespace: "ns"}
+	ttKey := topology.Key{Name: "tt", Namespace: "ns"}
+
+	fakeRecorder := record.NewFakeRecorder(10)
+	recorder := NewTopologyEventRecorder(
+		fakeRecorder,
+		listers.NewServiceLister(newIndexer(t, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: svcKey.Name, Namespace: svcKey.Namespace}})),
+		splitlister.NewTrafficSplitLister(newIndexer(t, &split.TrafficSplit{ObjectMeta: metav1.ObjectMeta{Name: tsKey.Name, Namespace: tsKey.Namespace}})),
+		accesslister.NewTrafficTargetLister(newIndexer(t, &access.TrafficTarget{ObjectMeta: metav1.ObjectMeta{Name: ttKey.Name, Namespace: ttKey.Namespace}})),
+		log,
+	)
+
+	now := time.Now()
+	recorder.now = func() time.Time { return now }
+
+	topo := topology.NewTopology()
+	topo.Services[svcKey] = &topology.Service{Name: svcKey.Name, Namespace: svcKey.Namespace, Errors: []string{"svc error"}}
+	topo.TrafficSplits[tsKey] = &topology.TrafficSplit{Name: tsKey.Name, Namespace: tsKey.Namespace, Errors: []string{"ts error"}}
+	topo.ServiceTrafficTargets[topology.ServiceTrafficTargetKey{Service: svcKey, TrafficTarget: ttKey}] = &topology.ServiceTrafficTarget{
+		Name:      ttKey.Name,
+		Namespace: ttKey.Namespace,
+		Service:   svcKey,
+		Errors:    []string{"tt error", "tt error"},
+	}
+	topo.Services[topology.Key{Name: "unknown", Namespace: "ns"}] = &topology.Service{Name: "unknown", Namespace: "ns", Errors: []string{"unknown error"}}
+
+	recorder.Record(topo)
+
+	assert.ElementsMatch(t, []string{
+		"Warning ConfigurationError svc error",
+		"Warning ConfigurationError ts error",
+		"Warning ConfigurationError tt error",
+	}, drainEvents(fakeRecorder))
+
+	// The same errors must not be emitted again on the next rebuild.
+	recorder.Record(topo)
+
+	assert.Empty(t, drainEvents(fakeRecorder))
+
+	// Once the interval has elapsed, the errors still reported are emitted again.
+	now = now.Add(eventRecordInterval)
+	topo.TrafficSplits[tsKey].Errors = nil
+
+	recorder.Record(topo)
+
+	assert.ElementsMatch(t, []string{
+		"Warning ConfigurationError svc error",
+		"Warning ConfigurationError tt error",
+	}, drainEvents(fakeRecorder))
+
+	// An error which disappeared is emitted again as soon as it comes back.
+	topo.TrafficSplits[tsKey].Errors = []string{"ts error"}
+
+	recorder.Record(topo)
+
+	assert.Equal(t, []string{"Warning ConfigurationError ts error"}, drainEvents(fakeRecorder))
+}
+
+func newIndexer(t *testing.T, objects ...interface{}) cache.Indexer {
+	t.Helper()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+
+	for _, object := range objects {
+		require.NoError(t, indexer.Add(object))
+	}
+
+	return indexer
+}
+
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
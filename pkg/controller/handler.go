@@ -1,9 +1,17 @@
 package controller
 
 import (
+	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
+	split "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/split/v1alpha3"
 	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/pkg/k8s"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -28,6 +36,11 @@ func (h *enqueueWorkHandler) OnUpdate(oldObj interface{}, newObj interface{}) {
 		return
 	}
 
+	// The status written by the controller doesn't change the topology.
+	if isStatusUpdate(oldObj, newObj) {
+		return
+	}
+
 	h.enqueueWork(newObj)
 }
 
@@ -50,3 +63,87 @@ func (h *enqueueWorkHandler) enqueueWork(obj interface{}) {
 
 	h.workQueue.Add(key)
 }
+
+// isStatusUpdate returns true if the given objects are TrafficSplits or TrafficTargets which only differ by their
+// status annotation.
+func isStatusUpdate(oldObj, newObj interface{}) bool {
+	switch oldObj.(type) {
+	case *split.TrafficSplit, *access.TrafficTarget:
+	default:
+		return false
+	}
+
+	oldRuntimeObj, okOld := oldObj.(runtime.Object)
+	newRuntimeObj, okNew := newObj.(runtime.Object)
+
+	if !okOld || !okNew {
+		return false
+	}
+
+	oldCopy := oldRuntimeObj.DeepCopyObject()
+	newCopy := newRuntimeObj.DeepCopyObject()
+
+	for _, obj := range []runtime.Object{oldCopy, newCopy} {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return false
+		}
+
+		annotations := accessor.GetAnnotations()
+		delete(annotations, k8s.StatusAnnotation)
+
+		accessor.SetAnnotations(annotations)
+		accessor.SetResourceVersion("")
+		accessor.SetManagedFields(nil)
+	}
+
+	return equality.Semantic.DeepEqual(oldCopy, newCopy)
+}
+
+// enqueueNamespaceWorkHandler enqueues the services of a namespace when its labels or annotations change, as the
+// namespace may start or stop being selected by the namespace selector, or opt in or out of the mesh.
+type enqueueNamespaceWorkHandler struct {
+	logger        logrus.FieldLogger
+	workQueue     workqueue.RateLimitingInterface
+	serviceLister listers.ServiceLister
+}
+
+// OnAdd is called when a namespace is added to the informers cache. There is nothing to do as a new namespace
+// doesn't contain any service yet.
+func (h *enqueueNamespaceWorkHandler) OnAdd(_ interface{}) {}
+
+// OnUpdate is called when a namespace is updated in the informers cache.
+func (h *enqueueNamespaceWorkHandler) OnUpdate(oldObj interface{}, newObj interface{}) {
+	oldNamespace, okOld := oldObj.(*corev1.Namespace)
+	newNamespace, okNew := newObj.(*corev1.Namespace)
+
+	if !okOld || !okNew {
+		return
+	}
+
+	if labels.Equals(oldNamespace.Labels, newNamespace.Labels) && labels.Equals(oldNamespace.Annotations, newNamespace.Annotations) {
+		return
+	}
+
+	services, err := h.serviceLister.Services(newNamespace.Name).List(labels.Everything())
+	if err != nil {
+		h.logger.Errorf("Unable to list services in namespace %q: %v", newNamespace.Name, err)
+		return
+	}
+
+	for _, svc := range services {
+		key, err := cache.MetaNamespaceKeyFunc(svc)
+		if err != nil {
+			h.logger.Errorf("Unable to create a work key for resource %#v", svc)
+			continue
+		}
+
+		h.workQueue.Add(key)
+	}
+
+	h.workQueue.Add(configRefreshKey)
+}
+
+// OnDelete is called when a namespace is removed from the informers cache. There is nothing to do as the deletion
+// of the namespace services is handled by the service informer.
+func (h *enqueueNamespaceWorkHandler) OnDelete(_ interface{}) {}
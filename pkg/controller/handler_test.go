@@ -4,10 +4,14 @@ import (
 	"os"
 	"testing"
 
+	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
+	split "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/split/v1alpha3"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/traefik/mesh/pkg/k8s"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/util/workqueue"
 )
 
@@ -72,6 +76,36 @@ func TestEnqueueWorkHandler_OnUpdate(t *testing.T) {
 			},
 			expectedLen: 1,
 		},
+		{
+			desc: "should not enqueue if only the status annotation of a TrafficSplit has changed",
+			oldObj: &split.TrafficSplit{
+				ObjectMeta: metav1.ObjectMeta{ResourceVersion: "foo", Annotations: map[string]string{"foo": "bar"}},
+				Spec:       split.TrafficSplitSpec{Service: "svc"},
+			},
+			newObj: &split.TrafficSplit{
+				ObjectMeta: metav1.ObjectMeta{
+					ResourceVersion: "bar",
+					Annotations:     map[string]string{"foo": "bar", k8s.StatusAnnotation: `{"accepted":true,"pods":1}`},
+				},
+				Spec: split.TrafficSplitSpec{Service: "svc"},
+			},
+			expectedLen: 0,
+		},
+		{
+			desc: "should enqueue if the spec of a TrafficTarget has changed along with its status annotation",
+			oldObj: &access.TrafficTarget{
+				ObjectMeta: metav1.ObjectMeta{ResourceVersion: "foo"},
+				Spec:       access.TrafficTargetSpec{Destination: access.IdentityBindingSubject{Kind: "ServiceAccount", Name: "a"}},
+			},
+			newObj: &access.TrafficTarget{
+				ObjectMeta: metav1.ObjectMeta{
+					ResourceVersion: "bar",
+					Annotations:     map[string]string{k8s.StatusAnnotation: `{"accepted":true,"pods":1}`},
+				},
+				Spec: access.TrafficTargetSpec{Destination: access.IdentityBindingSubject{Kind: "ServiceAccount", Name: "b"}},
+			},
+			expectedLen: 1,
+		},
 	}
 
 	for _, test := range tests {
@@ -141,3 +175,73 @@ func TestEnqueueWorkHandler_enqueueWork(t *testing.T) {
 		})
 	}
 }
+
+func TestEnqueueNamespaceWorkHandler_OnUpdate(t *testing.T) {
+	tests := []struct {
+		desc         string
+		oldObj       interface{}
+		newObj       interface{}
+		expectedKeys []interface{}
+	}{
+		{
+			desc: "should not enqueue if the namespace labels didn't change",
+			oldObj: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: map[string]string{"mesh.traefik.io/enabled": "true"}, ResourceVersion: "1"},
+			},
+			newObj: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: map[string]string{"mesh.traefik.io/enabled": "true"}, ResourceVersion: "2"},
+			},
+		},
+		{
+			desc: "should enqueue the namespace services and a refresh key if the namespace labels changed",
+			oldObj: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: map[string]string{"mesh.traefik.io/enabled": "true"}},
+			},
+			newObj: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: map[string]string{"mesh.traefik.io/enabled": "false"}},
+			},
+			expectedKeys: []interface{}{"foo/svc-1", "foo/svc-2", configRefreshKey},
+		},
+		{
+			desc: "should enqueue the namespace services and a refresh key if the namespace annotations changed",
+			oldObj: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+			},
+			newObj: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Annotations: map[string]string{"mesh.traefik.io/enabled": "false"}},
+			},
+			expectedKeys: []interface{}{"foo/svc-1", "foo/svc-2", configRefreshKey},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			workQueue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+
+			serviceLister := listers.NewServiceLister(newIndexer(t,
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc-1", Namespace: "foo"}},
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc-2", Namespace: "foo"}},
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc-3", Namespace: "bar"}},
+			))
+
+			handler := &enqueueNamespaceWorkHandler{logger: log, workQueue: workQueue, serviceLister: serviceLister}
+			handler.OnUpdate(test.oldObj, test.newObj)
+
+			var keys []interface{}
+
+			for workQueue.Len() > 0 {
+				key, _ := workQueue.Get()
+				keys = append(keys, key)
+			}
+
+			assert.ElementsMatch(t, test.expectedKeys, keys)
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"sync"
 
 	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	listers "k8s.io/client-go/listers/core/v1"
 )
@@ -61,9 +63,9 @@ func (p *PortMapping) LoadState() error {
 	defer p.mu.Unlock()
 
 	for _, shadowService := range shadowServices {
-		namespace, name, err := p.parseServiceNamespaceAndName(shadowService.Name)
+		namespace, name, err := p.getServiceNamespaceAndName(shadowService)
 		if err != nil {
-			p.logger.Error("Unable to parse shadow service shadowSvcName %q: %v", shadowService.Name, err)
+			p.logger.Errorf("Unable to get the service of shadow service %q: %v", shadowService.Name, err)
 			continue
 		}
 
@@ -135,7 +137,26 @@ func (p *PortMapping) Remove(namespace, name string, port int32) (int32, error)
 	return port, nil
 }
 
-// parseServiceNamespaceAndName parses and returns the service namespace and shadowSvcName from the given shadow service shadowSvcName.
+// Usage returns the number of mapped ports and the total number of ports which can be mapped.
+func (p *PortMapping) Usage() (used int, capacity int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.table), int(p.maxPort - p.minPort + 1)
+}
+
+// getServiceNamespaceAndName returns the namespace and the name of the service the given shadow service is created for.
+// They are read from the shadow service labels, or parsed from its name if it has been created by a version without
+// these labels, and not updated since.
+func (p *PortMapping) getServiceNamespaceAndName(shadowService *corev1.Service) (namespace string, name string, err error) {
+	if namespace, name, ok := k8s.ShadowServiceSource(shadowService); ok {
+		return namespace, name, nil
+	}
+
+	return p.parseServiceNamespaceAndName(shadowService.Name)
+}
+
+// parseServiceNamespaceAndName parses and returns the service namespace and name from the given shadow service name.
 func (p *PortMapping) parseServiceNamespaceAndName(shadowServiceName string) (namespace string, name string, err error) {
 	expr := fmt.Sprintf(`%s-(.*)-6d61657368-(.*)`, p.namespace)
 
@@ -146,7 +167,7 @@ func (p *PortMapping) parseServiceNamespaceAndName(shadowServiceName string) (na
 
 	parts := regex.FindStringSubmatch(shadowServiceName)
 	if len(parts) != 3 {
-		return "", "", fmt.Errorf("unable to parse service namespace and shadowSvcName")
+		return "", "", fmt.Errorf("unable to parse service namespace and name")
 	}
 
 	return parts[2], parts[1], nil
@@ -126,6 +126,27 @@ func TestPortMapping_Remove(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestPortMapping_Usage(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	serviceLister, err := newFakeServiceLister()
+	require.NoError(t, err)
+
+	p := NewPortMapping("traefik-mesh", serviceLister, logger, 10000, 10009)
+
+	used, capacity := p.Usage()
+	assert.Equal(t, 0, used)
+	assert.Equal(t, 10, capacity)
+
+	p.table[10000] = &servicePort{Namespace: "my-ns", Name: "my-app", Port: 9090}
+	p.table[10001] = &servicePort{Namespace: "my-ns", Name: "my-app", Port: 9091}
+
+	used, capacity = p.Usage()
+	assert.Equal(t, 2, used)
+	assert.Equal(t, 10, capacity)
+}
+
 func TestPortMapping_LoadState(t *testing.T) {
 	tests := []struct {
 		desc     string
@@ -149,6 +170,18 @@ func TestPortMapping_LoadState(t *testing.T) {
 				}),
 			},
 		},
+		{
+			desc:     "should read the service of the shadow services from their labels",
+			expPorts: []int32{10000},
+			services: []runtime.Object{
+				newLabeledShadowService("traefik-mesh-a-very-long-service-name-which-do-dbe96ab75db52e9a",
+					"bar", "a-very-long-service-name-which-does-not-fit-in-a-shadow-name",
+					corev1.ServicePort{
+						Port:       80,
+						TargetPort: intstr.FromInt(10000),
+					}),
+			},
+		},
 		{
 			desc:     "should ignore the shadow service ports with an out of range target port",
 			expPorts: []int32{10001},
@@ -213,24 +246,36 @@ func TestPortMapping_LoadState(t *testing.T) {
 	}
 }
 
-func TestPortMapping_parseServiceNamespaceAndName(t *testing.T) {
+func TestPortMapping_getServiceNamespaceAndName(t *testing.T) {
 	tests := []struct {
-		desc          string
-		shadowSvcName string
-		expErr        bool
-		expNamespace  string
-		expName       string
+		desc         string
+		shadowSvc    *corev1.Service
+		expErr       bool
+		expNamespace string
+		expName      string
 	}{
 		{
-			desc:          "should return an error if the shadow service name is malformed",
-			shadowSvcName: "foo",
-			expErr:        true,
+			desc:      "should return an error if the shadow service has no labels and its name is malformed",
+			shadowSvc: newShadowService("foo"),
+			expErr:    true,
 		},
 		{
-			desc:          "should return the parsed service namespace and name from the shadow service name",
-			shadowSvcName: "traefik-mesh-foo-6d61657368-default",
-			expNamespace:  "default",
-			expName:       "foo",
+			desc:         "should return the service namespace and name from the shadow service labels",
+			shadowSvc:    newLabeledShadowService("traefik-mesh-foo-6d61657368-default", "default", "foo"),
+			expNamespace: "default",
+			expName:      "foo",
+		},
+		{
+			desc:         "should return the service namespace and name from the labels of a shadow service with a bounded name",
+			shadowSvc:    newLabeledShadowService("traefik-mesh-a-very-long-service-name-which-do-dbe96ab75db52e9a", "bar", "a-very-long-service-name-which-does-not-fit-in-a-shadow-name"),
+			expNamespace: "bar",
+			expName:      "a-very-long-service-name-which-does-not-fit-in-a-shadow-name",
+		},
+		{
+			desc:         "should return the parsed service namespace and name from the name of a shadow service without labels",
+			shadowSvc:    newShadowService("traefik-mesh-foo-6d61657368-default"),
+			expNamespace: "default",
+			expName:      "foo",
 		},
 	}
 
@@ -247,7 +292,7 @@ func TestPortMapping_parseServiceNamespaceAndName(t *testing.T) {
 
 			portMapping := NewPortMapping("traefik-mesh", serviceLister, logger, 10000, 10005)
 
-			namespace, name, err := portMapping.parseServiceNamespaceAndName(test.shadowSvcName)
+			namespace, name, err := portMapping.getServiceNamespaceAndName(test.shadowSvc)
 			if test.expErr {
 				require.Error(t, err)
 				return
@@ -298,3 +343,11 @@ func newShadowService(name string, ports ...corev1.ServicePort) *corev1.Service
 		},
 	}
 }
+
+func newLabeledShadowService(name, svcNamespace, svcName string, ports ...corev1.ServicePort) *corev1.Service {
+	shadowSvc := newShadowService(name, ports...)
+	shadowSvc.Labels["mesh.traefik.io/service-namespace"] = svcNamespace
+	shadowSvc.Labels["mesh.traefik.io/service-name"] = svcName
+
+	return shadowSvc
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/pkg/annotations"
+	"github.com/traefik/mesh/pkg/k8s"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -73,10 +74,7 @@ func (s *ShadowServiceManager) CreateOrUpdate(ctx context.Context, svc *corev1.S
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      shadowSvcName,
 			Namespace: s.namespace,
-			Labels: map[string]string{
-				"app":  "maesh",
-				"type": "shadow",
-			},
+			Labels:    k8s.ShadowServiceLabels(svc.Namespace, svc.Name),
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: ports,
@@ -86,6 +84,8 @@ func (s *ShadowServiceManager) CreateOrUpdate(ctx context.Context, svc *corev1.S
 		},
 	}
 
+	setIPFamilies(newShadowSvc, svc)
+
 	if shadowSvc == nil {
 		return s.kubeClient.CoreV1().Services(s.namespace).Create(ctx, newShadowSvc, metav1.CreateOptions{})
 	}
@@ -97,9 +97,36 @@ func (s *ShadowServiceManager) CreateOrUpdate(ctx context.Context, svc *corev1.S
 	shadowSvc = shadowSvc.DeepCopy()
 	shadowSvc.Spec.Ports = newShadowSvc.Spec.Ports
 
+	// Shadow services created by previous versions don't have the labels holding the service namespace and name.
+	if shadowSvc.Labels == nil {
+		shadowSvc.Labels = make(map[string]string)
+	}
+
+	for key, value := range newShadowSvc.Labels {
+		shadowSvc.Labels[key] = value
+	}
+
+	setIPFamilies(shadowSvc, svc)
+
 	return s.kubeClient.CoreV1().Services(s.namespace).Update(ctx, shadowSvc, metav1.UpdateOptions{})
 }
 
+// setIPFamilies makes the given shadow service use the IP families of the given service, so that the service is
+// reachable through the mesh over each of them on dual-stack clusters. As the primary IP family of a service can't be
+// changed, the IP families are left untouched if the primary one differs.
+func setIPFamilies(shadowSvc, svc *corev1.Service) {
+	if svc.Spec.IPFamilyPolicy == nil {
+		return
+	}
+
+	if len(shadowSvc.Spec.IPFamilies) > 0 && len(svc.Spec.IPFamilies) > 0 && shadowSvc.Spec.IPFamilies[0] != svc.Spec.IPFamilies[0] {
+		return
+	}
+
+	shadowSvc.Spec.IPFamilyPolicy = svc.Spec.IPFamilyPolicy
+	shadowSvc.Spec.IPFamilies = svc.Spec.IPFamilies
+}
+
 // Delete deletes the shadow service associated with the given service.
 func (s *ShadowServiceManager) Delete(ctx context.Context, namespace, name string) error {
 	shadowSvcName := s.getShadowServiceName(namespace, name)
@@ -165,9 +192,9 @@ func (s *ShadowServiceManager) removeServicePortMapping(namespace, name string,
 	}
 }
 
-// getShadowServiceName returns the shadow service shadowSvcName corresponding to the given service shadowSvcName and namespace.
+// getShadowServiceName returns the shadow service name corresponding to the given service name and namespace.
 func (s *ShadowServiceManager) getShadowServiceName(namespace, name string) string {
-	return fmt.Sprintf("%s-%s-6d61657368-%s", s.namespace, name, namespace)
+	return k8s.ShadowServiceName(s.namespace, namespace, name)
 }
 
 func (s *ShadowServiceManager) getShadowServicePorts(svc *corev1.Service) ([]corev1.ServicePort, error) {
@@ -80,6 +80,12 @@ func TestShadowServiceManager_CreateOrUpdate(t *testing.T) {
 				ObjectMeta: v1.ObjectMeta{
 					Name:      "traefik-mesh-foo-6d61657368-bar",
 					Namespace: "traefik-mesh",
+					Labels: map[string]string{
+						"app":                               "maesh",
+						"type":                              "shadow",
+						"mesh.traefik.io/service-namespace": "bar",
+						"mesh.traefik.io/service-name":      "foo",
+					},
 				},
 				Spec: corev1.ServiceSpec{
 					Ports: []corev1.ServicePort{
@@ -114,6 +120,51 @@ func TestShadowServiceManager_CreateOrUpdate(t *testing.T) {
 				ObjectMeta: v1.ObjectMeta{
 					Name:      "traefik-mesh-foo-6d61657368-bar",
 					Namespace: "traefik-mesh",
+					Labels: map[string]string{
+						"app":                               "maesh",
+						"type":                              "shadow",
+						"mesh.traefik.io/service-namespace": "bar",
+						"mesh.traefik.io/service-name":      "foo",
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{
+						{
+							Protocol:   corev1.ProtocolTCP,
+							Port:       8080,
+							TargetPort: intstr.FromInt(10000),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc:        "should create a shadow service with a bounded name for a long service name",
+			defaultMode: "tcp",
+			svc: &corev1.Service{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "a-very-long-service-name-which-does-not-fit-in-a-shadow-name",
+					Namespace: "bar",
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{
+						{
+							Protocol: corev1.ProtocolTCP,
+							Port:     8080,
+						},
+					},
+				},
+			},
+			expectedShadowSvc: &corev1.Service{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "traefik-mesh-a-very-long-service-name-which-do-dbe96ab75db52e9a",
+					Namespace: "traefik-mesh",
+					Labels: map[string]string{
+						"app":                               "maesh",
+						"type":                              "shadow",
+						"mesh.traefik.io/service-namespace": "bar",
+						"mesh.traefik.io/service-name":      "a-very-long-service-name-which-does-not-fit-in-a-shadow-name",
+					},
 				},
 				Spec: corev1.ServiceSpec{
 					Ports: []corev1.ServicePort{
@@ -164,6 +215,12 @@ func TestShadowServiceManager_CreateOrUpdate(t *testing.T) {
 				ObjectMeta: v1.ObjectMeta{
 					Name:      "traefik-mesh-foo-6d61657368-bar",
 					Namespace: "traefik-mesh",
+					Labels: map[string]string{
+						"app":                               "maesh",
+						"type":                              "shadow",
+						"mesh.traefik.io/service-namespace": "bar",
+						"mesh.traefik.io/service-name":      "foo",
+					},
 				},
 				Spec: corev1.ServiceSpec{
 					Ports: []corev1.ServicePort{
@@ -209,6 +266,12 @@ func TestShadowServiceManager_CreateOrUpdate(t *testing.T) {
 				ObjectMeta: v1.ObjectMeta{
 					Name:      "traefik-mesh-foo-6d61657368-bar",
 					Namespace: "traefik-mesh",
+					Labels: map[string]string{
+						"app":                               "maesh",
+						"type":                              "shadow",
+						"mesh.traefik.io/service-namespace": "bar",
+						"mesh.traefik.io/service-name":      "foo",
+					},
 				},
 				Spec: corev1.ServiceSpec{
 					Ports: []corev1.ServicePort{
@@ -275,6 +338,7 @@ func TestShadowServiceManager_CreateOrUpdate(t *testing.T) {
 
 			assert.Equal(t, test.expectedShadowSvc.Name, shadowSvc.Name)
 			assert.Equal(t, test.expectedShadowSvc.Namespace, shadowSvc.Namespace)
+			assert.Equal(t, test.expectedShadowSvc.Labels, shadowSvc.Labels)
 
 			for i, port := range test.expectedShadowSvc.Spec.Ports {
 				assert.Equal(t, port, shadowSvc.Spec.Ports[i])
@@ -709,3 +773,54 @@ func newFakeClient(serverVersion string, objects ...runtime.Object) (*fake.Clien
 
 	return client, lister
 }
+
+func TestShadowServiceManager_setIPFamilies(t *testing.T) {
+	singleStack := corev1.IPFamilyPolicySingleStack
+	preferDualStack := corev1.IPFamilyPolicyPreferDualStack
+
+	tests := []struct {
+		desc              string
+		shadowSvcSpec     corev1.ServiceSpec
+		svcSpec           corev1.ServiceSpec
+		expIPFamilyPolicy *corev1.IPFamilyPolicyType
+		expIPFamilies     []corev1.IPFamily
+	}{
+		{
+			desc: "should leave the IP families unset if the service has no IP family policy",
+		},
+		{
+			desc:              "should copy the IP families of a dual-stack service",
+			svcSpec:           corev1.ServiceSpec{IPFamilyPolicy: &preferDualStack, IPFamilies: []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}},
+			expIPFamilyPolicy: &preferDualStack,
+			expIPFamilies:     []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol},
+		},
+		{
+			desc:              "should upgrade a single-stack shadow service to dual-stack",
+			shadowSvcSpec:     corev1.ServiceSpec{IPFamilyPolicy: &singleStack, IPFamilies: []corev1.IPFamily{corev1.IPv4Protocol}},
+			svcSpec:           corev1.ServiceSpec{IPFamilyPolicy: &preferDualStack, IPFamilies: []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}},
+			expIPFamilyPolicy: &preferDualStack,
+			expIPFamilies:     []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol},
+		},
+		{
+			desc:              "should not change the primary IP family of a shadow service",
+			shadowSvcSpec:     corev1.ServiceSpec{IPFamilyPolicy: &singleStack, IPFamilies: []corev1.IPFamily{corev1.IPv4Protocol}},
+			svcSpec:           corev1.ServiceSpec{IPFamilyPolicy: &singleStack, IPFamilies: []corev1.IPFamily{corev1.IPv6Protocol}},
+			expIPFamilyPolicy: &singleStack,
+			expIPFamilies:     []corev1.IPFamily{corev1.IPv4Protocol},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			shadowSvc := &corev1.Service{Spec: test.shadowSvcSpec}
+
+			setIPFamilies(shadowSvc, &corev1.Service{Spec: test.svcSpec})
+
+			assert.Equal(t, test.expIPFamilyPolicy, shadowSvc.Spec.IPFamilyPolicy)
+			assert.Equal(t, test.expIPFamilies, shadowSvc.Spec.IPFamilies)
+		})
+	}
+}
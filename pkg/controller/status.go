@@ -0,0 +1,332 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	access "github.com/servicemeshinterface/smi-sdk-go/pkg/apis/access/v1alpha2"
+	accesslister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/access/listers/access/v1alpha2"
+	splitlister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/split/listers/split/v1alpha3"
+	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/topology"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// statusPatchTimeout is the maximum duration of a status annotation patch.
+const statusPatchTimeout = 5 * time.Second
+
+// errNoMatchingService is the error reported on the TrafficTargets whose destination is not exposed by any Service.
+const errNoMatchingService = "no matching service"
+
+// ResourceStatus is the status reported on the SMI resources processed by the controller.
+type ResourceStatus struct {
+	// Accepted is true if the resource is part of the mesh configuration.
+	Accepted bool `json:"accepted"`
+	// Services is the number of Services on which a TrafficTarget is applied.
+	Services int `json:"services,omitempty"`
+	// Backends is the number of valid backends of a TrafficSplit.
+	Backends int `json:"backends,omitempty"`
+	// Pods is the number of Pods resolved for the resource: the destination Pods of a TrafficTarget or the Pods
+	// exposed by the backends of a TrafficSplit.
+	Pods int `json:"pods"`
+	// Errors is the list of errors found while building the mesh configuration for the resource.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// StatusWriter writes the status of the SMI resources found in a topology. The statuses are written in the background,
+// by the Run loop, for the latest topology only.
+type StatusWriter struct {
+	clients             k8s.Client
+	trafficSplitLister  splitlister.TrafficSplitLister
+	trafficTargetLister accesslister.TrafficTargetLister
+	logger              logrus.FieldLogger
+
+	// pending holds the latest topology whose statuses are not written yet.
+	pending chan statusUpdate
+}
+
+// statusUpdate is a topology whose statuses must be written, with the filter of the resources it has been built with.
+type statusUpdate struct {
+	topo           *topology.Topology
+	resourceFilter *k8s.ResourceFilter
+}
+
+// NewStatusWriter creates and returns a new StatusWriter instance.
+func NewStatusWriter(clients k8s.Client, trafficSplitLister splitlister.TrafficSplitLister, trafficTargetLister accesslister.TrafficTargetLister, logger logrus.FieldLogger) *StatusWriter {
+	return &StatusWriter{
+		clients:             clients,
+		trafficSplitLister:  trafficSplitLister,
+		trafficTargetLister: trafficTargetLister,
+		logger:              logger,
+		pending:             make(chan statusUpdate, 1),
+	}
+}
+
+// Run writes the statuses of the topologies given to Write, until the given channel is closed.
+func (w *StatusWriter) Run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case update := <-w.pending:
+			w.writeStatuses(update.topo, update.resourceFilter)
+		}
+	}
+}
+
+// Write schedules the write of the statuses of the given topology, without waiting for it. If the statuses of a
+// previous topology are not written yet, they are replaced by the ones of the given topology.
+func (w *StatusWriter) Write(topo *topology.Topology, resourceFilter *k8s.ResourceFilter) {
+	update := statusUpdate{topo: topo, resourceFilter: resourceFilter}
+
+	for {
+		select {
+		case w.pending <- update:
+			return
+		default:
+		}
+
+		// Drop the pending topology, which is outdated.
+		select {
+		case <-w.pending:
+		default:
+		}
+	}
+}
+
+// writeStatuses writes the status annotation on the TrafficSplits and TrafficTargets of the given topology, and on the
+// TrafficTargets not ignored by the given filter which are missing from the topology. The annotation is updated only
+// if the status has changed.
+func (w *StatusWriter) writeStatuses(topo *topology.Topology, resourceFilter *k8s.ResourceFilter) {
+	for tsKey, status := range buildTrafficSplitStatuses(topo) {
+		if err := w.writeTrafficSplitStatus(tsKey, status); err != nil {
+			w.logger.Errorf("Unable to write status of TrafficSplit %q: %v", tsKey, err)
+		}
+	}
+
+	if w.trafficTargetLister == nil {
+		return
+	}
+
+	statuses := buildTrafficTargetStatuses(topo)
+
+	tts, err := w.trafficTargetLister.List(labels.Everything())
+	if err != nil {
+		w.logger.Errorf("Unable to list TrafficTargets: %v", err)
+	} else {
+		addUnmatchedTrafficTargetStatuses(statuses, tts, resourceFilter)
+	}
+
+	for ttKey, status := range statuses {
+		if err := w.writeTrafficTargetStatus(ttKey, status); err != nil {
+			w.logger.Errorf("Unable to write status of TrafficTarget %q: %v", ttKey, err)
+		}
+	}
+}
+
+func (w *StatusWriter) writeTrafficSplitStatus(key topology.Key, status ResourceStatus) error {
+	ts, err := w.trafficSplitLister.TrafficSplits(key.Namespace).Get(key.Name)
+	if err != nil {
+		return err
+	}
+
+	patch, changed, err := buildStatusPatch(ts.Annotations, status)
+	if err != nil || !changed {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusPatchTimeout)
+	defer cancel()
+
+	_, err = w.clients.SplitClient().SplitV1alpha3().TrafficSplits(key.Namespace).Patch(ctx, key.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+
+	return err
+}
+
+func (w *StatusWriter) writeTrafficTargetStatus(key topology.Key, status ResourceStatus) error {
+	tt, err := w.trafficTargetLister.TrafficTargets(key.Namespace).Get(key.Name)
+	if err != nil {
+		return err
+	}
+
+	patch, changed, err := buildStatusPatch(tt.Annotations, status)
+	if err != nil || !changed {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusPatchTimeout)
+	defer cancel()
+
+	_, err = w.clients.AccessClient().AccessV1alpha2().TrafficTargets(key.Namespace).Patch(ctx, key.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+
+	return err
+}
+
+// buildStatusPatch builds a merge patch setting the status annotation to the given status. It returns false if the
+// current annotations already hold this status.
+func buildStatusPatch(annotations map[string]string, status ResourceStatus) ([]byte, bool, error) {
+	value, err := json.Marshal(status)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to marshal status: %w", err)
+	}
+
+	if current, ok := annotations[k8s.StatusAnnotation]; ok && current == string(value) {
+		return nil, false, nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				k8s.StatusAnnotation: string(value),
+			},
+		},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to marshal status patch: %w", err)
+	}
+
+	return patch, true, nil
+}
+
+// buildTrafficSplitStatuses builds the status of each TrafficSplit of the given topology. A TrafficSplit is accepted
+// if it is still applied on its Service, which is not the case when it could not be built or when it was causing a loop.
+func buildTrafficSplitStatuses(topo *topology.Topology) map[topology.Key]ResourceStatus {
+	statuses := make(map[topology.Key]ResourceStatus)
+
+	for tsKey, ts := range topo.TrafficSplits {
+		status := ResourceStatus{
+			Backends: len(ts.Backends),
+			Errors:   uniqueSortedErrors(ts.Errors),
+		}
+
+		if svc, ok := topo.Services[ts.Service]; ok {
+			status.Accepted = containsKey(svc.TrafficSplits, tsKey)
+		}
+
+		pods := make(map[topology.Key]struct{})
+
+		for _, backend := range ts.Backends {
+			backendSvc, ok := topo.Services[backend.Service]
+			if !ok {
+				continue
+			}
+
+			for _, podKey := range backendSvc.Pods {
+				pods[podKey] = struct{}{}
+			}
+		}
+
+		status.Pods = len(pods)
+
+		statuses[tsKey] = status
+	}
+
+	return statuses
+}
+
+// buildTrafficTargetStatuses builds the status of each TrafficTarget of the given topology. As a TrafficTarget is
+// applied on every Service exposing its destination Pods, the status aggregates all the matching ServiceTrafficTargets.
+// A TrafficTarget is accepted if it is applied on at least one Service.
+func buildTrafficTargetStatuses(topo *topology.Topology) map[topology.Key]ResourceStatus {
+	statuses := make(map[topology.Key]ResourceStatus)
+	pods := make(map[topology.Key]map[topology.Key]struct{})
+	errs := make(map[topology.Key][]string)
+
+	for svcTTKey, stt := range topo.ServiceTrafficTargets {
+		ttKey := svcTTKey.TrafficTarget
+		status := statuses[ttKey]
+
+		if svc, ok := topo.Services[svcTTKey.Service]; ok && containsServiceTrafficTargetKey(svc.TrafficTargets, svcTTKey) {
+			status.Accepted = true
+			status.Services++
+		}
+
+		if _, ok := pods[ttKey]; !ok {
+			pods[ttKey] = make(map[topology.Key]struct{})
+		}
+
+		for _, podKey := range stt.Destination.Pods {
+			pods[ttKey][podKey] = struct{}{}
+		}
+
+		errs[ttKey] = append(errs[ttKey], stt.Errors...)
+		statuses[ttKey] = status
+	}
+
+	for ttKey, status := range statuses {
+		status.Pods = len(pods[ttKey])
+		status.Errors = uniqueSortedErrors(errs[ttKey])
+
+		statuses[ttKey] = status
+	}
+
+	return statuses
+}
+
+// addUnmatchedTrafficTargetStatuses adds a not accepted status to the given TrafficTargets which have no status yet,
+// as their destination is not exposed by any Service and they are therefore missing from the topology.
+func addUnmatchedTrafficTargetStatuses(statuses map[topology.Key]ResourceStatus, tts []*access.TrafficTarget, resourceFilter *k8s.ResourceFilter) {
+	for _, tt := range tts {
+		if resourceFilter != nil && resourceFilter.IsIgnored(tt) {
+			continue
+		}
+
+		ttKey := topology.Key{Name: tt.Name, Namespace: tt.Namespace}
+		if _, ok := statuses[ttKey]; ok {
+			continue
+		}
+
+		statuses[ttKey] = ResourceStatus{Errors: []string{errNoMatchingService}}
+	}
+}
+
+// uniqueSortedErrors returns the given errors sorted and without duplicates, so the status doesn't change between two
+// builds of the same topology.
+func uniqueSortedErrors(errs []string) []string {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+
+	var result []string
+
+	for _, err := range errs {
+		if _, ok := seen[err]; ok {
+			continue
+		}
+
+		seen[err] = struct{}{}
+		result = append(result, err)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+func containsKey(keys []topology.Key, key topology.Key) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsServiceTrafficTargetKey(keys []topology.ServiceTrafficTargetKey, key topology.ServiceTrafficTargetKey) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	accesslister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/access/listers/access/v1alpha2"
+	splitlister "github.com/servicemeshinterface/smi-sdk-go/pkg/gen/client/split/listers/split/v1alpha3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/k8s"
+	"github.com/traefik/mesh/pkg/topology"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildTrafficSplitStatuses(t *testing.T) {
+	svcKey := topology.Key{Name: "svc", Namespace: "ns"}
+	backend1Key := topology.Key{Name: "svc-v1", Namespace: "ns"}
+	backend2Key := topology.Key{Name: "svc-v2", Namespace: "ns"}
+	acceptedKey := topology.Key{Name: "accepted", Namespace: "ns"}
+	loopKey := topology.Key{Name: "loop", Namespace: "ns"}
+
+	topo := topology.NewTopology()
+	topo.Services[svcKey] = &topology.Service{Name: svcKey.Name, Namespace: svcKey.Namespace, TrafficSplits: []topology.Key{acceptedKey}}
+	topo.Services[backend1Key] = &topology.Service{Name: backend1Key.Name, Namespace: backend1Key.Namespace, Pods: []topology.Key{{Name: "pod-1", Namespace: "ns"}}}
+	topo.Services[backend2Key] = &topology.Service{Name: backend2Key.Name, Namespace: backend2Key.Namespace, Pods: []topology.Key{{Name: "pod-1", Namespace: "ns"}, {Name: "pod-2", Namespace: "ns"}}}
+	topo.TrafficSplits[acceptedKey] = &topology.TrafficSplit{
+		Name:      acceptedKey.Name,
+		Namespace: acceptedKey.Namespace,
+		Service:   svcKey,
+		Backends: []topology.TrafficSplitBackend{
+			{Weight: 80, Service: backend1Key},
+			{Weight: 20, Service: backend2Key},
+		},
+		Errors: []string{"port 8080 must be exposed", "port 8080 must be exposed"},
+	}
+	topo.TrafficSplits[loopKey] = &topology.TrafficSplit{
+		Name:      loopKey.Name,
+		Namespace: loopKey.Namespace,
+		Service:   svcKey,
+		Errors:    []string{"unable to get incoming pods: circular reference detected"},
+	}
+
+	statuses := buildTrafficSplitStatuses(topo)
+
+	assert.Equal(t, map[topology.Key]ResourceStatus{
+		acceptedKey: {
+			Accepted: true,
+			Backends: 2,
+			Pods:     2,
+			Errors:   []string{"port 8080 must be exposed"},
+		},
+		loopKey: {
+			Accepted: false,
+			Errors:   []string{"unable to get incoming pods: circular reference detected"},
+		},
+	}, statuses)
+}
+
+func TestBuildTrafficTargetStatuses(t *testing.T) {
+	svc1Key := topology.Key{Name: "svc-1", Namespace: "ns"}
+	svc2Key := topology.Key{Name: "svc-2", Namespace: "ns"}
+	ttKey := topology.Key{Name: "tt", Namespace: "ns"}
+	invalidTTKey := topology.Key{Name: "invalid", Namespace: "ns"}
+
+	svc1TTKey := topology.ServiceTrafficTargetKey{Service: svc1Key, TrafficTarget: ttKey}
+	svc2TTKey := topology.ServiceTrafficTargetKey{Service: svc2Key, TrafficTarget: ttKey}
+	svc1InvalidTTKey := topology.ServiceTrafficTargetKey{Service: svc1Key, TrafficTarget: invalidTTKey}
+
+	topo := topology.NewTopology()
+	topo.Services[svc1Key] = &topology.Service{Name: svc1Key.Name, Namespace: svc1Key.Namespace, TrafficTargets: []topology.ServiceTrafficTargetKey{svc1TTKey}}
+	topo.Services[svc2Key] = &topology.Service{Name: svc2Key.Name, Namespace: svc2Key.Namespace, TrafficTargets: []topology.ServiceTrafficTargetKey{svc2TTKey}}
+	topo.ServiceTrafficTargets[svc1TTKey] = &topology.ServiceTrafficTarget{
+		Service:     svc1Key,
+		Name:        ttKey.Name,
+		Namespace:   ttKey.Namespace,
+		Destination: topology.ServiceTrafficTargetDestination{Pods: []topology.Key{{Name: "pod-1", Namespace: "ns"}}},
+	}
+	topo.ServiceTrafficTargets[svc2TTKey] = &topology.ServiceTrafficTarget{
+		Service:     svc2Key,
+		Name:        ttKey.Name,
+		Namespace:   ttKey.Namespace,
+		Destination: topology.ServiceTrafficTargetDestination{Pods: []topology.Key{{Name: "pod-1", Namespace: "ns"}, {Name: "pod-2", Namespace: "ns"}}},
+	}
+	topo.ServiceTrafficTargets[svc1InvalidTTKey] = &topology.ServiceTrafficTarget{
+		Service:   svc1Key,
+		Name:      invalidTTKey.Name,
+		Namespace: invalidTTKey.Namespace,
+		Errors:    []string{"unable to build spec: unable to find HTTPRouteGroup"},
+	}
+
+	statuses := buildTrafficTargetStatuses(topo)
+
+	assert.Equal(t, map[topology.Key]ResourceStatus{
+		ttKey: {
+			Accepted: true,
+			Services: 2,
+			Pods:     2,
+		},
+		invalidTTKey: {
+			Accepted: false,
+			Errors:   []string{"unable to build spec: unable to find HTTPRouteGroup"},
+		},
+	}, statuses)
+}
+
+func TestStatusWriter_writeStatuses(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	clientMock := k8s.NewClientMock("status.yaml")
+
+	ctx := context.Background()
+
+	ts, err := clientMock.SplitClient().SplitV1alpha3().TrafficSplits("ns").Get(ctx, "ts", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	unmatchedTT, err := clientMock.AccessClient().AccessV1alpha2().TrafficTargets("ns").Get(ctx, "tt-unmatched", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	ignoredTT, err := clientMock.AccessClient().AccessV1alpha2().TrafficTargets("ignored").Get(ctx, "tt-ignored", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	writer := NewStatusWriter(clientMock,
+		splitlister.NewTrafficSplitLister(newIndexer(t, ts)),
+		accesslister.NewTrafficTargetLister(newIndexer(t, unmatchedTT, ignoredTT)),
+		log,
+	)
+
+	svcKey := topology.Key{Name: "svc", Namespace: "ns"}
+	tsKey := topology.Key{Name: "ts", Namespace: "ns"}
+
+	topo := topology.NewTopology()
+	topo.Services[svcKey] = &topology.Service{Name: svcKey.Name, Namespace: svcKey.Namespace}
+	topo.TrafficSplits[tsKey] = &topology.TrafficSplit{
+		Name:      tsKey.Name,
+		Namespace: tsKey.Namespace,
+		Service:   svcKey,
+		Errors:    []string{"unable to find backend Service \"svc-v1@ns\""},
+	}
+
+	writer.writeStatuses(topo, k8s.NewResourceFilter(k8s.IgnoreNamespaces("ignored")))
+
+	ts, err = clientMock.SplitClient().SplitV1alpha3().TrafficSplits("ns").Get(ctx, "ts", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	var status ResourceStatus
+
+	require.NoError(t, json.Unmarshal([]byte(ts.Annotations[k8s.StatusAnnotation]), &status))
+	assert.Equal(t, ResourceStatus{Errors: []string{"unable to find backend Service \"svc-v1@ns\""}}, status)
+	assert.Equal(t, "bar", ts.Annotations["foo"])
+
+	unmatchedTT, err = clientMock.AccessClient().AccessV1alpha2().TrafficTargets("ns").Get(ctx, "tt-unmatched", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, json.Unmarshal([]byte(unmatchedTT.Annotations[k8s.StatusAnnotation]), &status))
+	assert.Equal(t, ResourceStatus{Errors: []string{"no matching service"}}, status)
+
+	ignoredTT, err = clientMock.AccessClient().AccessV1alpha2().TrafficTargets("ignored").Get(ctx, "tt-ignored", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	assert.NotContains(t, ignoredTT.Annotations, k8s.StatusAnnotation)
+}
+
+func TestStatusWriter_Write(t *testing.T) {
+	writer := NewStatusWriter(nil, nil, nil, logrus.New())
+
+	outdated := topology.NewTopology()
+	latest := topology.NewTopology()
+
+	// Only the latest topology is kept while the statuses are not written.
+	writer.Write(outdated, nil)
+	writer.Write(latest, nil)
+
+	require.Len(t, writer.pending, 1)
+
+	update := <-writer.pending
+	assert.Same(t, latest, update.topo)
+}
+
+func TestBuildStatusPatch(t *testing.T) {
+	status := ResourceStatus{Accepted: true, Backends: 1, Pods: 2}
+
+	patch, changed, err := buildStatusPatch(nil, status)
+	require.NoError(t, err)
+
+	assert.True(t, changed)
+	assert.JSONEq(t, `{"metadata":{"annotations":{"mesh.traefik.io/status":"{\"accepted\":true,\"backends\":1,\"pods\":2}"}}}`, string(patch))
+
+	_, changed, err = buildStatusPatch(map[string]string{k8s.StatusAnnotation: `{"accepted":true,"backends":1,"pods":2}`}, status)
+	require.NoError(t, err)
+
+	assert.False(t, changed)
+}
@@ -0,0 +1,289 @@
+package dns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// backupVersion is the version of the backup format, bumped on incompatible changes.
+	backupVersion = "v1"
+
+	backupVersionAnnotation   = "mesh.traefik.io/dns-backup-version"
+	backupSourceAnnotation    = "mesh.traefik.io/dns-backup-source"
+	backupPatchHashAnnotation = "mesh.traefik.io/dns-backup-patch-hash"
+)
+
+// backupName returns the name of the backup of the ConfigMap with the given name in the given namespace.
+func backupName(namespace, name string) string {
+	return fmt.Sprintf("dns-backup-%s-%s", namespace, name)
+}
+
+// backupConfigMap stores, in the given Traefik Mesh namespace, the data of the live version of the given ConfigMap,
+// which is about to be patched. When a backup already exists, the live ConfigMap has already been patched, so the
+// backup keeps the original data. The patch is recorded in the backup by recordConfigMapPatch, once it has been
+// successfully rolled out.
+func (c *Client) backupConfigMap(ctx context.Context, configMap *corev1.ConfigMap, traefikMeshNamespace string) error {
+	source := configMap.Namespace + "/" + configMap.Name
+	name := backupName(configMap.Namespace, configMap.Name)
+
+	var liveData map[string]string
+
+	live, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Get(ctx, configMap.Name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		liveData = live.Data
+	case !kerrors.IsNotFound(err):
+		return fmt.Errorf("unable to get ConfigMap %q in namespace %q: %w", configMap.Name, configMap.Namespace, err)
+	}
+
+	backup, err := c.getConfigMapBackup(ctx, configMap.Namespace, configMap.Name, traefikMeshNamespace)
+	if err != nil {
+		return err
+	}
+
+	if backup != nil {
+		drifted, driftErr := hasDrifted(backup, liveData)
+		if driftErr != nil {
+			return driftErr
+		}
+
+		if drifted {
+			c.logger.Warnf("ConfigMap %q has changed since it was patched by Traefik Mesh, its backup %q in namespace %q keeps the data from before the first patch",
+				source, name, traefikMeshNamespace)
+		}
+
+		if c.dryRunOut != nil {
+			fmt.Fprintf(c.dryRunOut, "Would keep the backup of ConfigMap %s in ConfigMap %s/%s\n", source, traefikMeshNamespace, name)
+		}
+
+		return nil
+	}
+
+	if c.dryRunOut != nil {
+		fmt.Fprintf(c.dryRunOut, "Would back up ConfigMap %s to ConfigMap %s/%s\n", source, traefikMeshNamespace, name)
+		return nil
+	}
+
+	data := make(map[string]string, len(liveData))
+	for key, value := range liveData {
+		data[key] = value
+	}
+
+	// Until the patch is rolled out, the live data is the original data.
+	liveHash, err := hashData(liveData)
+	if err != nil {
+		return err
+	}
+
+	backup = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: traefikMeshNamespace,
+			Labels: map[string]string{
+				"app":       "maesh",
+				"component": "dns-backup",
+			},
+			Annotations: map[string]string{
+				backupVersionAnnotation:   backupVersion,
+				backupSourceAnnotation:    source,
+				backupPatchHashAnnotation: liveHash,
+			},
+		},
+		Data: data,
+	}
+
+	if _, err = c.kubeClient.CoreV1().ConfigMaps(traefikMeshNamespace).Create(ctx, backup, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("unable to create backup ConfigMap %q in namespace %q: %w", name, traefikMeshNamespace, err)
+	}
+
+	c.logger.Infof("ConfigMap %q has been backed up to ConfigMap %q in namespace %q", source, name, traefikMeshNamespace)
+
+	return nil
+}
+
+// recordConfigMapPatch records, in the backup stored in the given Traefik Mesh namespace, the data written by the patch
+// of the given ConfigMap, once it has been successfully rolled out, to detect the changes made since.
+func (c *Client) recordConfigMapPatch(ctx context.Context, configMap *corev1.ConfigMap, traefikMeshNamespace string) error {
+	if c.dryRunOut != nil {
+		return nil
+	}
+
+	backup, err := c.getConfigMapBackup(ctx, configMap.Namespace, configMap.Name, traefikMeshNamespace)
+	if err != nil {
+		return err
+	}
+
+	if backup == nil {
+		return fmt.Errorf("no backup found for ConfigMap %q in namespace %q", configMap.Name, configMap.Namespace)
+	}
+
+	patchHash, err := hashData(configMap.Data)
+	if err != nil {
+		return err
+	}
+
+	if backup.Annotations[backupPatchHashAnnotation] == patchHash {
+		return nil
+	}
+
+	backup.Annotations[backupPatchHashAnnotation] = patchHash
+
+	if _, err = c.kubeClient.CoreV1().ConfigMaps(traefikMeshNamespace).Update(ctx, backup, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update backup ConfigMap %q in namespace %q: %w", backup.Name, traefikMeshNamespace, err)
+	}
+
+	return nil
+}
+
+// getConfigMapBackup returns the backup, stored in the given Traefik Mesh namespace, of the ConfigMap with the given
+// name in the given namespace, or nil if there is none.
+func (c *Client) getConfigMapBackup(ctx context.Context, namespace, name, traefikMeshNamespace string) (*corev1.ConfigMap, error) {
+	backupName := backupName(namespace, name)
+
+	backup, err := c.kubeClient.CoreV1().ConfigMaps(traefikMeshNamespace).Get(ctx, backupName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get backup ConfigMap %q in namespace %q: %w", backupName, traefikMeshNamespace, err)
+	}
+
+	if version := backup.Annotations[backupVersionAnnotation]; version != backupVersion {
+		return nil, fmt.Errorf("unsupported version %q of backup ConfigMap %q in namespace %q", version, backupName, traefikMeshNamespace)
+	}
+
+	if source := backup.Annotations[backupSourceAnnotation]; source != namespace+"/"+name {
+		return nil, fmt.Errorf("backup ConfigMap %q in namespace %q belongs to ConfigMap %q", backupName, traefikMeshNamespace, source)
+	}
+
+	if backup.Data == nil {
+		backup.Data = make(map[string]string)
+	}
+
+	return backup, nil
+}
+
+// restoreFromBackup replaces the data of the given ConfigMap with the data of its backup, stored in the given Traefik
+// Mesh namespace, and returns the backup, or nil if there is none. A warning is logged if the ConfigMap has changed
+// since it was patched, as these changes are discarded.
+func (c *Client) restoreFromBackup(ctx context.Context, configMap *corev1.ConfigMap, traefikMeshNamespace string) (*corev1.ConfigMap, error) {
+	backup, err := c.getConfigMapBackup(ctx, configMap.Namespace, configMap.Name, traefikMeshNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if backup == nil {
+		c.logger.Debugf("No backup found for ConfigMap %q in namespace %q", configMap.Name, configMap.Namespace)
+		return nil, nil
+	}
+
+	drifted, err := hasDrifted(backup, configMap.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if drifted {
+		c.logger.Warnf("ConfigMap %q in namespace %q has changed since it was patched by Traefik Mesh, restoring its backup %q in namespace %q discards these changes",
+			configMap.Name, configMap.Namespace, backup.Name, backup.Namespace)
+	}
+
+	data := make(map[string]string, len(backup.Data))
+	for key, value := range backup.Data {
+		data[key] = value
+	}
+
+	configMap.Data = data
+
+	return backup, nil
+}
+
+// deleteConfigMapBackup deletes the given backup once it has been restored.
+func (c *Client) deleteConfigMapBackup(ctx context.Context, backup *corev1.ConfigMap) error {
+	if c.dryRunOut != nil {
+		fmt.Fprintf(c.dryRunOut, "Would delete backup ConfigMap %s/%s\n", backup.Namespace, backup.Name)
+		c.removedBackups = append(c.removedBackups, backup.Name)
+
+		return nil
+	}
+
+	err := c.kubeClient.CoreV1().ConfigMaps(backup.Namespace).Delete(ctx, backup.Name, metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("unable to delete backup ConfigMap %q in namespace %q: %w", backup.Name, backup.Namespace, err)
+	}
+
+	c.removedBackups = append(c.removedBackups, backup.Name)
+
+	return nil
+}
+
+// DeleteConfigMapBackups deletes all the backups stored in the given Traefik Mesh namespace, including the ones of
+// ConfigMaps which are not used by the DNS of the cluster anymore, and which can't be restored.
+func (c *Client) DeleteConfigMapBackups(ctx context.Context, traefikMeshNamespace string) error {
+	backups, err := c.kubeClient.CoreV1().ConfigMaps(traefikMeshNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=maesh,component=dns-backup",
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list backup ConfigMaps in namespace %q: %w", traefikMeshNamespace, err)
+	}
+
+	removed := make(map[string]struct{}, len(c.removedBackups))
+	for _, name := range c.removedBackups {
+		removed[name] = struct{}{}
+	}
+
+	for i := range backups.Items {
+		// In dry-run mode, the backups restored are still there.
+		if _, ok := removed[backups.Items[i].Name]; ok {
+			continue
+		}
+
+		if err = c.deleteConfigMapBackup(ctx, &backups.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemovedBackups returns the names of the backup ConfigMaps deleted by the Client, or which would be deleted in dry-run
+// mode.
+func (c *Client) RemovedBackups() []string {
+	return c.removedBackups
+}
+
+// hasDrifted returns true if the given live data differs from the data written by the patch recorded in the given
+// backup.
+func hasDrifted(backup *corev1.ConfigMap, liveData map[string]string) (bool, error) {
+	liveHash, err := hashData(liveData)
+	if err != nil {
+		return false, err
+	}
+
+	return liveHash != backup.Annotations[backupPatchHashAnnotation], nil
+}
+
+// hashData returns a hash of the given ConfigMap data.
+func hashData(data map[string]string) (string, error) {
+	if data == nil {
+		data = make(map[string]string)
+	}
+
+	// Maps are marshaled with sorted keys.
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal ConfigMap data: %w", err)
+	}
+
+	hash := sha256.Sum256(raw)
+
+	return hex.EncodeToString(hash[:]), nil
+}
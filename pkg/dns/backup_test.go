@@ -0,0 +1,166 @@
+package dns
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/k8s"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBackup_restore(t *testing.T) {
+	tests := []struct {
+		desc        string
+		reconfigure bool
+		drift       bool
+		expWarning  bool
+	}{
+		{
+			desc: "Restore the backup",
+		},
+		{
+			desc:        "Restore the backup after patching twice",
+			reconfigure: true,
+		},
+		{
+			desc:       "Restore the backup of a ConfigMap changed since it was patched",
+			drift:      true,
+			expWarning: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock("configurecoredns_custom_namespace.yaml")
+			configMaps := k8sClient.KubernetesClient().CoreV1().ConfigMaps("dns-system")
+
+			log, hook := newTestLogger()
+			client := NewClient(log, k8sClient.KubernetesClient())
+
+			original, err := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			require.NoError(t, client.ConfigureCoreDNS(ctx, "dns-system", "cluster.local", "traefik-mesh"))
+
+			backup, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps("traefik-mesh").Get(ctx, "dns-backup-dns-system-coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, original.Data, backup.Data)
+			assert.Equal(t, "dns-backup", backup.Labels["component"])
+			assert.Equal(t, "v1", backup.Annotations["mesh.traefik.io/dns-backup-version"])
+			assert.Equal(t, "dns-system/coredns", backup.Annotations["mesh.traefik.io/dns-backup-source"])
+
+			if test.reconfigure {
+				require.NoError(t, NewClient(log, k8sClient.KubernetesClient(), ForwardTo("10.0.0.10:5353")).ConfigureCoreDNS(ctx, "dns-system", "cluster.local", "traefik-mesh"))
+			}
+
+			if test.drift {
+				live, getErr := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+				require.NoError(t, getErr)
+
+				live.Data["Corefile"] += "# Hand edited\n"
+
+				_, err = configMaps.Update(ctx, live, metav1.UpdateOptions{})
+				require.NoError(t, err)
+			}
+
+			hook.Reset()
+
+			require.NoError(t, client.RestoreCoreDNS(ctx, "traefik-mesh"))
+
+			restored, err := configMaps.Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, original.Data, restored.Data)
+			assert.Equal(t, test.expWarning, hasWarning(hook))
+
+			_, err = k8sClient.KubernetesClient().CoreV1().ConfigMaps("traefik-mesh").Get(ctx, "dns-backup-dns-system-coredns", metav1.GetOptions{})
+			assert.True(t, kerrors.IsNotFound(err))
+		})
+	}
+}
+
+func TestBackup_failedRollout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8s.NewClientMock("rollout_unavailable.yaml")
+
+	log, _ := newTestLogger()
+	client := NewClient(log, k8sClient.KubernetesClient(), RolloutTimeout(50*time.Millisecond))
+	client.rolloutPollInterval = 10 * time.Millisecond
+
+	original, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	require.Error(t, client.ConfigureCoreDNS(ctx, metav1.NamespaceSystem, "cluster.local", "traefik-mesh"))
+
+	backup, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps("traefik-mesh").Get(ctx, "dns-backup-kube-system-coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// The patch has been rolled back, so it must not be recorded in the backup.
+	drifted, err := hasDrifted(backup, original.Data)
+	require.NoError(t, err)
+	assert.False(t, drifted)
+}
+
+func TestBackup_unsupportedVersion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient := k8s.NewClientMock("configurecoredns_custom_namespace.yaml")
+
+	log, _ := newTestLogger()
+	client := NewClient(log, k8sClient.KubernetesClient())
+
+	require.NoError(t, client.ConfigureCoreDNS(ctx, "dns-system", "cluster.local", "traefik-mesh"))
+
+	backups := k8sClient.KubernetesClient().CoreV1().ConfigMaps("traefik-mesh")
+
+	backup, err := backups.Get(ctx, "dns-backup-dns-system-coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	backup.Annotations["mesh.traefik.io/dns-backup-version"] = "v0"
+
+	_, err = backups.Update(ctx, backup, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	assert.Error(t, client.RestoreCoreDNS(ctx, "traefik-mesh"))
+}
+
+func TestRemoveStubDomain_missingTrailer(t *testing.T) {
+	config := ".:53 {\n    errors\n}\n" + traefikMeshBlockHeader + "\ntraefik.mesh:53 {\n    errors\n}\n.:5353 {\n    errors\n}\n"
+
+	assert.Equal(t, config, removeStubDomain(config, traefikMeshBlockHeader, traefikMeshBlockTrailer))
+}
+
+func newTestLogger() (*logrus.Logger, *test.Hook) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	return log, test.NewLocal(log)
+}
+
+func hasWarning(hook *test.Hook) bool {
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigState is the state of the mesh domains configuration in the cluster DNS.
+type ConfigState int
+
+// Mesh domains configuration states.
+const (
+	// ConfigMissing means that none of the mesh domains is configured.
+	ConfigMissing ConfigState = iota
+	// ConfigOutdated means that the mesh domains configuration differs from the one the prepare command would write.
+	ConfigOutdated
+	// ConfigUpToDate means that the mesh domains are configured as the prepare command would configure them.
+	ConfigUpToDate
+)
+
+// String returns a human readable representation of the configuration state.
+func (s ConfigState) String() string {
+	switch s {
+	case ConfigMissing:
+		return "missing"
+	case ConfigOutdated:
+		return "outdated"
+	case ConfigUpToDate:
+		return "up to date"
+	default:
+		return "unknown"
+	}
+}
+
+// ProviderVersion returns the version of the given DNS provider deployed in the cluster.
+func (c *Client) ProviderVersion(ctx context.Context, provider Provider) (string, error) {
+	switch provider {
+	case CoreDNS:
+		coreDNS, err := c.getClusterCoreDNSWorkload(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		version, err := c.getCoreDNSVersion(coreDNS)
+		if err != nil {
+			return "", err
+		}
+
+		return version.String(), nil
+
+	case KubeDNS:
+		kubeDNSDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "kube-dns", metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("unable to get KubeDNS deployment in namespace %q: %w", metav1.NamespaceSystem, err)
+		}
+
+		for _, container := range kubeDNSDeployment.Spec.Template.Spec.Containers {
+			if container.Name != "kubedns" {
+				continue
+			}
+
+			parts := strings.Split(container.Image, ":")
+
+			return parts[len(parts)-1], nil
+		}
+
+		return "", fmt.Errorf("unable to get KubeDNS container in deployment %q in namespace %q", "kube-dns", metav1.NamespaceSystem)
+
+	default:
+		return "", fmt.Errorf("unsupported DNS provider %d", provider)
+	}
+}
+
+// CoreDNSConfigState returns the state of the mesh domains configuration in the CoreDNS of the cluster, compared to
+// the one ConfigureCoreDNS would write. Nothing is written.
+func (c *Client) CoreDNSConfigState(ctx context.Context, clusterDomain, traefikMeshNamespace string) (ConfigState, error) {
+	coreDNS, err := c.getClusterCoreDNSWorkload(ctx)
+	if err != nil {
+		return ConfigMissing, err
+	}
+
+	patchedConfigMap, changed, err := c.patchCoreDNSConfig(ctx, coreDNS, clusterDomain, traefikMeshNamespace)
+	if err != nil {
+		return ConfigMissing, fmt.Errorf("unable to patch coredns config: %w", err)
+	}
+
+	if !changed {
+		return ConfigUpToDate, nil
+	}
+
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(patchedConfigMap.Namespace).Get(ctx, patchedConfigMap.Name, metav1.GetOptions{})
+	if err != nil {
+		return ConfigMissing, fmt.Errorf("unable to get ConfigMap %q in namespace %q: %w", patchedConfigMap.Name, patchedConfigMap.Namespace, err)
+	}
+
+	for _, value := range configMap.Data {
+		for _, domain := range blockDomains(value) {
+			if c.hasDomain(domain) {
+				return ConfigOutdated, nil
+			}
+		}
+	}
+
+	return ConfigMissing, nil
+}
+
+// KubeDNSConfigState returns the state of the mesh domains stub domains in the KubeDNS configuration. Nothing is
+// written.
+func (c *Client) KubeDNSConfigState(ctx context.Context) (ConfigState, error) {
+	kubeDNSDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "kube-dns", metav1.GetOptions{})
+	if err != nil {
+		return ConfigMissing, fmt.Errorf("unable to get KubeDNS deployment in namespace %q: %w", metav1.NamespaceSystem, err)
+	}
+
+	configMap, err := c.getConfigMap(ctx, &workload{deployment: kubeDNSDeployment}, "kube-dns")
+	if kerrors.IsNotFound(err) {
+		return ConfigMissing, nil
+	}
+
+	if err != nil {
+		return ConfigMissing, err
+	}
+
+	stubDomains := make(map[string][]string)
+
+	if stubDomainsStr := configMap.Data["stubDomains"]; stubDomainsStr != "" {
+		if err = json.Unmarshal([]byte(stubDomainsStr), &stubDomains); err != nil {
+			return ConfigMissing, fmt.Errorf("unable to unmarshal stub domains: %w", err)
+		}
+	}
+
+	var configured int
+
+	for _, domain := range c.domains {
+		if len(stubDomains[domain]) > 0 {
+			configured++
+		}
+	}
+
+	switch configured {
+	case 0:
+		return ConfigMissing, nil
+	case len(c.domains):
+		return ConfigUpToDate, nil
+	default:
+		return ConfigOutdated, nil
+	}
+}
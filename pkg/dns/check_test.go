@@ -0,0 +1,135 @@
+package dns
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/k8s"
+)
+
+func TestClient_CoreDNSConfigState(t *testing.T) {
+	tests := []struct {
+		desc     string
+		mockFile string
+		domains  []string
+		expState ConfigState
+	}{
+		{
+			desc:     "Not patched CoreDNS",
+			mockFile: "configurecoredns_not_patched.yaml",
+			expState: ConfigMissing,
+		},
+		{
+			desc:     "Already patched CoreDNS",
+			mockFile: "configurecoredns_already_patched.yaml",
+			expState: ConfigUpToDate,
+		},
+		{
+			desc:     "Already patched CoreDNS custom config",
+			mockFile: "configurecoredns_custom_already_patched.yaml",
+			expState: ConfigUpToDate,
+		},
+		{
+			desc:     "CoreDNS 1.7 patched for an older version of CoreDNS",
+			mockFile: "configurecoredns_17_already_patched.yaml",
+			expState: ConfigOutdated,
+		},
+		{
+			desc:     "CoreDNS patched for other mesh domains",
+			mockFile: "configurecoredns_already_patched.yaml",
+			domains:  []string{"svc.mesh.internal"},
+			expState: ConfigMissing,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			var opts []ClientOption
+			if test.domains != nil {
+				opts = append(opts, Domains(test.domains...))
+			}
+
+			client := NewClient(log, k8sClient.KubernetesClient(), opts...)
+
+			state, err := client.CoreDNSConfigState(ctx, "titi", "toto")
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expState, state)
+		})
+	}
+}
+
+func TestClient_KubeDNSConfigState(t *testing.T) {
+	tests := []struct {
+		desc     string
+		mockFile string
+		domains  []string
+		expState ConfigState
+	}{
+		{
+			desc:     "Not patched KubeDNS",
+			mockFile: "configurekubedns_not_patched.yaml",
+			expState: ConfigMissing,
+		},
+		{
+			desc:     "Missing optional ConfigMap",
+			mockFile: "configurekubedns_optional_configmap.yaml",
+			expState: ConfigMissing,
+		},
+		{
+			desc:     "Already patched KubeDNS",
+			mockFile: "restorekubedns_already_patched.yaml",
+			expState: ConfigUpToDate,
+		},
+		{
+			desc:     "KubeDNS patched for some of the mesh domains",
+			mockFile: "restorekubedns_already_patched.yaml",
+			domains:  []string{"traefik.mesh", "svc.mesh.internal"},
+			expState: ConfigOutdated,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			var opts []ClientOption
+			if test.domains != nil {
+				opts = append(opts, Domains(test.domains...))
+			}
+
+			client := NewClient(log, k8sClient.KubernetesClient(), opts...)
+
+			state, err := client.KubeDNSConfigState(ctx)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expState, state)
+		})
+	}
+}
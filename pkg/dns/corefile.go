@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var reloadRegexp = regexp.MustCompile(`(?m)^\s*reload(\s|$)`)
+
+// validateCorefile checks the syntax of the given Corefile, or of the given server blocks when they are imported by a
+// Corefile (e.g.: coredns-custom), the same way CoreDNS would: quotes and braces must be balanced, and each server
+// block must be made of a list of keys followed by a body between braces.
+func validateCorefile(corefile string) error {
+	var (
+		depth   int
+		line    = 1
+		keys    []string
+		token   strings.Builder
+		quoted  bool
+		escaped bool
+		comment bool
+	)
+
+	endToken := func() {
+		if token.Len() > 0 {
+			if depth == 0 {
+				keys = append(keys, token.String())
+			}
+
+			token.Reset()
+		}
+	}
+
+	for _, r := range corefile {
+		switch {
+		case comment:
+			if r == '\n' {
+				comment = false
+				line++
+			}
+
+			continue
+
+		case quoted:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				quoted = false
+			case r == '\n':
+				line++
+			}
+
+			token.WriteRune(r)
+
+			continue
+		}
+
+		switch r {
+		case '#':
+			endToken()
+
+			comment = true
+
+		case '"':
+			quoted = true
+
+			token.WriteRune(r)
+
+		case '{':
+			endToken()
+
+			if depth == 0 {
+				if len(keys) == 0 {
+					return fmt.Errorf("line %d: server block without keys", line)
+				}
+
+				keys = nil
+			}
+
+			depth++
+
+		case '}':
+			endToken()
+
+			if depth == 0 {
+				return fmt.Errorf("line %d: unexpected '}'", line)
+			}
+
+			depth--
+
+		case ' ', '\t', '\r':
+			endToken()
+
+		case '\n':
+			endToken()
+
+			line++
+
+		default:
+			token.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return errors.New("unterminated quoted string")
+	}
+
+	endToken()
+
+	if depth > 0 {
+		return fmt.Errorf("%d unclosed '{'", depth)
+	}
+
+	if len(keys) > 0 {
+		return fmt.Errorf("server block %q has no body", strings.Join(keys, " "))
+	}
+
+	return nil
+}
+
+// hasReloadPlugin returns true if the given Corefile enables the reload plugin, which makes CoreDNS load the changes
+// of the Corefile without restarting.
+func hasReloadPlugin(corefile string) bool {
+	return reloadRegexp.MatchString(corefile)
+}
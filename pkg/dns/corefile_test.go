@@ -0,0 +1,79 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCorefile(t *testing.T) {
+	tests := []struct {
+		desc     string
+		corefile string
+		expErr   bool
+	}{
+		{
+			desc:     "Empty Corefile",
+			corefile: "",
+		},
+		{
+			desc:     "Server blocks with nested blocks",
+			corefile: ".:53 {\n    errors\n    health {\n        lameduck 5s\n    }\n    forward . /etc/resolv.conf\n}\n\ntraefik.mesh:53 {\n    errors\n}\n",
+		},
+		{
+			desc:     "Comments and quoted braces",
+			corefile: "# Comment with a brace {\n.:53 {\n    template IN A example {\n        answer \"{{ .Name }} 60 IN A 127.0.0.1\"\n    }\n}\n",
+		},
+		{
+			desc:     "Server block with several keys",
+			corefile: "maesh:53 traefik.mesh:53 {\n    errors\n}\n",
+		},
+		{
+			desc:     "Unclosed server block",
+			corefile: ".:53 {\n    errors\n",
+			expErr:   true,
+		},
+		{
+			desc:     "Unexpected closing brace",
+			corefile: ".:53 {\n    errors\n}\n}\n",
+			expErr:   true,
+		},
+		{
+			desc:     "Server block without keys",
+			corefile: "{\n    errors\n}\n",
+			expErr:   true,
+		},
+		{
+			desc:     "Server block without body",
+			corefile: ".:53 {\n    errors\n}\ntraefik.mesh:53\n",
+			expErr:   true,
+		},
+		{
+			desc:     "Unterminated quoted string",
+			corefile: ".:53 {\n    hosts \"foo {\n}\n",
+			expErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateCorefile(test.corefile)
+			if test.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestHasReloadPlugin(t *testing.T) {
+	assert.True(t, hasReloadPlugin(".:53 {\n    errors\n    reload\n}\n"))
+	assert.True(t, hasReloadPlugin(".:53 {\n    reload 10s\n}\n"))
+	assert.False(t, hasReloadPlugin(".:53 {\n    errors\n}\n"))
+	assert.False(t, hasReloadPlugin(".:53 {\n    # reload\n    errors\n}\n"))
+}
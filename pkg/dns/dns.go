@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"regexp"
 	"strings"
 	"time"
 
@@ -13,7 +15,6 @@ import (
 	goversion "github.com/hashicorp/go-version"
 	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/pkg/safe"
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -51,21 +52,54 @@ var (
 type Client struct {
 	kubeClient kubernetes.Interface
 	logger     logrus.FieldLogger
+	// forwardAddr is the address of the DNS server to which the mesh domains are forwarded, if any.
+	forwardAddr string
+	// rolloutTimeout is the maximum duration to wait for the DNS pods to be available after a restart.
+	rolloutTimeout      time.Duration
+	rolloutPollInterval time.Duration
+	// forceRestart makes the DNS pods restart even when the reload plugin loads the configuration changes.
+	forceRestart bool
+	// dryRunOut is the writer to which the changes are printed instead of being applied, in dry-run mode.
+	dryRunOut io.Writer
+	// domains are the mesh domains, the first one being the main mesh domain.
+	domains []string
+	// removedBackups are the names of the backup ConfigMaps deleted, or which would be deleted in dry-run mode.
+	removedBackups []string
+}
+
+// ClientOption configures the Client.
+type ClientOption func(c *Client)
+
+// ForwardTo configures the cluster DNS to forward the mesh domains to the DNS server listening on the given address,
+// typically the one embedded in the controller, instead of rewriting them to the shadow services.
+func ForwardTo(addr string) ClientOption {
+	return func(c *Client) {
+		c.forwardAddr = addr
+	}
 }
 
 // NewClient returns an initialized DNSClient object.
-func NewClient(logger logrus.FieldLogger, kubeClient kubernetes.Interface) *Client {
-	return &Client{
-		kubeClient: kubeClient,
-		logger:     logger,
+func NewClient(logger logrus.FieldLogger, kubeClient kubernetes.Interface, opts ...ClientOption) *Client {
+	c := &Client{
+		kubeClient:          kubeClient,
+		logger:              logger,
+		rolloutPollInterval: defaultRolloutPollInterval,
+		domains:             defaultDomains,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
-// CheckDNSProvider checks that the DNS provider deployed in the cluster is supported and returns it.
+// CheckDNSProvider checks that the DNS provider deployed in the cluster is supported and returns it. CoreDNS is looked
+// up in the kube-system namespace first, then KubeDNS, and finally CoreDNS in the other namespaces.
 func (c *Client) CheckDNSProvider(ctx context.Context) (Provider, error) {
 	c.logger.Debug("Detecting DNS provider...")
 
-	match, err := c.coreDNSMatch(ctx)
+	match, err := c.coreDNSMatch(ctx, metav1.NamespaceSystem)
 	if err != nil {
 		return UnknownDNS, err
 	}
@@ -83,39 +117,79 @@ func (c *Client) CheckDNSProvider(ctx context.Context) (Provider, error) {
 		return KubeDNS, nil
 	}
 
+	match, err = c.coreDNSMatch(ctx, metav1.NamespaceAll)
+	if err != nil {
+		return UnknownDNS, err
+	}
+
+	if match {
+		return CoreDNS, nil
+	}
+
 	return UnknownDNS, errors.New("no supported DNS service available for installing traefik mesh")
 }
 
-func (c *Client) coreDNSMatch(ctx context.Context) (bool, error) {
-	c.logger.Debugf("Checking if CoreDNS is installed in namespace %q...", metav1.NamespaceSystem)
+// CoreDNSNamespace returns the namespace in which the CoreDNS of the cluster is deployed.
+func (c *Client) CoreDNSNamespace(ctx context.Context) (string, error) {
+	coreDNS, err := c.getClusterCoreDNSWorkload(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return coreDNS.objectMeta().Namespace, nil
+}
+
+// getClusterCoreDNSWorkload returns the CoreDNS workload of the cluster, looking in the kube-system namespace first.
+func (c *Client) getClusterCoreDNSWorkload(ctx context.Context) (*workload, error) {
+	coreDNS, err := c.getCoreDNSWorkload(ctx, metav1.NamespaceSystem)
+	if err != nil {
+		return nil, err
+	}
 
-	// Most Kubernetes distributions deploy CoreDNS with the following label, so look for it first.
-	opts := metav1.ListOptions{
-		LabelSelector: "kubernetes.io/name=CoreDNS",
+	if coreDNS != nil {
+		return coreDNS, nil
 	}
 
-	deployments, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).List(ctx, opts)
+	coreDNS, err = c.findCoreDNSWorkload(ctx)
 	if err != nil {
-		return false, fmt.Errorf("unable to list CoreDNS deployments in namespace %q: %w", metav1.NamespaceSystem, err)
+		return nil, err
+	}
+
+	if coreDNS == nil {
+		return nil, errors.New("unable to find CoreDNS")
 	}
 
-	var deployment *appsv1.Deployment
-	if len(deployments.Items) == 1 {
-		deployment = &deployments.Items[0]
+	return coreDNS, nil
+}
+
+// coreDNSMatch checks if a supported version of CoreDNS is deployed in the given namespace, or outside the kube-system
+// namespace if the namespace is empty.
+func (c *Client) coreDNSMatch(ctx context.Context, namespace string) (bool, error) {
+	var (
+		coreDNS *workload
+		err     error
+	)
+
+	if namespace == metav1.NamespaceAll {
+		c.logger.Debug("Checking if CoreDNS is installed in other namespaces...")
+
+		coreDNS, err = c.findCoreDNSWorkload(ctx)
 	} else {
-		// If we did not find CoreDNS using the annotation (e.g.: with kubeadm), fall back to matching the name of the deployment.
-		deployment, err = c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
-		if kerrors.IsNotFound(err) {
-			c.logger.Debug("CoreDNS deployment not found")
-			return false, nil
-		}
+		c.logger.Debugf("Checking if CoreDNS is installed in namespace %q...", namespace)
 
-		if err != nil {
-			return false, fmt.Errorf("unable to get CoreDNS deployment in namespace %q: %w", metav1.NamespaceSystem, err)
-		}
+		coreDNS, err = c.getCoreDNSWorkload(ctx, namespace)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("unable to get CoreDNS: %w", err)
+	}
+
+	if coreDNS == nil {
+		c.logger.Debug("CoreDNS not found")
+		return false, nil
 	}
 
-	version, err := c.getCoreDNSVersion(deployment)
+	version, err := c.getCoreDNSVersion(coreDNS)
 	if err != nil {
 		return false, err
 	}
@@ -126,7 +200,7 @@ func (c *Client) coreDNSMatch(ctx context.Context) (bool, error) {
 		return false, fmt.Errorf("unsupported CoreDNS version %q", version)
 	}
 
-	c.logger.Debugf("CoreDNS %q has been detected", version)
+	c.logger.Debugf("CoreDNS %q has been detected in %s", version, coreDNS)
 
 	return true, nil
 }
@@ -153,12 +227,16 @@ func (c *Client) kubeDNSMatch(ctx context.Context) (bool, error) {
 func (c *Client) ConfigureCoreDNS(ctx context.Context, coreDNSNamespace, clusterDomain, traefikMeshNamespace string) error {
 	c.logger.Debugf("Patching ConfigMap %q in namespace %q...", "coredns", coreDNSNamespace)
 
-	coreDNSDeployment, err := c.kubeClient.AppsV1().Deployments(coreDNSNamespace).Get(ctx, "coredns", metav1.GetOptions{})
+	coreDNS, err := c.getCoreDNSWorkload(ctx, coreDNSNamespace)
 	if err != nil {
 		return err
 	}
 
-	patchedConfigMap, changed, err := c.patchCoreDNSConfig(ctx, coreDNSDeployment, clusterDomain, traefikMeshNamespace)
+	if coreDNS == nil {
+		return fmt.Errorf("unable to find CoreDNS in namespace %q", coreDNSNamespace)
+	}
+
+	patchedConfigMap, changed, err := c.patchCoreDNSConfig(ctx, coreDNS, clusterDomain, traefikMeshNamespace)
 	if err != nil {
 		return fmt.Errorf("unable to patch coredns config: %w", err)
 	}
@@ -169,88 +247,96 @@ func (c *Client) ConfigureCoreDNS(ctx context.Context, coreDNSNamespace, cluster
 		return nil
 	}
 
-	if _, err = c.kubeClient.CoreV1().ConfigMaps(patchedConfigMap.Namespace).Update(ctx, patchedConfigMap, metav1.UpdateOptions{}); err != nil {
+	if err = c.backupConfigMap(ctx, patchedConfigMap, traefikMeshNamespace); err != nil {
 		return err
 	}
 
-	c.logger.Infof("CoreDNS ConfigMap %q in namespace %q has successfully been patched", patchedConfigMap.Name, patchedConfigMap.Namespace)
+	if err = c.updateConfigMap(ctx, coreDNS, patchedConfigMap); err != nil {
+		return err
+	}
 
-	if err := c.restartPods(ctx, coreDNSDeployment); err != nil {
+	if err = c.recordConfigMapPatch(ctx, patchedConfigMap, traefikMeshNamespace); err != nil {
 		return err
 	}
 
+	c.logger.Infof("CoreDNS ConfigMap %q in namespace %q has successfully been patched", patchedConfigMap.Name, patchedConfigMap.Namespace)
+
 	return nil
 }
 
-func (c *Client) patchCoreDNSConfig(ctx context.Context, deployment *appsv1.Deployment, clusterDomain, traefikMeshNamespace string) (*corev1.ConfigMap, bool, error) {
-	coreDNSVersion, err := c.getCoreDNSVersion(deployment)
+func (c *Client) patchCoreDNSConfig(ctx context.Context, coreDNS *workload, clusterDomain, traefikMeshNamespace string) (*corev1.ConfigMap, bool, error) {
+	coreDNSVersion, err := c.getCoreDNSVersion(coreDNS)
 	if err != nil {
 		return nil, false, err
 	}
 
-	customConfigMap, err := c.getConfigMap(ctx, deployment, "coredns-custom")
+	customConfigMap, err := c.getConfigMap(ctx, coreDNS, "coredns-custom")
 
 	// For AKS the CoreDNS config have to be added to the coredns-custom ConfigMap.
 	// See https://docs.microsoft.com/en-us/azure/aks/coredns-custom
 	if err == nil {
-		// deprecated, will be removed in the next major release.
-		corefile, mChanged := addStubDomain(
-			customConfigMap.Data["maesh.server"],
-			maeshBlockHeader,
-			maeshBlockTrailer,
-			maeshDomain,
-			clusterDomain,
-			traefikMeshNamespace,
-			coreDNSVersion,
-		)
-		customConfigMap.Data["maesh.server"] = corefile
+		var changed bool
 
-		corefile, tChanged := addStubDomain(
-			customConfigMap.Data["traefik.mesh.server"],
-			traefikMeshBlockHeader,
-			traefikMeshBlockTrailer,
-			traefikMeshDomain,
-			clusterDomain,
-			traefikMeshNamespace,
-			coreDNSVersion,
-		)
-		customConfigMap.Data["traefik.mesh.server"] = corefile
+		for key, value := range customConfigMap.Data {
+			if len(blockDomains(value)) > 0 && !c.hasDomain(strings.TrimSuffix(key, ".server")) {
+				delete(customConfigMap.Data, key)
 
-		return customConfigMap, mChanged || tChanged, nil
+				changed = true
+			}
+		}
+
+		for _, domain := range c.domains {
+			header, trailer := blockMarkers(domain)
+
+			corefile, dChanged := c.buildStubDomain(
+				customConfigMap.Data[domain+".server"],
+				header,
+				trailer,
+				domain,
+				clusterDomain,
+				traefikMeshNamespace,
+				coreDNSVersion,
+			)
+			customConfigMap.Data[domain+".server"] = corefile
+
+			changed = changed || dChanged
+		}
+
+		return customConfigMap, changed, nil
 	}
 
-	coreDNSConfigMap, err := c.getConfigMap(ctx, deployment, "coredns")
+	coreDNSConfigMap, err := c.getConfigMap(ctx, coreDNS, "coredns")
 	if err != nil {
 		return nil, false, err
 	}
 
-	corefile, mChanged := addStubDomain(
-		coreDNSConfigMap.Data["Corefile"],
-		maeshBlockHeader,
-		maeshBlockTrailer,
-		maeshDomain,
-		clusterDomain,
-		traefikMeshNamespace,
-		coreDNSVersion,
-	)
+	corefile, changed := c.removeStaleBlocks(coreDNSConfigMap.Data["Corefile"])
 
-	corefile, tChanged := addStubDomain(
-		corefile,
-		traefikMeshBlockHeader,
-		traefikMeshBlockTrailer,
-		traefikMeshDomain,
-		clusterDomain,
-		traefikMeshNamespace,
-		coreDNSVersion,
-	)
+	for _, domain := range c.domains {
+		header, trailer := blockMarkers(domain)
+
+		var dChanged bool
+
+		corefile, dChanged = c.buildStubDomain(
+			corefile,
+			header,
+			trailer,
+			domain,
+			clusterDomain,
+			traefikMeshNamespace,
+			coreDNSVersion,
+		)
+
+		changed = changed || dChanged
+	}
 
 	coreDNSConfigMap.Data["Corefile"] = corefile
 
-	return coreDNSConfigMap, mChanged || tChanged, nil
+	return coreDNSConfigMap, changed, nil
 }
 
-func (c *Client) getCoreDNSVersion(deployment *appsv1.Deployment) (*goversion.Version, error) {
-	for _, container := range deployment.Spec.Template.Spec.Containers {
+func (c *Client) getCoreDNSVersion(coreDNS *workload) (*goversion.Version, error) {
+	for _, container := range coreDNS.template().Spec.Containers {
 		if container.Name != "coredns" {
 			continue
 		}
@@ -260,7 +346,17 @@ func (c *Client) getCoreDNSVersion(deployment *appsv1.Deployment) (*goversion.Ve
 		return goversion.NewVersion(parts[len(parts)-1])
 	}
 
-	return nil, fmt.Errorf("unable to get CoreDNS container in deployment %q in namespace %q", deployment.Name, deployment.Namespace)
+	return nil, fmt.Errorf("unable to get CoreDNS container in %s", coreDNS)
+}
+
+// buildStubDomain adds the stub domain for the given mesh domain to the given CoreDNS configuration, forwarding the
+// domain to the configured DNS server if any.
+func (c *Client) buildStubDomain(config, blockHeader, blockTrailer, domain, clusterDomain, traefikMeshNamespace string, coreDNSVersion *goversion.Version) (string, bool) {
+	if c.forwardAddr != "" {
+		return addForwardStubDomain(config, blockHeader, blockTrailer, domain, c.forwardAddr)
+	}
+
+	return addStubDomain(config, blockHeader, blockTrailer, domain, clusterDomain, traefikMeshNamespace, coreDNSVersion)
 }
 
 // ConfigureKubeDNS patches the KubeDNS configuration for Traefik Mesh.
@@ -272,6 +368,18 @@ func (c *Client) ConfigureKubeDNS(ctx context.Context, clusterDomain, traefikMes
 		return err
 	}
 
+	kubeDNS := &workload{deployment: kubeDNSDeployment}
+
+	// KubeDNS stub domains can directly target the DNS server, there is no need for the Traefik Mesh CoreDNS.
+	if c.forwardAddr != "" {
+		configMap, patchErr := c.patchKubeDNSConfig(ctx, kubeDNS, c.forwardAddr)
+		if patchErr != nil {
+			return patchErr
+		}
+
+		return c.patchKubeDNSConfigMap(ctx, kubeDNS, configMap, traefikMeshNamespace)
+	}
+
 	var coreDNSServiceIP string
 
 	c.logger.Debugf("Getting ClusterIP for Service %q in namespace %q", "coredns", traefikMeshNamespace)
@@ -279,7 +387,7 @@ func (c *Client) ConfigureKubeDNS(ctx context.Context, clusterDomain, traefikMes
 	operation := func() error {
 		svc, svcErr := c.kubeClient.CoreV1().Services(traefikMeshNamespace).Get(ctx, "coredns", metav1.GetOptions{})
 		if svcErr != nil {
-			return fmt.Errorf("unable to get CoreDNS service in namespace %q: %w", traefikMeshNamespace, err)
+			return fmt.Errorf("unable to get CoreDNS service in namespace %q: %w", traefikMeshNamespace, svcErr)
 		}
 
 		if svc.Spec.ClusterIP == "" {
@@ -297,141 +405,173 @@ func (c *Client) ConfigureKubeDNS(ctx context.Context, clusterDomain, traefikMes
 
 	c.logger.Debugf("ClusterIP for Service %q in namespace %q is %q", "coredns", traefikMeshNamespace, coreDNSServiceIP)
 
-	if err := c.patchKubeDNSConfig(ctx, kubeDNSDeployment, coreDNSServiceIP); err != nil {
+	configMap, err := c.patchKubeDNSConfig(ctx, kubeDNS, coreDNSServiceIP)
+	if err != nil {
 		return err
 	}
 
+	// The Traefik Mesh CoreDNS has to be ready before KubeDNS starts forwarding the mesh domains to it.
 	if err := c.ConfigureCoreDNS(ctx, traefikMeshNamespace, clusterDomain, traefikMeshNamespace); err != nil {
 		return err
 	}
 
-	if err := c.restartPods(ctx, kubeDNSDeployment); err != nil {
+	return c.patchKubeDNSConfigMap(ctx, kubeDNS, configMap, traefikMeshNamespace)
+}
+
+// patchKubeDNSConfigMap backs up the KubeDNS ConfigMap, writes the given patched version, and records the patch once
+// it has been rolled out.
+func (c *Client) patchKubeDNSConfigMap(ctx context.Context, kubeDNS *workload, configMap *corev1.ConfigMap, traefikMeshNamespace string) error {
+	if err := c.backupConfigMap(ctx, configMap, traefikMeshNamespace); err != nil {
+		return err
+	}
+
+	if err := c.updateConfigMap(ctx, kubeDNS, configMap); err != nil {
 		return err
 	}
 
-	return nil
+	return c.recordConfigMapPatch(ctx, configMap, traefikMeshNamespace)
 }
 
-func (c *Client) patchKubeDNSConfig(ctx context.Context, deployment *appsv1.Deployment, coreDNSServiceIP string) error {
-	configMap, err := c.getOrCreateConfigMap(ctx, deployment, "kube-dns")
+// patchKubeDNSConfig returns the KubeDNS ConfigMap with the mesh domains stub domains pointing to the given address.
+func (c *Client) patchKubeDNSConfig(ctx context.Context, kubeDNS *workload, coreDNSServiceIP string) (*corev1.ConfigMap, error) {
+	configMap, err := c.getOrCreateConfigMap(ctx, kubeDNS, "kube-dns")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	stubDomains := make(map[string][]string)
 
 	if stubDomainsStr := configMap.Data["stubDomains"]; stubDomainsStr != "" {
 		if err = json.Unmarshal([]byte(stubDomainsStr), &stubDomains); err != nil {
-			return fmt.Errorf("unable to unmarshal stub domains: %w", err)
+			return nil, fmt.Errorf("unable to unmarshal stub domains: %w", err)
 		}
 	}
 
 	// Add our stubDomains.
-	// maesh stubDomain is deprecated and will be removed in the next major release.
-	stubDomains["maesh"] = []string{coreDNSServiceIP}
-	stubDomains["traefik.mesh"] = []string{coreDNSServiceIP}
+	for _, domain := range c.domains {
+		stubDomains[domain] = []string{coreDNSServiceIP}
+	}
 
 	configMapData, err := json.Marshal(stubDomains)
 	if err != nil {
-		return fmt.Errorf("unable to marshal stub domains: %w", err)
+		return nil, fmt.Errorf("unable to marshal stub domains: %w", err)
 	}
 
 	configMap.Data["stubDomains"] = string(configMapData)
 
-	if _, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
-		return err
-	}
-
-	return nil
+	return configMap, nil
 }
 
-// restartPods restarts the pods in a given deployment.
-func (c *Client) restartPods(ctx context.Context, deployment *appsv1.Deployment) error {
-	c.logger.Infof("Restarting %q pods", deployment.Name)
+// restartPods restarts the pods of a given workload.
+func (c *Client) restartPods(ctx context.Context, w *workload) error {
+	c.logger.Infof("Restarting %q pods", w.objectMeta().Name)
 
-	annotations := deployment.Spec.Template.Annotations
+	template := w.template()
+
+	annotations := template.Annotations
 	if len(annotations) == 0 {
 		annotations = make(map[string]string)
 	}
 
 	annotations["traefik-mesh-hash"] = uuid.New().String()
-	deployment.Spec.Template.Annotations = annotations
-
-	_, err := c.kubeClient.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+	template.Annotations = annotations
 
-	return err
+	return c.updateWorkload(ctx, w)
 }
 
-// RestoreCoreDNS restores the CoreDNS configuration to pre-install state.
-func (c *Client) RestoreCoreDNS(ctx context.Context) error {
-	coreDNSDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+// RestoreCoreDNS restores the CoreDNS configuration to pre-install state, from the backup stored in the given Traefik
+// Mesh namespace when the configuration was patched.
+func (c *Client) RestoreCoreDNS(ctx context.Context, traefikMeshNamespace string) error {
+	coreDNS, err := c.getClusterCoreDNSWorkload(ctx)
 	if err != nil {
 		return err
 	}
 
-	unpatchedConfigMap, err := c.unpatchCoreDNSConfig(ctx, coreDNSDeployment)
+	unpatchedConfigMap, backup, err := c.unpatchCoreDNSConfig(ctx, coreDNS, traefikMeshNamespace)
 	if err != nil {
 		return fmt.Errorf("unable to unpatch coredns config: %w", err)
 	}
 
-	if _, err = c.kubeClient.CoreV1().ConfigMaps(unpatchedConfigMap.Namespace).Update(ctx, unpatchedConfigMap, metav1.UpdateOptions{}); err != nil {
+	if err = c.updateConfigMap(ctx, coreDNS, unpatchedConfigMap); err != nil {
 		return err
 	}
 
-	if err := c.restartPods(ctx, coreDNSDeployment); err != nil {
-		return err
+	if backup != nil {
+		return c.deleteConfigMapBackup(ctx, backup)
 	}
 
 	return nil
 }
 
-func (c *Client) unpatchCoreDNSConfig(ctx context.Context, deployment *appsv1.Deployment) (*corev1.ConfigMap, error) {
-	coreDNSConfigMap, err := c.getConfigMap(ctx, deployment, "coredns-custom")
-
-	// For AKS the CoreDNS config have to be removed from the coredns-custom ConfigMap.
-	// See https://docs.microsoft.com/en-us/azure/aks/coredns-custom
-	if err == nil {
-		delete(coreDNSConfigMap.Data, "maesh.server")
-		delete(coreDNSConfigMap.Data, "traefik.mesh.server")
+// unpatchCoreDNSConfig returns the patched CoreDNS ConfigMap restored from its backup, along with the backup. Without a
+// backup, e.g. when the configuration has been patched by an older version, the mesh blocks are removed from the
+// ConfigMap.
+func (c *Client) unpatchCoreDNSConfig(ctx context.Context, coreDNS *workload, traefikMeshNamespace string) (*corev1.ConfigMap, *corev1.ConfigMap, error) {
+	coreDNSConfigMap, err := c.getConfigMap(ctx, coreDNS, "coredns-custom")
+	custom := err == nil
 
-		return coreDNSConfigMap, nil
+	if !custom {
+		coreDNSConfigMap, err = c.getConfigMap(ctx, coreDNS, "coredns")
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	coreDNSConfigMap, err = c.getConfigMap(ctx, deployment, "coredns")
+	backup, err := c.restoreFromBackup(ctx, coreDNSConfigMap, traefikMeshNamespace)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	corefile := removeStubDomain(
-		coreDNSConfigMap.Data["Corefile"],
-		maeshBlockHeader,
-		maeshBlockTrailer,
-	)
+	if backup != nil {
+		return coreDNSConfigMap, backup, nil
+	}
 
-	corefile = removeStubDomain(
-		corefile,
-		traefikMeshBlockHeader,
-		traefikMeshBlockTrailer,
-	)
+	// For AKS the CoreDNS config have to be removed from the coredns-custom ConfigMap.
+	// See https://docs.microsoft.com/en-us/azure/aks/coredns-custom
+	if custom {
+		for key, value := range coreDNSConfigMap.Data {
+			if len(blockDomains(value)) > 0 {
+				delete(coreDNSConfigMap.Data, key)
+			}
+		}
 
-	coreDNSConfigMap.Data["Corefile"] = corefile
+		return coreDNSConfigMap, nil, nil
+	}
+
+	coreDNSConfigMap.Data["Corefile"] = removeBlocks(coreDNSConfigMap.Data["Corefile"])
 
-	return coreDNSConfigMap, nil
+	return coreDNSConfigMap, nil, nil
 }
 
-// RestoreKubeDNS restores the KubeDNS configuration to pre-install state.
-func (c *Client) RestoreKubeDNS(ctx context.Context) error {
+// RestoreKubeDNS restores the KubeDNS configuration to pre-install state, from the backup stored in the given Traefik
+// Mesh namespace when the configuration was patched.
+func (c *Client) RestoreKubeDNS(ctx context.Context, traefikMeshNamespace string) error {
 	kubeDNSDeployment, err := c.kubeClient.AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "kube-dns", metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
 
+	kubeDNS := &workload{deployment: kubeDNSDeployment}
+
 	// Get the currently loaded KubeDNS ConfigMap.
-	configMap, err := c.getConfigMap(ctx, kubeDNSDeployment, "kube-dns")
+	configMap, err := c.getConfigMap(ctx, kubeDNS, "kube-dns")
 	if err != nil {
 		return err
 	}
 
+	backup, err := c.restoreFromBackup(ctx, configMap, traefikMeshNamespace)
+	if err != nil {
+		return err
+	}
+
+	if backup != nil {
+		if err = c.updateConfigMap(ctx, kubeDNS, configMap); err != nil {
+			return err
+		}
+
+		return c.deleteConfigMapBackup(ctx, backup)
+	}
+
 	// Check if stubDomains are still defined.
 	stubDomainsStr := configMap.Data["stubDomains"]
 	if stubDomainsStr == "" {
@@ -444,9 +584,9 @@ func (c *Client) RestoreKubeDNS(ctx context.Context) error {
 	}
 
 	// Delete our stubDomains.
-	// maesh stubDomain is deprecated and will be removed in the next major release.
-	delete(stubDomains, "maesh")
-	delete(stubDomains, "traefik.mesh")
+	for _, domain := range c.domains {
+		delete(stubDomains, domain)
+	}
 
 	configMapData, err := json.Marshal(stubDomains)
 	if err != nil {
@@ -455,36 +595,35 @@ func (c *Client) RestoreKubeDNS(ctx context.Context) error {
 
 	configMap.Data["stubDomains"] = string(configMapData)
 
-	if _, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
-		return err
-	}
-
-	if err := c.restartPods(ctx, kubeDNSDeployment); err != nil {
-		return err
-	}
-
-	return nil
+	return c.updateConfigMap(ctx, kubeDNS, configMap)
 }
 
-// getOrCreateConfigMap parses the deployment and returns the ConfigMap with the given name. This method will create the
+// getOrCreateConfigMap parses the workload and returns the ConfigMap with the given name. This method will create the
 // corresponding ConfigMap if the associated volume is marked as optional and the ConfigMap is not found.
-func (c *Client) getOrCreateConfigMap(ctx context.Context, deployment *appsv1.Deployment, name string) (*corev1.ConfigMap, error) {
-	volumeSrc, err := getConfigMapVolumeSource(deployment, name)
+func (c *Client) getOrCreateConfigMap(ctx context.Context, w *workload, name string) (*corev1.ConfigMap, error) {
+	volumeSrc, err := getConfigMapVolumeSource(w, name)
 	if err != nil {
 		return nil, err
 	}
 
-	configMap, err := c.kubeClient.CoreV1().ConfigMaps(deployment.Namespace).Get(ctx, volumeSrc.Name, metav1.GetOptions{})
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(w.objectMeta().Namespace).Get(ctx, volumeSrc.Name, metav1.GetOptions{})
 
 	if kerrors.IsNotFound(err) && volumeSrc.Optional != nil && *volumeSrc.Optional {
 		configMap = &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      name,
-				Namespace: deployment.Namespace,
+				Namespace: w.objectMeta().Namespace,
 			},
 		}
 
-		configMap, err = c.kubeClient.CoreV1().ConfigMaps(deployment.Namespace).Create(ctx, configMap, metav1.CreateOptions{})
+		// In dry-run mode, the creation is reported along with the ConfigMap changes.
+		if c.dryRunOut != nil {
+			configMap.Data = make(map[string]string)
+
+			return configMap, nil
+		}
+
+		configMap, err = c.kubeClient.CoreV1().ConfigMaps(w.objectMeta().Namespace).Create(ctx, configMap, metav1.CreateOptions{})
 	}
 
 	if err != nil {
@@ -498,14 +637,14 @@ func (c *Client) getOrCreateConfigMap(ctx context.Context, deployment *appsv1.De
 	return configMap, err
 }
 
-// getConfigMap parses the deployment and returns the ConfigMap with the given name.
-func (c *Client) getConfigMap(ctx context.Context, deployment *appsv1.Deployment, name string) (*corev1.ConfigMap, error) {
-	volumeSrc, err := getConfigMapVolumeSource(deployment, name)
+// getConfigMap parses the workload and returns the ConfigMap with the given name.
+func (c *Client) getConfigMap(ctx context.Context, w *workload, name string) (*corev1.ConfigMap, error) {
+	volumeSrc, err := getConfigMapVolumeSource(w, name)
 	if err != nil {
 		return nil, err
 	}
 
-	configMap, err := c.kubeClient.CoreV1().ConfigMaps(deployment.Namespace).Get(ctx, volumeSrc.Name, metav1.GetOptions{})
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(w.objectMeta().Namespace).Get(ctx, volumeSrc.Name, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -518,8 +657,8 @@ func (c *Client) getConfigMap(ctx context.Context, deployment *appsv1.Deployment
 }
 
 // getConfigMapVolumeSource returns the ConfigMapVolumeSource corresponding to the ConfigMap with the given name.
-func getConfigMapVolumeSource(deployment *appsv1.Deployment, name string) (*corev1.ConfigMapVolumeSource, error) {
-	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+func getConfigMapVolumeSource(w *workload, name string) (*corev1.ConfigMapVolumeSource, error) {
+	for _, volume := range w.template().Spec.Volumes {
 		if volume.ConfigMap == nil {
 			continue
 		}
@@ -552,11 +691,13 @@ func addStubDomain(config, blockHeader, blockTrailer, domain, clusterDomain, tra
 		config = removeStubDomain(config, blockHeader, blockTrailer)
 	}
 
+	// The rewrite rules compute the name of the shadow services named after their service. The shadow services whose
+	// name is bounded with a hash can only be resolved by the embedded DNS server.
 	stubDomainFormat := `%[4]s
 %[7]s:53 {
     errors
     rewrite continue {
-        name regex ([a-zA-Z0-9-_]*)\.([a-zv0-9-_]*)\.%[7]s %[3]s-{1}-6d61657368-{2}.%[3]s.svc.%[1]s
+        name regex ([a-zA-Z0-9-_]*)\.([a-zv0-9-_]*)\.%[8]s %[3]s-{1}-6d61657368-{2}.%[3]s.svc.%[1]s
         answer name %[3]s-([a-zA-Z0-9-_]*)-6d61657368-([a-zA-Z0-9-_]*)\.%[3]s\.svc\.%[2]s {1}.{2}.%[7]s
     }
     kubernetes %[1]s in-addr.arpa ip6.arpa {
@@ -580,19 +721,47 @@ func addStubDomain(config, blockHeader, blockTrailer, domain, clusterDomain, tra
 
 	stubDomain := fmt.Sprintf(stubDomainFormat,
 		clusterDomain,
-		strings.ReplaceAll(clusterDomain, ".", "\\."),
+		regexp.QuoteMeta(clusterDomain),
 		traefikMeshNamespace,
 		blockHeader,
 		blockTrailer,
 		upstream,
 		domain,
+		regexp.QuoteMeta(domain),
+	)
+
+	return config + "\n" + stubDomain + "\n", existingStubDomain != stubDomain
+}
+
+func addForwardStubDomain(config, blockHeader, blockTrailer, domain, forwardAddr string) (string, bool) {
+	existingStubDomain := getStubDomain(config, blockHeader, blockTrailer)
+
+	if existingStubDomain != "" {
+		config = removeStubDomain(config, blockHeader, blockTrailer)
+	}
+
+	stubDomainFormat := `%[1]s
+%[3]s:53 {
+    errors
+    forward . %[4]s
+    cache 30
+    reload
+}
+%[2]s`
+
+	stubDomain := fmt.Sprintf(stubDomainFormat,
+		blockHeader,
+		blockTrailer,
+		domain,
+		forwardAddr,
 	)
 
 	return config + "\n" + stubDomain + "\n", existingStubDomain != stubDomain
 }
 
 func removeStubDomain(config, blockHeader, blockTrailer string) string {
-	if !strings.Contains(config, blockHeader) {
+	// Without its trailer, the end of the block is unknown, and removing anything could corrupt the config.
+	if !strings.Contains(config, blockHeader) || !strings.Contains(config, blockTrailer) {
 		return config
 	}
 	// Split the data on the header, and save the pre-header data.
@@ -600,12 +769,7 @@ func removeStubDomain(config, blockHeader, blockTrailer string) string {
 	preData := splitData[0]
 
 	// Split the data on the trailer, and save the post-header data.
-	postData := ""
-
-	splitData = strings.SplitN(config, blockTrailer+"\n", 2)
-	if len(splitData) > 1 {
-		postData = splitData[1]
-	}
+	_, postData, _ := strings.Cut(config, blockTrailer)
 
-	return preData + postData
+	return preData + strings.TrimPrefix(postData, "\n")
 }
@@ -0,0 +1,127 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultDomains are the mesh domains used when none are configured. The maesh domain is deprecated, and will be
+// removed in the next major release.
+var defaultDomains = []string{traefikMeshDomain, maeshDomain}
+
+var (
+	domainLabelRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	blockHeaderRegexp = regexp.MustCompile(`#### Begin Traefik Mesh (\S+) Block`)
+)
+
+// DefaultDomains returns the mesh domains used when none are configured.
+func DefaultDomains() []string {
+	return append([]string(nil), defaultDomains...)
+}
+
+// Domains configures the mesh domains served by the cluster DNS. The first one is the main mesh domain.
+func Domains(domains ...string) ClientOption {
+	return func(c *Client) {
+		c.domains = domains
+	}
+}
+
+// ValidateDomains checks that the given mesh domains are valid DNS names, and that none of them is a subdomain of
+// another one, as a name could then belong to both.
+func ValidateDomains(domains []string) error {
+	if len(domains) == 0 {
+		return errors.New("at least one mesh domain is required")
+	}
+
+	for i, domain := range domains {
+		if len(domain) > 253 {
+			return fmt.Errorf("mesh domain %q is too long", domain)
+		}
+
+		for _, label := range strings.Split(domain, ".") {
+			if !domainLabelRegexp.MatchString(label) {
+				return fmt.Errorf("mesh domain %q is not a valid lowercase DNS name", domain)
+			}
+		}
+
+		for _, other := range domains[:i] {
+			if domain == other || strings.HasSuffix(domain, "."+other) || strings.HasSuffix(other, "."+domain) {
+				return fmt.Errorf("mesh domains %q and %q overlap", other, domain)
+			}
+		}
+	}
+
+	return nil
+}
+
+// blockMarkers returns the header and the trailer delimiting the configuration of the given mesh domain. The default
+// mesh domains keep the markers written by the previous versions.
+func blockMarkers(domain string) (string, string) {
+	switch domain {
+	case maeshDomain:
+		return maeshBlockHeader, maeshBlockTrailer
+	case traefikMeshDomain:
+		return traefikMeshBlockHeader, traefikMeshBlockTrailer
+	default:
+		return fmt.Sprintf("#### Begin Traefik Mesh %s Block", domain), fmt.Sprintf("#### End Traefik Mesh %s Block", domain)
+	}
+}
+
+// blockDomains returns the mesh domains having a block in the given configuration.
+func blockDomains(config string) []string {
+	var domains []string
+
+	if strings.Contains(config, traefikMeshBlockHeader) {
+		domains = append(domains, traefikMeshDomain)
+	}
+
+	if strings.Contains(config, maeshBlockHeader) {
+		domains = append(domains, maeshDomain)
+	}
+
+	for _, match := range blockHeaderRegexp.FindAllStringSubmatch(config, -1) {
+		domains = append(domains, match[1])
+	}
+
+	return domains
+}
+
+// removeStaleBlocks removes from the given configuration the blocks of the mesh domains which are not configured
+// anymore, and returns true if there was any.
+func (c *Client) removeStaleBlocks(config string) (string, bool) {
+	var removed bool
+
+	for _, domain := range blockDomains(config) {
+		if c.hasDomain(domain) {
+			continue
+		}
+
+		header, trailer := blockMarkers(domain)
+		config = removeStubDomain(config, header, trailer)
+		removed = true
+	}
+
+	return config, removed
+}
+
+// removeBlocks removes from the given configuration the blocks of all the mesh domains.
+func removeBlocks(config string) string {
+	for _, domain := range blockDomains(config) {
+		header, trailer := blockMarkers(domain)
+		config = removeStubDomain(config, header, trailer)
+	}
+
+	return config
+}
+
+func (c *Client) hasDomain(domain string) bool {
+	for _, d := range c.domains {
+		if d == domain {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package dns
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateDomains(t *testing.T) {
+	tests := []struct {
+		desc    string
+		domains []string
+		expErr  bool
+	}{
+		{
+			desc:    "Default domains",
+			domains: []string{"traefik.mesh", "maesh"},
+		},
+		{
+			desc:    "Custom domain",
+			domains: []string{"svc.mesh.internal"},
+		},
+		{
+			desc:   "No domain",
+			expErr: true,
+		},
+		{
+			desc:    "Empty domain",
+			domains: []string{""},
+			expErr:  true,
+		},
+		{
+			desc:    "Uppercase domain",
+			domains: []string{"Traefik.Mesh"},
+			expErr:  true,
+		},
+		{
+			desc:    "Invalid label",
+			domains: []string{"mesh-.internal"},
+			expErr:  true,
+		},
+		{
+			desc:    "Trailing dot",
+			domains: []string{"traefik.mesh."},
+			expErr:  true,
+		},
+		{
+			desc:    "Duplicated domain",
+			domains: []string{"traefik.mesh", "traefik.mesh"},
+			expErr:  true,
+		},
+		{
+			desc:    "Subdomain of another domain",
+			domains: []string{"mesh", "traefik.mesh"},
+			expErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateDomains(test.domains)
+			if test.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfigureCoreDNS_customDomain(t *testing.T) {
+	tests := []struct {
+		desc      string
+		mockFile  string
+		configMap string
+		key       string
+	}{
+		{
+			desc:      "Corefile patched for the default domains",
+			mockFile:  "configurecoredns_already_patched.yaml",
+			configMap: "coredns",
+			key:       "Corefile",
+		},
+		{
+			desc:      "CoreDNS custom config patched for the default domains",
+			mockFile:  "configurecoredns_custom_already_patched.yaml",
+			configMap: "coredns-custom",
+			key:       "svc.mesh.internal.server",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+			configMaps := k8sClient.KubernetesClient().CoreV1().ConfigMaps("kube-system")
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			client := NewClient(log, k8sClient.KubernetesClient(), Domains("svc.mesh.internal"))
+
+			require.NoError(t, client.ConfigureCoreDNS(ctx, "kube-system", "titi", "toto"))
+
+			cfgMap, err := configMaps.Get(ctx, test.configMap, metav1.GetOptions{})
+			require.NoError(t, err)
+
+			// The blocks of the domains which are not configured anymore are removed.
+			for _, value := range cfgMap.Data {
+				assert.NotContains(t, value, traefikMeshBlockHeader)
+				assert.NotContains(t, value, maeshBlockHeader)
+			}
+
+			assert.NotContains(t, cfgMap.Data, "traefik.mesh.server")
+			assert.NotContains(t, cfgMap.Data, "maesh.server")
+
+			block := cfgMap.Data[test.key]
+			assert.Contains(t, block, "#### Begin Traefik Mesh svc.mesh.internal Block\nsvc.mesh.internal:53 {\n")
+			assert.Contains(t, block, "name regex ([a-zA-Z0-9-_]*)\\.([a-zv0-9-_]*)\\.svc\\.mesh\\.internal toto-{1}-6d61657368-{2}.toto.svc.titi\n")
+			assert.Contains(t, block, "}\n#### End Traefik Mesh svc.mesh.internal Block\n")
+			assert.NoError(t, validateCorefile(block))
+
+			// Without a backup, the restore removes the blocks of any mesh domain.
+			require.NoError(t, k8sClient.KubernetesClient().CoreV1().ConfigMaps("toto").Delete(ctx, backupName("kube-system", test.configMap), metav1.DeleteOptions{}))
+			require.NoError(t, client.RestoreCoreDNS(ctx, "toto"))
+
+			cfgMap, err = configMaps.Get(ctx, test.configMap, metav1.GetOptions{})
+			require.NoError(t, err)
+
+			for _, value := range cfgMap.Data {
+				assert.NotContains(t, value, "svc.mesh.internal")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pmezard/go-difflib/difflib"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DryRun makes the Client print the changes it would make to the DNS configuration to the given writer, as unified
+// diffs, instead of applying them.
+func DryRun(out io.Writer) ClientOption {
+	return func(c *Client) {
+		c.dryRunOut = out
+	}
+}
+
+// printConfigMapChanges prints the diff between the live ConfigMap and the given one, and the workload which would be
+// restarted to load it, if any.
+func (c *Client) printConfigMapChanges(ctx context.Context, w *workload, configMap *corev1.ConfigMap) error {
+	var previousData map[string]string
+
+	current, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Get(ctx, configMap.Name, metav1.GetOptions{})
+	switch {
+	case kerrors.IsNotFound(err):
+		fmt.Fprintf(c.dryRunOut, "Would create ConfigMap %s/%s\n", configMap.Namespace, configMap.Name)
+	case err != nil:
+		return fmt.Errorf("unable to get ConfigMap %q in namespace %q: %w", configMap.Name, configMap.Namespace, err)
+	default:
+		previousData = current.Data
+	}
+
+	if err = validateConfigMapChanges(configMap, previousData); err != nil {
+		return err
+	}
+
+	diff, err := configMapDiff(configMap, previousData)
+	if err != nil {
+		return err
+	}
+
+	if diff == "" {
+		fmt.Fprintf(c.dryRunOut, "ConfigMap %s/%s is unchanged\n", configMap.Namespace, configMap.Name)
+		return nil
+	}
+
+	fmt.Fprint(c.dryRunOut, diff)
+
+	if w == nil {
+		return nil
+	}
+
+	if _, reload := c.reloadMetricsPort(configMap, previousData); reload {
+		fmt.Fprintf(c.dryRunOut, "Would let the reload plugin of %s load ConfigMap %s/%s\n", w, configMap.Namespace, configMap.Name)
+		return nil
+	}
+
+	fmt.Fprintf(c.dryRunOut, "Would restart the pods of %s\n", w)
+
+	return nil
+}
+
+// configMapDiff returns the unified diff of each entry of the given ConfigMap which differs from the given previous
+// data, or an empty string if there is none.
+func configMapDiff(configMap *corev1.ConfigMap, previousData map[string]string) (string, error) {
+	keySet := make(map[string]struct{})
+
+	for key := range previousData {
+		keySet[key] = struct{}{}
+	}
+
+	for key := range configMap.Data {
+		keySet[key] = struct{}{}
+	}
+
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var result string
+
+	for _, key := range keys {
+		previous, next := previousData[key], configMap.Data[key]
+		if previous == next {
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(previous),
+			B:        difflib.SplitLines(next),
+			FromFile: fmt.Sprintf("a/%s/%s/%s", configMap.Namespace, configMap.Name, key),
+			ToFile:   fmt.Sprintf("b/%s/%s/%s", configMap.Namespace, configMap.Name, key),
+			Context:  3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to diff %q in ConfigMap %q in namespace %q: %w", key, configMap.Name, configMap.Namespace, err)
+		}
+
+		result += diff
+	}
+
+	return result, nil
+}
@@ -0,0 +1,196 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		desc        string
+		mockFile    string
+		forwardAddr string
+		run         func(ctx context.Context, client *Client) error
+		configMaps  []string
+		workload    []string
+		expOutput   []string
+	}{
+		{
+			desc:     "Configure CoreDNS",
+			mockFile: "configurecoredns_not_patched.yaml",
+			run: func(ctx context.Context, client *Client) error {
+				return client.ConfigureCoreDNS(ctx, metav1.NamespaceSystem, "titi", "toto")
+			},
+			configMaps: []string{"kube-system/coredns"},
+			workload:   []string{"Deployment", "kube-system", "coredns"},
+			expOutput: []string{
+				"--- a/kube-system/coredns/Corefile\n+++ b/kube-system/coredns/Corefile\n",
+				"Would let the reload plugin of Deployment kube-system/coredns load ConfigMap kube-system/coredns\n",
+			},
+		},
+		{
+			desc:     "Configure CoreDNS custom config",
+			mockFile: "configurecoredns_custom_not_patched.yaml",
+			run: func(ctx context.Context, client *Client) error {
+				return client.ConfigureCoreDNS(ctx, metav1.NamespaceSystem, "titi", "toto")
+			},
+			configMaps: []string{"kube-system/coredns", "kube-system/coredns-custom"},
+			workload:   []string{"Deployment", "kube-system", "coredns"},
+			expOutput: []string{
+				"--- a/kube-system/coredns-custom/traefik.mesh.server\n+++ b/kube-system/coredns-custom/traefik.mesh.server\n",
+				"Would restart the pods of Deployment kube-system/coredns\n",
+			},
+		},
+		{
+			desc:     "Configure CoreDNS in a custom namespace",
+			mockFile: "configurecoredns_custom_namespace.yaml",
+			run: func(ctx context.Context, client *Client) error {
+				return client.ConfigureCoreDNS(ctx, "dns-system", "cluster.local", "traefik-mesh")
+			},
+			configMaps: []string{"dns-system/coredns"},
+			workload:   []string{"Deployment", "dns-system", "dns"},
+			expOutput:  []string{"Would restart the pods of Deployment dns-system/dns\n"},
+		},
+		{
+			desc:        "Configure KubeDNS forwarding to a DNS server",
+			mockFile:    "configurekubedns_not_patched.yaml",
+			forwardAddr: "10.0.0.10:5353",
+			run: func(ctx context.Context, client *Client) error {
+				return client.ConfigureKubeDNS(ctx, "cluster.local", "traefik-mesh")
+			},
+			configMaps: []string{"kube-system/kube-dns"},
+			workload:   []string{"Deployment", "kube-system", "kube-dns"},
+			expOutput: []string{
+				"--- a/kube-system/kube-dns/stubDomains\n+++ b/kube-system/kube-dns/stubDomains\n",
+				"Would restart the pods of Deployment kube-system/kube-dns\n",
+			},
+		},
+		{
+			desc:        "Configure KubeDNS with an optional ConfigMap",
+			mockFile:    "configurekubedns_optional_configmap.yaml",
+			forwardAddr: "10.0.0.10:5353",
+			run: func(ctx context.Context, client *Client) error {
+				return client.ConfigureKubeDNS(ctx, "cluster.local", "traefik-mesh")
+			},
+			configMaps: []string{"kube-system/kube-dns"},
+			workload:   []string{"Deployment", "kube-system", "kube-dns"},
+			expOutput:  []string{"Would create ConfigMap kube-system/kube-dns\n"},
+		},
+		{
+			desc:     "Restore CoreDNS",
+			mockFile: "restorecoredns_patched.yaml",
+			run: func(ctx context.Context, client *Client) error {
+				return client.RestoreCoreDNS(ctx, "traefik-mesh")
+			},
+			configMaps: []string{"kube-system/coredns"},
+			workload:   []string{"Deployment", "kube-system", "coredns"},
+			expOutput:  []string{"-#### Begin Maesh Block\n"},
+		},
+		{
+			desc:     "Restore KubeDNS",
+			mockFile: "restorekubedns_already_patched.yaml",
+			run: func(ctx context.Context, client *Client) error {
+				return client.RestoreKubeDNS(ctx, "traefik-mesh")
+			},
+			configMaps: []string{"kube-system/kube-dns"},
+			workload:   []string{"Deployment", "kube-system", "kube-dns"},
+			expOutput:  []string{"Would restart the pods of Deployment kube-system/kube-dns\n"},
+		},
+		{
+			desc:     "Configure NodeLocal DNSCache",
+			mockFile: "configurenodelocaldns_not_patched.yaml",
+			run: func(ctx context.Context, client *Client) error {
+				return client.ConfigureNodeLocalDNS(ctx, "cluster.local", "traefik-mesh")
+			},
+			configMaps: []string{"kube-system/node-local-dns"},
+			workload:   []string{"DaemonSet", "kube-system", "node-local-dns"},
+			expOutput:  []string{"+#### Begin Traefik Mesh Block\n"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			var opts []ClientOption
+			if test.forwardAddr != "" {
+				opts = append(opts, ForwardTo(test.forwardAddr))
+			}
+
+			before := getConfigMaps(ctx, t, k8sClient, test.configMaps)
+
+			var out bytes.Buffer
+
+			dryRunClient := NewClient(log, k8sClient.KubernetesClient(), append(opts, DryRun(&out))...)
+			require.NoError(t, test.run(ctx, dryRunClient))
+
+			for _, expOutput := range test.expOutput {
+				assert.Contains(t, out.String(), expOutput)
+			}
+
+			// Nothing has been written.
+			assert.Equal(t, before, getConfigMaps(ctx, t, k8sClient, test.configMaps))
+			assert.False(t, isRestarted(ctx, t, k8sClient, test.workload[0], test.workload[1], test.workload[2]))
+
+			backups, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps("traefik-mesh").List(ctx, metav1.ListOptions{LabelSelector: "component=dns-backup"})
+			require.NoError(t, err)
+			assert.Empty(t, backups.Items)
+
+			// The printed diffs are the ones of the real change.
+			client := NewClient(log, k8sClient.KubernetesClient(), opts...)
+			require.NoError(t, test.run(ctx, client))
+
+			after := getConfigMaps(ctx, t, k8sClient, test.configMaps)
+
+			for _, key := range test.configMaps {
+				diff, err := configMapDiff(after[key], before[key].Data)
+				require.NoError(t, err)
+
+				assert.Contains(t, out.String(), diff)
+			}
+		})
+	}
+}
+
+// getConfigMaps returns the ConfigMaps with the given namespace/name keys, with an empty ConfigMap for the missing ones.
+func getConfigMaps(ctx context.Context, t *testing.T, k8sClient *k8s.ClientMock, keys []string) map[string]*corev1.ConfigMap {
+	t.Helper()
+
+	configMaps := make(map[string]*corev1.ConfigMap)
+
+	for _, key := range keys {
+		namespace, name, _ := strings.Cut(key, "/")
+
+		configMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		} else {
+			require.NoError(t, err)
+		}
+
+		configMaps[key] = configMap
+	}
+
+	return configMaps
+}
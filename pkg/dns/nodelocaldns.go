@@ -0,0 +1,227 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeLocalDNSName is the name of the NodeLocal DNSCache ConfigMap and DaemonSet.
+const nodeLocalDNSName = "node-local-dns"
+
+var (
+	bindRegexp    = regexp.MustCompile(`(?m)^\s*bind\s+([^\n]+?)\s*$`)
+	forwardRegexp = regexp.MustCompile(`(?m)^\s*forward\s+\.\s+([^{\n]+?)\s*\{?\s*$`)
+)
+
+// CheckNodeLocalDNS checks if NodeLocal DNSCache is deployed in the cluster.
+func (c *Client) CheckNodeLocalDNS(ctx context.Context) (bool, error) {
+	c.logger.Debugf("Checking if NodeLocal DNSCache is installed in namespace %q...", metav1.NamespaceSystem)
+
+	_, err := c.kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, nodeLocalDNSName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		c.logger.Debug("NodeLocal DNSCache ConfigMap not found")
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("unable to get NodeLocal DNSCache ConfigMap in namespace %q: %w", metav1.NamespaceSystem, err)
+	}
+
+	c.logger.Debug("NodeLocal DNSCache has been detected")
+
+	return true, nil
+}
+
+// ConfigureNodeLocalDNS patches the NodeLocal DNSCache configuration for Traefik Mesh. NodeLocal DNSCache forwards the
+// queries outside the cluster domain to the upstream servers, so the mesh domains have to be forwarded to the cluster
+// DNS, the same way the cluster domain is, or to the configured DNS server. The original configuration is backed up in
+// the given Traefik Mesh namespace.
+func (c *Client) ConfigureNodeLocalDNS(ctx context.Context, clusterDomain, traefikMeshNamespace string) error {
+	c.logger.Debugf("Patching ConfigMap %q in namespace %q...", nodeLocalDNSName, metav1.NamespaceSystem)
+
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, nodeLocalDNSName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+
+	corefile, changed, err := c.patchNodeLocalDNSCorefile(configMap.Data["Corefile"], clusterDomain)
+	if err != nil {
+		return fmt.Errorf("unable to patch NodeLocal DNSCache config: %w", err)
+	}
+
+	if !changed {
+		c.logger.Infof("NodeLocal DNSCache ConfigMap %q in namespace %q has already been patched", configMap.Name, configMap.Namespace)
+
+		return nil
+	}
+
+	configMap.Data["Corefile"] = corefile
+
+	if err = c.backupConfigMap(ctx, configMap, traefikMeshNamespace); err != nil {
+		return err
+	}
+
+	if err = c.updateNodeLocalDNSConfigMap(ctx, configMap); err != nil {
+		return err
+	}
+
+	if err = c.recordConfigMapPatch(ctx, configMap, traefikMeshNamespace); err != nil {
+		return err
+	}
+
+	c.logger.Infof("NodeLocal DNSCache ConfigMap %q in namespace %q has successfully been patched", configMap.Name, configMap.Namespace)
+
+	return nil
+}
+
+// RestoreNodeLocalDNS restores the NodeLocal DNSCache configuration to pre-install state, from the backup stored in the
+// given Traefik Mesh namespace when the configuration was patched.
+func (c *Client) RestoreNodeLocalDNS(ctx context.Context, traefikMeshNamespace string) error {
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, nodeLocalDNSName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	backup, err := c.restoreFromBackup(ctx, configMap, traefikMeshNamespace)
+	if err != nil {
+		return err
+	}
+
+	if backup != nil {
+		if err = c.updateNodeLocalDNSConfigMap(ctx, configMap); err != nil {
+			return err
+		}
+
+		return c.deleteConfigMapBackup(ctx, backup)
+	}
+
+	corefile := configMap.Data["Corefile"]
+	if len(blockDomains(corefile)) == 0 {
+		return nil
+	}
+
+	configMap.Data["Corefile"] = removeBlocks(corefile)
+
+	return c.updateNodeLocalDNSConfigMap(ctx, configMap)
+}
+
+// updateNodeLocalDNSConfigMap writes the given NodeLocal DNSCache ConfigMap, and makes the NodeLocal DNSCache workload
+// load it.
+func (c *Client) updateNodeLocalDNSConfigMap(ctx context.Context, configMap *corev1.ConfigMap) error {
+	nodeLocalDNS, err := c.getWorkload(ctx, metav1.NamespaceSystem, nodeLocalDNSName)
+	if err != nil {
+		return err
+	}
+
+	if c.dryRunOut != nil {
+		return c.printConfigMapChanges(ctx, nodeLocalDNS, configMap)
+	}
+
+	if nodeLocalDNS != nil {
+		return c.updateConfigMap(ctx, nodeLocalDNS, configMap)
+	}
+
+	// Without a workload to restart, the configuration is picked up by the reload plugin.
+	c.logger.Debugf("NodeLocal DNSCache workload %q not found in namespace %q", nodeLocalDNSName, metav1.NamespaceSystem)
+
+	if err = validateConfigMapChanges(configMap, nil); err != nil {
+		return err
+	}
+
+	_, err = c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+
+	return err
+}
+
+// patchNodeLocalDNSCorefile adds the mesh domains stub domains to the given NodeLocal DNSCache Corefile. They listen on
+// the addresses of the cluster domain server block, and forward to its upstream unless a DNS server is configured.
+func (c *Client) patchNodeLocalDNSCorefile(corefile, clusterDomain string) (string, bool, error) {
+	serverBlock := getServerBlock(corefile, clusterDomain)
+	if serverBlock == "" {
+		return "", false, fmt.Errorf("unable to find the %q server block", clusterDomain)
+	}
+
+	bind := bindRegexp.FindStringSubmatch(serverBlock)
+	if bind == nil {
+		return "", false, fmt.Errorf("unable to find the bind addresses of the %q server block", clusterDomain)
+	}
+
+	upstream := c.forwardAddr
+	if upstream == "" {
+		forward := forwardRegexp.FindStringSubmatch(serverBlock)
+		if forward == nil {
+			return "", false, fmt.Errorf("unable to find the upstream of the %q server block", clusterDomain)
+		}
+
+		upstream = forward[1]
+	}
+
+	corefile, changed := c.removeStaleBlocks(corefile)
+
+	for _, domain := range c.domains {
+		header, trailer := blockMarkers(domain)
+
+		var dChanged bool
+
+		corefile, dChanged = addNodeLocalStubDomain(corefile, header, trailer, domain, bind[1], upstream)
+		changed = changed || dChanged
+	}
+
+	return corefile, changed, nil
+}
+
+// getServerBlock returns the server block of the given zone in the given Corefile, or an empty string if there is none.
+func getServerBlock(corefile, zone string) string {
+	start := strings.Index(corefile, zone+":53 {")
+	if start == -1 {
+		return ""
+	}
+
+	end := strings.Index(corefile[start:], "\n}")
+	if end == -1 {
+		return ""
+	}
+
+	return corefile[start : start+end+2]
+}
+
+func addNodeLocalStubDomain(config, blockHeader, blockTrailer, domain, bind, upstream string) (string, bool) {
+	existingStubDomain := getStubDomain(config, blockHeader, blockTrailer)
+
+	if existingStubDomain != "" {
+		config = removeStubDomain(config, blockHeader, blockTrailer)
+	}
+
+	stubDomainFormat := `%[1]s
+%[3]s:53 {
+    errors
+    cache 30
+    reload
+    loop
+    bind %[4]s
+    forward . %[5]s {
+        force_tcp
+    }
+}
+%[2]s`
+
+	stubDomain := fmt.Sprintf(stubDomainFormat,
+		blockHeader,
+		blockTrailer,
+		domain,
+		bind,
+		upstream,
+	)
+
+	return config + "\n" + stubDomain + "\n", existingStubDomain != stubDomain
+}
@@ -0,0 +1,249 @@
+package dns
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const nodeLocalDNSCorefile = `cluster.local:53 {
+    errors
+    cache {
+        success 9984 30
+        denial 9984 5
+    }
+    reload
+    loop
+    bind 169.254.20.10 10.96.0.10
+    forward . __PILLAR__CLUSTER__DNS__ {
+        force_tcp
+    }
+    prometheus :9253
+    health 169.254.20.10:8080
+}
+.:53 {
+    errors
+    cache 30
+    reload
+    loop
+    bind 169.254.20.10 10.96.0.10
+    forward . __PILLAR__UPSTREAM__SERVERS__
+    prometheus :9253
+}
+`
+
+const nodeLocalDNSStubDomains = `
+#### Begin Traefik Mesh Block
+traefik.mesh:53 {
+    errors
+    cache 30
+    reload
+    loop
+    bind 169.254.20.10 10.96.0.10
+    forward . __PILLAR__CLUSTER__DNS__ {
+        force_tcp
+    }
+}
+#### End Traefik Mesh Block
+
+#### Begin Maesh Block
+maesh:53 {
+    errors
+    cache 30
+    reload
+    loop
+    bind 169.254.20.10 10.96.0.10
+    forward . __PILLAR__CLUSTER__DNS__ {
+        force_tcp
+    }
+}
+#### End Maesh Block
+`
+
+func TestCheckNodeLocalDNS(t *testing.T) {
+	tests := []struct {
+		desc     string
+		mockFile string
+		expMatch bool
+	}{
+		{
+			desc:     "NodeLocal DNSCache",
+			mockFile: "configurenodelocaldns_not_patched.yaml",
+			expMatch: true,
+		},
+		{
+			desc:     "No NodeLocal DNSCache",
+			mockFile: "checkdnsprovider_supported_version.yaml",
+			expMatch: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			client := NewClient(log, k8sClient.KubernetesClient())
+
+			match, err := client.CheckNodeLocalDNS(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, test.expMatch, match)
+		})
+	}
+}
+
+func TestConfigureNodeLocalDNS(t *testing.T) {
+	tests := []struct {
+		desc        string
+		mockFile    string
+		forwardAddr string
+		expCorefile string
+		expErr      bool
+		expRestart  bool
+	}{
+		{
+			desc:        "First time config of NodeLocal DNSCache",
+			mockFile:    "configurenodelocaldns_not_patched.yaml",
+			expCorefile: nodeLocalDNSCorefile + nodeLocalDNSStubDomains,
+			expRestart:  false,
+		},
+		{
+			desc:        "Already patched NodeLocal DNSCache config",
+			mockFile:    "configurenodelocaldns_already_patched.yaml",
+			expCorefile: nodeLocalDNSCorefile + nodeLocalDNSStubDomains,
+			expRestart:  false,
+		},
+		{
+			desc:        "NodeLocal DNSCache forwarding to a DNS server",
+			mockFile:    "configurenodelocaldns_not_patched.yaml",
+			forwardAddr: "10.0.0.10:5353",
+			expCorefile: nodeLocalDNSCorefile + strings.ReplaceAll(nodeLocalDNSStubDomains, "__PILLAR__CLUSTER__DNS__", "10.0.0.10:5353"),
+			expRestart:  false,
+		},
+		{
+			desc:        "NodeLocal DNSCache without DaemonSet",
+			mockFile:    "configurenodelocaldns_without_daemonset.yaml",
+			expCorefile: nodeLocalDNSCorefile + nodeLocalDNSStubDomains,
+		},
+		{
+			desc:     "NodeLocal DNSCache config without cluster domain server block",
+			mockFile: "configurenodelocaldns_missing_cluster_domain.yaml",
+			expErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			var opts []ClientOption
+			if test.forwardAddr != "" {
+				opts = append(opts, ForwardTo(test.forwardAddr))
+			}
+
+			client := NewClient(log, k8sClient.KubernetesClient(), opts...)
+
+			err := client.ConfigureNodeLocalDNS(ctx, "cluster.local", "traefik-mesh")
+			if test.expErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			cfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps("kube-system").Get(ctx, "node-local-dns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expCorefile, cfgMap.Data["Corefile"])
+
+			daemonSet, err := k8sClient.KubernetesClient().AppsV1().DaemonSets("kube-system").Get(ctx, "node-local-dns", metav1.GetOptions{})
+			if err != nil {
+				assert.False(t, test.expRestart)
+				return
+			}
+
+			restarted := daemonSet.Spec.Template.Annotations["traefik-mesh-hash"] != ""
+			assert.Equal(t, test.expRestart, restarted)
+		})
+	}
+}
+
+func TestRestoreNodeLocalDNS(t *testing.T) {
+	tests := []struct {
+		desc        string
+		mockFile    string
+		expCorefile string
+		expRestart  bool
+	}{
+		{
+			desc:        "NodeLocal DNSCache config patched",
+			mockFile:    "restorenodelocaldns_patched.yaml",
+			expCorefile: nodeLocalDNSCorefile + "\n\n",
+			expRestart:  false,
+		},
+		{
+			desc:        "NodeLocal DNSCache config not patched",
+			mockFile:    "restorenodelocaldns_not_patched.yaml",
+			expCorefile: nodeLocalDNSCorefile,
+			expRestart:  false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			client := NewClient(log, k8sClient.KubernetesClient())
+
+			err := client.RestoreNodeLocalDNS(ctx, "traefik-mesh")
+			require.NoError(t, err)
+
+			cfgMap, err := k8sClient.KubernetesClient().CoreV1().ConfigMaps("kube-system").Get(ctx, "node-local-dns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expCorefile, cfgMap.Data["Corefile"])
+
+			daemonSet, err := k8sClient.KubernetesClient().AppsV1().DaemonSets("kube-system").Get(ctx, "node-local-dns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			restarted := daemonSet.Spec.Template.Annotations["traefik-mesh-hash"] != ""
+			assert.Equal(t, test.expRestart, restarted)
+		})
+	}
+}
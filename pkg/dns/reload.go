@@ -0,0 +1,216 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// defaultMetricsPort is the port the CoreDNS prometheus plugin listens on when no address is configured.
+	defaultMetricsPort = "9153"
+
+	reloadVersionMetric = "coredns_reload_version_info"
+	reloadFailedMetric  = "coredns_reload_failed_total"
+)
+
+var prometheusRegexp = regexp.MustCompile(`(?m)^\s*prometheus(?:[ \t]+(\S+))?[ \t]*(?:\{|$)`)
+
+// reloadMetrics holds the metrics the CoreDNS reload plugin exposes for a pod.
+type reloadMetrics struct {
+	// hashAlgorithm is the algorithm used to hash the loaded Corefile, sha512 or md5 depending on the CoreDNS version.
+	hashAlgorithm string
+	// hash is the hash of the Corefile loaded by the pod.
+	hash string
+	// failures is the number of failed reloads.
+	failures float64
+}
+
+// metricsPort returns the port of the CoreDNS prometheus plugin enabled by the given Corefile, or false if the plugin
+// is not enabled.
+func metricsPort(corefile string) (string, bool) {
+	match := prometheusRegexp.FindStringSubmatch(corefile)
+	if match == nil {
+		return "", false
+	}
+
+	if match[1] == "" {
+		return defaultMetricsPort, true
+	}
+
+	_, port, err := net.SplitHostPort(match[1])
+	if err != nil || port == "" {
+		return "", false
+	}
+
+	return port, true
+}
+
+// scrapeReloadMetrics returns the reload plugin metrics of the running pods of the given workload, by pod name. The
+// metrics are scraped through the Kubernetes API server proxy, on the given port.
+func (c *Client) scrapeReloadMetrics(ctx context.Context, w *workload, port string) (map[string]reloadMetrics, error) {
+	pods, err := c.listWorkloadPods(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no running pod found for %s", w)
+	}
+
+	metrics := make(map[string]reloadMetrics)
+
+	for _, pod := range pods {
+		if !isPodReady(pod) {
+			return nil, fmt.Errorf("pod %s/%s is not ready", pod.Namespace, pod.Name)
+		}
+
+		raw, err := c.kubeClient.CoreV1().Pods(pod.Namespace).ProxyGet("http", pod.Name, port, "metrics", nil).DoRaw(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the metrics of pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+
+		podMetrics, err := parseReloadMetrics(raw)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the metrics of pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+
+		metrics[pod.Name] = podMetrics
+	}
+
+	return metrics, nil
+}
+
+// waitForReload waits for all the pods of the given workload to load the given Corefile with the reload plugin. It
+// fails as soon as a pod reports a reload failure which is not part of the given baseline metrics.
+func (c *Client) waitForReload(ctx context.Context, w *workload, corefile, port string, baseline map[string]reloadMetrics) error {
+	c.logger.Infof("Waiting for the reload plugin of %s to load the new configuration...", w)
+
+	var notLoaded string
+
+	err := wait.PollImmediate(c.rolloutPollInterval, c.rolloutTimeout, func() (bool, error) {
+		metrics, err := c.scrapeReloadMetrics(ctx, w, port)
+		if err != nil {
+			c.logger.Debugf("Unable to check the configuration loaded by %s: %v", w, err)
+			notLoaded = err.Error()
+
+			return false, nil
+		}
+
+		for name, podMetrics := range metrics {
+			if podMetrics.failures > baseline[name].failures {
+				return false, fmt.Errorf("pod %s/%s failed to reload the configuration, see its logs for details", w.objectMeta().Namespace, name)
+			}
+
+			if podMetrics.hash != hashCorefile(podMetrics.hashAlgorithm, corefile) {
+				notLoaded = fmt.Sprintf("pod %s/%s has not loaded it", w.objectMeta().Namespace, name)
+
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("timed out after %s waiting for the new configuration to be loaded: %s", c.rolloutTimeout, notLoaded)
+	}
+
+	return err
+}
+
+// listWorkloadPods returns the pods of the given workload which are not being deleted.
+func (c *Client) listWorkloadPods(ctx context.Context, w *workload) ([]*corev1.Pod, error) {
+	var labelSelector *metav1.LabelSelector
+	if w.daemonSet != nil {
+		labelSelector = w.daemonSet.Spec.Selector
+	} else {
+		labelSelector = w.deployment.Spec.Selector
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of %s: %w", w, err)
+	}
+
+	list, err := c.kubeClient.CoreV1().Pods(w.objectMeta().Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the pods of %s: %w", w, err)
+	}
+
+	var pods []*corev1.Pod
+
+	for i := range list.Items {
+		if list.Items[i].DeletionTimestamp == nil {
+			pods = append(pods, &list.Items[i])
+		}
+	}
+
+	return pods, nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// parseReloadMetrics parses the reload plugin metrics from the given metrics, in the Prometheus text format.
+func parseReloadMetrics(raw []byte) (reloadMetrics, error) {
+	var parser expfmt.TextParser
+
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(raw))
+	if err != nil {
+		return reloadMetrics{}, err
+	}
+
+	version, ok := families[reloadVersionMetric]
+	if !ok || len(version.GetMetric()) == 0 {
+		return reloadMetrics{}, fmt.Errorf("metric %s not found", reloadVersionMetric)
+	}
+
+	var metrics reloadMetrics
+
+	for _, label := range version.GetMetric()[0].GetLabel() {
+		switch label.GetName() {
+		case "hash":
+			metrics.hashAlgorithm = label.GetValue()
+		case "value":
+			metrics.hash = label.GetValue()
+		}
+	}
+
+	if failed, ok := families[reloadFailedMetric]; ok && len(failed.GetMetric()) > 0 {
+		metrics.failures = failed.GetMetric()[0].GetCounter().GetValue()
+	}
+
+	return metrics, nil
+}
+
+// hashCorefile returns the hash of the given Corefile computed with the given algorithm, as the reload plugin reports
+// it. Older CoreDNS versions report an MD5 hash, and the recent ones a SHA512 hash.
+func hashCorefile(algorithm, corefile string) string {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		sum := md5.Sum([]byte(corefile))
+		return hex.EncodeToString(sum[:])
+	default:
+		sum := sha512.Sum512([]byte(corefile))
+		return hex.EncodeToString(sum[:])
+	}
+}
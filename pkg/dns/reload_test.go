@@ -0,0 +1,68 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsPort(t *testing.T) {
+	tests := []struct {
+		desc     string
+		corefile string
+		expPort  string
+		expFound bool
+	}{
+		{
+			desc:     "Default port",
+			corefile: ".:53 {\n    prometheus\n    reload\n}\n",
+			expPort:  "9153",
+			expFound: true,
+		},
+		{
+			desc:     "Custom address",
+			corefile: ".:53 {\n    prometheus 0.0.0.0:9253\n    reload\n}\n",
+			expPort:  "9253",
+			expFound: true,
+		},
+		{
+			desc:     "Port only",
+			corefile: ".:53 {\n    prometheus :9153\n    reload\n}\n",
+			expPort:  "9153",
+			expFound: true,
+		},
+		{
+			desc:     "Without the prometheus plugin",
+			corefile: ".:53 {\n    # prometheus :9153\n    reload\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			port, found := metricsPort(test.corefile)
+			assert.Equal(t, test.expFound, found)
+			assert.Equal(t, test.expPort, port)
+		})
+	}
+}
+
+func TestParseReloadMetrics(t *testing.T) {
+	raw := "# TYPE coredns_reload_failed_total counter\n" +
+		"coredns_reload_failed_total 2\n" +
+		"# TYPE coredns_reload_version_info gauge\n" +
+		"coredns_reload_version_info{hash=\"md5\",value=\"" + hashCorefile("md5", ".:53 {\n}\n") + "\"} 1\n"
+
+	metrics, err := parseReloadMetrics([]byte(raw))
+	require.NoError(t, err)
+
+	assert.Equal(t, "md5", metrics.hashAlgorithm)
+	assert.Equal(t, hashCorefile("md5", ".:53 {\n}\n"), metrics.hash)
+	assert.Equal(t, float64(2), metrics.failures)
+
+	_, err = parseReloadMetrics([]byte("# TYPE coredns_dns_requests_total counter\ncoredns_dns_requests_total 1\n"))
+	assert.Error(t, err)
+}
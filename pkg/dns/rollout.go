@@ -0,0 +1,253 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// defaultRolloutPollInterval is the interval at which the status of a DNS workload rollout is checked.
+const defaultRolloutPollInterval = 2 * time.Second
+
+// RolloutTimeout makes the Client wait, for at most the given duration, for the DNS pods restarted after a
+// configuration change to become available. If they don't, the previous configuration is restored.
+func RolloutTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.rolloutTimeout = timeout
+	}
+}
+
+// ForceRestart makes the Client restart the DNS pods after a configuration change, even when CoreDNS would load it by
+// itself with the reload plugin, so that the change is effective once the rollout is complete.
+func ForceRestart() ClientOption {
+	return func(c *Client) {
+		c.forceRestart = true
+	}
+}
+
+// updateConfigMap validates and writes the given ConfigMap, and makes the given DNS workload load it. When the current
+// Corefile enables the reload plugin and the prometheus plugin, and the Corefile changes, CoreDNS loads the change by
+// itself, unless a restart is forced: the reload plugin metrics of the pods are then checked until they all report the
+// new Corefile, and if they don't, or report a reload failure, the previous ConfigMap data is restored. Otherwise, the
+// workload pods are restarted, and if the rollout doesn't become healthy, the previous ConfigMap data and pod template
+// are restored.
+func (c *Client) updateConfigMap(ctx context.Context, w *workload, configMap *corev1.ConfigMap) error {
+	if c.dryRunOut != nil {
+		return c.printConfigMapChanges(ctx, w, configMap)
+	}
+
+	current, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Get(ctx, configMap.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get ConfigMap %q in namespace %q: %w", configMap.Name, configMap.Namespace, err)
+	}
+
+	previousData := current.Data
+
+	if err = validateConfigMapChanges(configMap, previousData); err != nil {
+		return err
+	}
+
+	metricsPort, reload := c.reloadMetricsPort(configMap, previousData)
+
+	var baseline map[string]reloadMetrics
+
+	if reload && c.rolloutTimeout > 0 {
+		baseline, err = c.scrapeReloadMetrics(ctx, w, metricsPort)
+		if err != nil {
+			c.logger.Infof("Unable to check the configuration loaded by the reload plugin of %s, restarting its pods instead: %v", w, err)
+
+			reload = false
+		}
+	}
+
+	if _, err = c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+
+	if reload {
+		return c.reloadConfigMap(ctx, w, configMap, previousData, metricsPort, baseline)
+	}
+
+	previousAnnotations := make(map[string]string)
+	for key, value := range w.template().Annotations {
+		previousAnnotations[key] = value
+	}
+
+	if err = c.restartPods(ctx, w); err != nil {
+		return err
+	}
+
+	rolloutErr := c.waitForRollout(ctx, w)
+	if rolloutErr == nil {
+		return nil
+	}
+
+	c.logger.Errorf("Rollout of %s failed, restoring its previous configuration: %v", w, rolloutErr)
+
+	if err = c.rollback(ctx, w, configMap, previousData, previousAnnotations); err != nil {
+		return fmt.Errorf("rollout of %s failed: %w, and unable to restore its previous configuration: %v", w, rolloutErr, err)
+	}
+
+	return fmt.Errorf("rollout of %s failed, its previous configuration has been restored: %w", w, rolloutErr)
+}
+
+// reloadConfigMap waits for the reload plugin of the given workload to load the given ConfigMap, and restores the
+// given previous ConfigMap data if it doesn't.
+func (c *Client) reloadConfigMap(ctx context.Context, w *workload, configMap *corev1.ConfigMap, previousData map[string]string, metricsPort string, baseline map[string]reloadMetrics) error {
+	if c.rolloutTimeout <= 0 {
+		c.logger.Infof("ConfigMap %q in namespace %q will be loaded by the reload plugin of %s", configMap.Name, configMap.Namespace, w)
+
+		return nil
+	}
+
+	reloadErr := c.waitForReload(ctx, w, configMap.Data["Corefile"], metricsPort, baseline)
+	if reloadErr == nil {
+		return nil
+	}
+
+	c.logger.Errorf("Reload of %s failed, restoring its previous configuration: %v", w, reloadErr)
+
+	if err := c.restoreConfigMap(ctx, configMap, previousData); err != nil {
+		return fmt.Errorf("reload of %s failed: %w, and unable to restore its previous configuration: %v", w, reloadErr, err)
+	}
+
+	return fmt.Errorf("reload of %s failed, its previous configuration has been restored: %w", w, reloadErr)
+}
+
+// reloadMetricsPort returns the port of the metrics of the reload plugin, and true if the change of the given ConfigMap
+// can be loaded by the reload plugin enabled by the given previous ConfigMap data, and checked with its metrics. The
+// reload plugin only watches the Corefile, not the files it imports.
+func (c *Client) reloadMetricsPort(configMap *corev1.ConfigMap, previousData map[string]string) (string, bool) {
+	if c.forceRestart {
+		return "", false
+	}
+
+	corefile, ok := previousData["Corefile"]
+	if !ok || !hasReloadPlugin(corefile) || corefile == configMap.Data["Corefile"] {
+		return "", false
+	}
+
+	return metricsPort(corefile)
+}
+
+// validateConfigMapChanges validates the Corefile, and the server blocks imported by the Corefile, which differ from the
+// given previous ConfigMap data. Unchanged entries are left to the cluster administrator.
+func validateConfigMapChanges(configMap *corev1.ConfigMap, previousData map[string]string) error {
+	for key, value := range configMap.Data {
+		if key != "Corefile" && !strings.HasSuffix(key, ".server") {
+			continue
+		}
+
+		if previous, ok := previousData[key]; ok && previous == value {
+			continue
+		}
+
+		if err := validateCorefile(value); err != nil {
+			return fmt.Errorf("invalid %q in ConfigMap %q in namespace %q: %w", key, configMap.Name, configMap.Namespace, err)
+		}
+	}
+
+	return nil
+}
+
+// restoreConfigMap restores the given ConfigMap data.
+func (c *Client) restoreConfigMap(ctx context.Context, configMap *corev1.ConfigMap, data map[string]string) error {
+	current, err := c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Get(ctx, configMap.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	current.Data = data
+
+	_, err = c.kubeClient.CoreV1().ConfigMaps(current.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+
+	return err
+}
+
+// rollback restores the given ConfigMap data, and the given pod template annotations of the given workload.
+func (c *Client) rollback(ctx context.Context, w *workload, configMap *corev1.ConfigMap, data, annotations map[string]string) error {
+	if err := c.restoreConfigMap(ctx, configMap, data); err != nil {
+		return err
+	}
+
+	currentWorkload, err := c.getWorkload(ctx, w.objectMeta().Namespace, w.objectMeta().Name)
+	if err != nil {
+		return err
+	}
+
+	if currentWorkload == nil {
+		return fmt.Errorf("%s not found", w)
+	}
+
+	currentWorkload.template().Annotations = annotations
+
+	return c.updateWorkload(ctx, currentWorkload)
+}
+
+// waitForRollout waits for the pods of the given workload to be updated and available, if a rollout timeout is set.
+func (c *Client) waitForRollout(ctx context.Context, w *workload) error {
+	if c.rolloutTimeout <= 0 {
+		return nil
+	}
+
+	c.logger.Infof("Waiting for the rollout of %s...", w)
+
+	err := wait.PollImmediate(c.rolloutPollInterval, c.rolloutTimeout, func() (bool, error) {
+		current, err := c.getWorkload(ctx, w.objectMeta().Namespace, w.objectMeta().Name)
+		if err != nil {
+			return false, err
+		}
+
+		if current == nil {
+			return false, fmt.Errorf("%s not found", w)
+		}
+
+		if current.deployment != nil {
+			return isDeploymentRolledOut(current.deployment)
+		}
+
+		return isDaemonSetRolledOut(current.daemonSet), nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("timed out after %s waiting for the pods to be available", c.rolloutTimeout)
+	}
+
+	return err
+}
+
+func isDeploymentRolledOut(deployment *appsv1.Deployment) (bool, error) {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return false, fmt.Errorf("deployment exceeded its progress deadline: %s", condition.Message)
+		}
+	}
+
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false, nil
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	return deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.Replicas == replicas &&
+		deployment.Status.AvailableReplicas == replicas, nil
+}
+
+func isDaemonSetRolledOut(daemonSet *appsv1.DaemonSet) bool {
+	if daemonSet.Status.ObservedGeneration < daemonSet.Generation {
+		return false
+	}
+
+	return daemonSet.Status.UpdatedNumberScheduled == daemonSet.Status.DesiredNumberScheduled &&
+		daemonSet.Status.NumberAvailable == daemonSet.Status.DesiredNumberScheduled
+}
@@ -0,0 +1,222 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// reloadMetricsMock serves the reload plugin metrics of a CoreDNS pod, which has loaded the Corefile returned by the
+// given function.
+type reloadMetricsMock struct {
+	loaded func(ctx context.Context) (string, int, error)
+}
+
+func (r reloadMetricsMock) DoRaw(ctx context.Context) ([]byte, error) {
+	corefile, failures, err := r.loaded(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("# TYPE coredns_reload_failed_total counter\ncoredns_reload_failed_total %d\n"+
+		"# TYPE coredns_reload_version_info gauge\ncoredns_reload_version_info{hash=\"sha512\",value=\"%s\"} 1\n",
+		failures, hashCorefile("sha512", corefile))), nil
+}
+
+func (r reloadMetricsMock) Stream(_ context.Context) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestClient_updateConfigMap(t *testing.T) {
+	tests := []struct {
+		desc           string
+		mockFile       string
+		corefile       string
+		rolloutTimeout time.Duration
+		forceRestart   bool
+		reloadFails    bool
+		expErr         bool
+		expRestart     bool
+		expRollback    bool
+	}{
+		{
+			desc:       "Restart without waiting for the rollout",
+			mockFile:   "rollout_unavailable.yaml",
+			corefile:   ".:53 {\n    errors\n}\n",
+			expRestart: true,
+		},
+		{
+			desc:           "Restart with an available rollout",
+			mockFile:       "rollout_available.yaml",
+			corefile:       ".:53 {\n    errors\n}\n",
+			rolloutTimeout: time.Second,
+			expRestart:     true,
+		},
+		{
+			desc:           "Rollback after the rollout timeout",
+			mockFile:       "rollout_unavailable.yaml",
+			corefile:       ".:53 {\n    errors\n}\n",
+			rolloutTimeout: 50 * time.Millisecond,
+			expErr:         true,
+			expRollback:    true,
+		},
+		{
+			desc:           "Rollback after the progress deadline is exceeded",
+			mockFile:       "rollout_progress_deadline_exceeded.yaml",
+			corefile:       ".:53 {\n    errors\n}\n",
+			rolloutTimeout: time.Minute,
+			expErr:         true,
+			expRollback:    true,
+		},
+		{
+			desc:           "Reload plugin",
+			mockFile:       "rollout_reload_metrics.yaml",
+			corefile:       ".:53 {\n    errors\n    prometheus :9153\n    reload\n}\n",
+			rolloutTimeout: time.Second,
+		},
+		{
+			desc:           "Restore after a reload failure",
+			mockFile:       "rollout_reload_metrics.yaml",
+			corefile:       ".:53 {\n    errors\n    prometheus :9153\n    reload\n}\n",
+			rolloutTimeout: time.Minute,
+			reloadFails:    true,
+			expErr:         true,
+		},
+		{
+			desc:           "Restore after the reload timeout",
+			mockFile:       "rollout_reload_metrics.yaml",
+			corefile:       "# Not loaded.\n.:53 {\n    errors\n    prometheus :9153\n    reload\n}\n",
+			rolloutTimeout: 50 * time.Millisecond,
+			expErr:         true,
+		},
+		{
+			desc:     "Reload plugin without the wait",
+			mockFile: "rollout_reload_metrics.yaml",
+			corefile: ".:53 {\n    errors\n    prometheus :9153\n    reload\n}\n",
+		},
+		{
+			desc:       "Reload plugin without metrics",
+			mockFile:   "rollout_reload.yaml",
+			corefile:   ".:53 {\n    errors\n    reload\n}\n",
+			expRestart: true,
+		},
+		{
+			desc:         "Reload plugin with a forced restart",
+			mockFile:     "rollout_reload.yaml",
+			corefile:     ".:53 {\n    errors\n    reload\n}\n",
+			forceRestart: true,
+			expRestart:   true,
+		},
+		{
+			desc:           "Invalid Corefile",
+			mockFile:       "rollout_available.yaml",
+			corefile:       ".:53 {\n    errors\n",
+			rolloutTimeout: time.Second,
+			expErr:         true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock(test.mockFile)
+
+			log := logrus.New()
+			log.SetOutput(os.Stdout)
+			log.SetLevel(logrus.DebugLevel)
+
+			opts := []ClientOption{RolloutTimeout(test.rolloutTimeout)}
+			if test.forceRestart {
+				opts = append(opts, ForceRestart())
+			}
+
+			kubeClient := k8sClient.KubernetesClient().(*fake.Clientset)
+
+			configMap, err := kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			previousCorefile := configMap.Data["Corefile"]
+
+			// The pods load the current Corefile, unless the reload fails or the Corefile starts with a comment.
+			loaded := func(ctx context.Context) (string, int, error) {
+				current, err := kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+				if err != nil {
+					return "", 0, err
+				}
+
+				corefile := current.Data["Corefile"]
+				if corefile == previousCorefile {
+					return corefile, 0, nil
+				}
+
+				if test.reloadFails {
+					return previousCorefile, 1, nil
+				}
+
+				if strings.HasPrefix(corefile, "#") {
+					return previousCorefile, 0, nil
+				}
+
+				return corefile, 0, nil
+			}
+
+			kubeClient.PrependProxyReactor("pods", func(action k8stesting.Action) (bool, restclient.ResponseWrapper, error) {
+				return true, reloadMetricsMock{loaded: loaded}, nil
+			})
+
+			client := NewClient(log, kubeClient, opts...)
+			client.rolloutPollInterval = 10 * time.Millisecond
+
+			coreDNS, err := client.getCoreDNSWorkload(ctx, metav1.NamespaceSystem)
+			require.NoError(t, err)
+			require.NotNil(t, coreDNS)
+
+			configMap.Data["Corefile"] = test.corefile
+
+			err = client.updateConfigMap(ctx, coreDNS, configMap)
+			if test.expErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			configMap, err = k8sClient.KubernetesClient().CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			deployment, err := k8sClient.KubernetesClient().AppsV1().Deployments(metav1.NamespaceSystem).Get(ctx, "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			// The annotations set by the fixture must survive a restart, and a rollback.
+			assert.Equal(t, "bar", deployment.Spec.Template.Annotations["foo"])
+
+			_, restarted := deployment.Spec.Template.Annotations["traefik-mesh-hash"]
+			assert.Equal(t, test.expRestart, restarted)
+
+			if test.expErr {
+				assert.Equal(t, previousCorefile, configMap.Data["Corefile"])
+				return
+			}
+
+			assert.Equal(t, test.corefile, configMap.Data["Corefile"])
+		})
+	}
+}
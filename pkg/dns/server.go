@@ -0,0 +1,380 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/pkg/k8s"
+	"golang.org/x/net/dns/dnsmessage"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listers "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	// maxMessageSize is the maximum size of a DNS message.
+	maxMessageSize = 65535
+	// tcpIdleTimeout is the duration after which idle TCP connections are closed.
+	tcpIdleTimeout = 10 * time.Second
+)
+
+// serverTTL is the TTL of the records served by the DNS server. Shadow service ClusterIPs don't change during the
+// lifetime of the services, so it matches the cache duration of the stub domains configured in CoreDNS.
+const serverTTL = 30
+
+// ErrServerClosed is returned by the Server ListenAndServe method after a call to Shutdown.
+var ErrServerClosed = errors.New("dns: server closed")
+
+// Server is a DNS server answering the queries for the mesh domains with the ClusterIP of the shadow services, without
+// patching the cluster DNS. Names are resolved from an informer cache of the shadow services.
+type Server struct {
+	addr          string
+	namespace     string
+	domains       []string
+	serviceLister listers.ServiceLister
+	logger        logrus.FieldLogger
+
+	// stopCh stops the informer keeping the shadow services cache up to date. It is closed on Shutdown.
+	stopCh chan struct{}
+
+	mu         sync.Mutex
+	closed     bool
+	packetConn net.PacketConn
+	listener   net.Listener
+}
+
+// NewServer creates a new DNS server listening on the given host and port, over UDP and TCP, and resolving the given
+// mesh domains with the shadow services of the given Traefik Mesh namespace.
+func NewServer(logger logrus.FieldLogger, port int32, host string, client kubernetes.Interface, namespace string, domains []string) (*Server, error) {
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(client, k8s.ResyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = "app=maesh,type=shadow"
+		}))
+
+	serviceLister := informerFactory.Core().V1().Services().Lister()
+
+	// The informer runs for the whole lifetime of the server, only the initial cache sync is bounded.
+	stopCh := make(chan struct{})
+	informerFactory.Start(stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for t, ok := range informerFactory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			close(stopCh)
+
+			return nil, fmt.Errorf("timed out while waiting for informer cache to sync: %s", t)
+		}
+	}
+
+	return &Server{
+		addr:          net.JoinHostPort(host, strconv.Itoa(int(port))),
+		namespace:     namespace,
+		domains:       domains,
+		serviceLister: serviceLister,
+		logger:        logger,
+		stopCh:        stopCh,
+	}, nil
+}
+
+// ListenAndServe serves the DNS queries over UDP and TCP. It always returns a non-nil error, ErrServerClosed after a
+// call to Shutdown.
+func (s *Server) ListenAndServe() error {
+	packetConn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on UDP address %q: %w", s.addr, err)
+	}
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		_ = packetConn.Close()
+
+		return fmt.Errorf("unable to listen on TCP address %q: %w", s.addr, err)
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		_ = packetConn.Close()
+		_ = listener.Close()
+
+		return ErrServerClosed
+	}
+
+	s.packetConn = packetConn
+	s.listener = listener
+	s.mu.Unlock()
+
+	errCh := make(chan error, 2)
+
+	go func() {
+		errCh <- s.serveUDP(packetConn)
+	}()
+
+	go func() {
+		errCh <- s.serveTCP(listener)
+	}()
+
+	err = <-errCh
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = packetConn.Close()
+	_ = listener.Close()
+
+	if s.closed {
+		return ErrServerClosed
+	}
+
+	return err
+}
+
+// Shutdown stops the DNS server. In-flight TCP connections are closed when their current query has been answered.
+func (s *Server) Shutdown(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		close(s.stopCh)
+	}
+
+	s.closed = true
+
+	var errs []string
+
+	if s.packetConn != nil {
+		if err := s.packetConn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if s.listener != nil {
+		if err := s.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to close DNS listeners: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) error {
+	buf := make([]byte, maxMessageSize)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		res, ok := s.handle(buf[:n])
+		if !ok {
+			continue
+		}
+
+		if _, err = conn.WriteTo(res, addr); err != nil {
+			s.logger.Debugf("Unable to write DNS response to %q: %v", addr, err)
+		}
+	}
+}
+
+func (s *Server) serveTCP(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go s.serveTCPConn(conn)
+	}
+}
+
+// serveTCPConn answers the queries sent on the given connection, each of them being prefixed by its length.
+func (s *Server) serveTCPConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	for !s.isClosed() {
+		if err := conn.SetDeadline(time.Now().Add(tcpIdleTimeout)); err != nil {
+			return
+		}
+
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+
+		req := make([]byte, length)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+
+		res, ok := s.handle(req)
+		if !ok {
+			return
+		}
+
+		if err := binary.Write(conn, binary.BigEndian, uint16(len(res))); err != nil {
+			return
+		}
+
+		if _, err := conn.Write(res); err != nil {
+			s.logger.Debugf("Unable to write DNS response to %q: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// handle returns the packed response to the given packed query, or false if the query is malformed.
+func (s *Server) handle(data []byte) ([]byte, bool) {
+	var req dnsmessage.Message
+	if err := req.Unpack(data); err != nil || req.Header.Response {
+		s.logger.Debugf("Dropping malformed DNS query: %v", err)
+		return nil, false
+	}
+
+	res, err := s.resolve(&req).Pack()
+	if err != nil {
+		s.logger.Errorf("Unable to pack DNS response: %v", err)
+		return nil, false
+	}
+
+	return res, true
+}
+
+// resolve builds the response to the given query. Names of the form <name>.<namespace>.<mesh-domain> are resolved to
+// the ClusterIPs of the shadow service of the <namespace>/<name> service. Queries outside the mesh domains are refused.
+func (s *Server) resolve(req *dnsmessage.Message) *dnsmessage.Message {
+	res := &dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               req.Header.ID,
+			Response:         true,
+			OpCode:           req.Header.OpCode,
+			RecursionDesired: req.Header.RecursionDesired,
+		},
+		Questions: req.Questions,
+	}
+
+	if req.Header.OpCode != 0 {
+		res.Header.RCode = dnsmessage.RCodeNotImplemented
+		return res
+	}
+
+	if len(req.Questions) != 1 {
+		res.Header.RCode = dnsmessage.RCodeFormatError
+		return res
+	}
+
+	question := req.Questions[0]
+
+	name, namespace, ok := parseMeshName(question.Name.String(), s.domains)
+	if !ok {
+		res.Header.RCode = dnsmessage.RCodeRefused
+		return res
+	}
+
+	res.Header.Authoritative = true
+
+	if name == "" {
+		// The mesh domain and the namespace subdomains exist, but have no records.
+		return res
+	}
+
+	shadowSvcName := k8s.ShadowServiceName(s.namespace, namespace, name)
+
+	shadowSvc, err := s.serviceLister.Services(s.namespace).Get(shadowSvcName)
+	if kerrors.IsNotFound(err) {
+		res.Header.RCode = dnsmessage.RCodeNameError
+		return res
+	}
+
+	if err != nil {
+		s.logger.Errorf("Unable to get shadow service %q: %v", shadowSvcName, err)
+
+		res.Header.RCode = dnsmessage.RCodeServerFailure
+
+		return res
+	}
+
+	// Dual-stack shadow services have a ClusterIP per IP family, served as A and AAAA records.
+	for _, clusterIP := range k8s.ServiceClusterIPs(shadowSvc) {
+		ip := net.ParseIP(clusterIP)
+		if ip == nil {
+			continue
+		}
+
+		header := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: serverTTL}
+
+		switch {
+		case ip.To4() != nil && (question.Type == dnsmessage.TypeA || question.Type == dnsmessage.TypeALL):
+			var a dnsmessage.AResource
+
+			copy(a.A[:], ip.To4())
+
+			header.Type = dnsmessage.TypeA
+			res.Answers = append(res.Answers, dnsmessage.Resource{Header: header, Body: &a})
+
+		case ip.To4() == nil && (question.Type == dnsmessage.TypeAAAA || question.Type == dnsmessage.TypeALL):
+			var aaaa dnsmessage.AAAAResource
+
+			copy(aaaa.AAAA[:], ip.To16())
+
+			header.Type = dnsmessage.TypeAAAA
+			res.Answers = append(res.Answers, dnsmessage.Resource{Header: header, Body: &aaaa})
+		}
+	}
+
+	return res
+}
+
+// parseMeshName parses the given fully qualified domain name, and returns the service name and namespace it refers to.
+// It returns false if the name is not in one of the given mesh domains, and an empty service name if the name is a mesh
+// domain or a namespace subdomain.
+func parseMeshName(fqdn string, domains []string) (name, namespace string, ok bool) {
+	fqdn = strings.ToLower(strings.TrimSuffix(fqdn, "."))
+
+	for _, domain := range domains {
+		if fqdn == domain {
+			return "", "", true
+		}
+
+		prefix := strings.TrimSuffix(fqdn, "."+domain)
+		if prefix == fqdn {
+			continue
+		}
+
+		name, namespace, found := strings.Cut(prefix, ".")
+		if !found {
+			return "", "", true
+		}
+
+		// Deeper names are kept in the namespace, and resolved as names which don't exist.
+		return name, namespace, true
+	}
+
+	return "", "", false
+}
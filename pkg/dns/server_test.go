@@ -0,0 +1,255 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServer_resolve(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+	log.SetLevel(logrus.DebugLevel)
+
+	client := fake.NewSimpleClientset(
+		newShadowService("traefik-mesh-svc-a-6d61657368-my-ns", "10.10.10.1"),
+		newShadowService("traefik-mesh-svc-b-6d61657368-my-ns", "fd00::1"),
+		newShadowService("traefik-mesh-svc-c-6d61657368-my-ns", "10.10.10.3", "fd00::3"),
+		newShadowService("traefik-mesh-a-very-long-service-name-which-do-4b63ac0fd5e96281", "10.10.10.4"),
+	)
+
+	server, err := NewServer(log, 5353, "", client, "traefik-mesh", []string{"traefik.mesh", "maesh", "svc.mesh.internal"})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = server.Shutdown(context.Background())
+	})
+
+	tests := []struct {
+		desc      string
+		name      string
+		qType     dnsmessage.Type
+		expRCode  dnsmessage.RCode
+		expAnswer string
+	}{
+		{
+			desc:      "A record of a service",
+			name:      "svc-a.my-ns.traefik.mesh.",
+			qType:     dnsmessage.TypeA,
+			expRCode:  dnsmessage.RCodeSuccess,
+			expAnswer: "10.10.10.1",
+		},
+		{
+			desc:      "A record of a service in the legacy domain",
+			name:      "svc-a.my-ns.maesh.",
+			qType:     dnsmessage.TypeA,
+			expRCode:  dnsmessage.RCodeSuccess,
+			expAnswer: "10.10.10.1",
+		},
+		{
+			desc:      "A record of a service in a custom domain",
+			name:      "svc-a.my-ns.svc.mesh.internal.",
+			qType:     dnsmessage.TypeA,
+			expRCode:  dnsmessage.RCodeSuccess,
+			expAnswer: "10.10.10.1",
+		},
+		{
+			desc:      "A record of a service with a mixed case name",
+			name:      "Svc-A.My-Ns.Traefik.Mesh.",
+			qType:     dnsmessage.TypeA,
+			expRCode:  dnsmessage.RCodeSuccess,
+			expAnswer: "10.10.10.1",
+		},
+		{
+			desc:     "AAAA record of an IPv4 service",
+			name:     "svc-a.my-ns.traefik.mesh.",
+			qType:    dnsmessage.TypeAAAA,
+			expRCode: dnsmessage.RCodeSuccess,
+		},
+		{
+			desc:      "AAAA record of an IPv6 service",
+			name:      "svc-b.my-ns.traefik.mesh.",
+			qType:     dnsmessage.TypeAAAA,
+			expRCode:  dnsmessage.RCodeSuccess,
+			expAnswer: "fd00::1",
+		},
+		{
+			desc:      "A record of a dual-stack service",
+			name:      "svc-c.my-ns.traefik.mesh.",
+			qType:     dnsmessage.TypeA,
+			expRCode:  dnsmessage.RCodeSuccess,
+			expAnswer: "10.10.10.3",
+		},
+		{
+			desc:      "AAAA record of a dual-stack service",
+			name:      "svc-c.my-ns.traefik.mesh.",
+			qType:     dnsmessage.TypeAAAA,
+			expRCode:  dnsmessage.RCodeSuccess,
+			expAnswer: "fd00::3",
+		},
+		{
+			desc:      "A record of a service with a bounded shadow service name",
+			name:      "a-very-long-service-name-which-does-not-fit-in-a-shadow-name.my-ns.traefik.mesh.",
+			qType:     dnsmessage.TypeA,
+			expRCode:  dnsmessage.RCodeSuccess,
+			expAnswer: "10.10.10.4",
+		},
+		{
+			desc:     "Unknown service",
+			name:     "svc-d.my-ns.traefik.mesh.",
+			qType:    dnsmessage.TypeA,
+			expRCode: dnsmessage.RCodeNameError,
+		},
+		{
+			desc:     "Name deeper than a service",
+			name:     "foo.svc-a.my-ns.traefik.mesh.",
+			qType:    dnsmessage.TypeA,
+			expRCode: dnsmessage.RCodeNameError,
+		},
+		{
+			desc:     "Namespace subdomain",
+			name:     "my-ns.traefik.mesh.",
+			qType:    dnsmessage.TypeA,
+			expRCode: dnsmessage.RCodeSuccess,
+		},
+		{
+			desc:     "Name outside the mesh domains",
+			name:     "svc-a.my-ns.svc.cluster.local.",
+			qType:    dnsmessage.TypeA,
+			expRCode: dnsmessage.RCodeRefused,
+		},
+		{
+			desc:     "Name ending like a mesh domain",
+			name:     "svc-a.my-ns.notmaesh.",
+			qType:    dnsmessage.TypeA,
+			expRCode: dnsmessage.RCodeRefused,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := dnsmessage.Message{
+				Header: dnsmessage.Header{ID: 42, RecursionDesired: true},
+				Questions: []dnsmessage.Question{{
+					Name:  dnsmessage.MustNewName(test.name),
+					Type:  test.qType,
+					Class: dnsmessage.ClassINET,
+				}},
+			}
+
+			data, err := req.Pack()
+			require.NoError(t, err)
+
+			resData, ok := server.handle(data)
+			require.True(t, ok)
+
+			var res dnsmessage.Message
+			require.NoError(t, res.Unpack(resData))
+
+			assert.Equal(t, uint16(42), res.Header.ID)
+			assert.True(t, res.Header.Response)
+			assert.Equal(t, test.expRCode, res.Header.RCode)
+			assert.Equal(t, req.Questions, res.Questions)
+
+			if test.expAnswer == "" {
+				assert.Empty(t, res.Answers)
+				return
+			}
+
+			require.Len(t, res.Answers, 1)
+			assert.Equal(t, test.name, res.Answers[0].Header.Name.String())
+			assert.Equal(t, test.qType, res.Answers[0].Header.Type)
+
+			switch body := res.Answers[0].Body.(type) {
+			case *dnsmessage.AResource:
+				assert.Equal(t, test.expAnswer, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				assert.Equal(t, test.expAnswer, net.IP(body.AAAA[:]).String())
+			default:
+				t.Fatalf("unexpected answer %T", body)
+			}
+		})
+	}
+}
+
+func TestServer_handleMalformedQuery(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+
+	server, err := NewServer(log, 5353, "", fake.NewSimpleClientset(), "traefik-mesh", []string{"traefik.mesh"})
+	require.NoError(t, err)
+
+	defer func() { _ = server.Shutdown(context.Background()) }()
+
+	_, ok := server.handle([]byte{0x00, 0x01, 0x02})
+	assert.False(t, ok)
+}
+
+func TestServer_resolveServiceCreatedAfterStartup(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(os.Stdout)
+
+	client := fake.NewSimpleClientset()
+
+	server, err := NewServer(log, 5353, "", client, "traefik-mesh", []string{"traefik.mesh"})
+	require.NoError(t, err)
+
+	defer func() { _ = server.Shutdown(context.Background()) }()
+
+	req := &dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 42},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName("svc-a.my-ns.traefik.mesh."),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+
+	assert.Equal(t, dnsmessage.RCodeNameError, server.resolve(req).Header.RCode)
+
+	_, err = client.CoreV1().Services("traefik-mesh").Create(context.Background(), newShadowService("traefik-mesh-svc-a-6d61657368-my-ns", "10.10.10.1"), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		res := server.resolve(req)
+
+		return res.Header.RCode == dnsmessage.RCodeSuccess && len(res.Answers) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	err = client.CoreV1().Services("traefik-mesh").Delete(context.Background(), "traefik-mesh-svc-a-6d61657368-my-ns", metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return server.resolve(req).Header.RCode == dnsmessage.RCodeNameError
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func newShadowService(name string, clusterIPs ...string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "traefik-mesh",
+			Labels: map[string]string{
+				"app":  "maesh",
+				"type": "shadow",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:  clusterIPs[0],
+			ClusterIPs: clusterIPs,
+		},
+	}
+}
@@ -0,0 +1,153 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// coreDNSLabelSelector is the label selector most Kubernetes distributions set on CoreDNS workloads.
+const coreDNSLabelSelector = "kubernetes.io/name=CoreDNS"
+
+// workload is a Deployment or a DaemonSet running DNS server pods.
+type workload struct {
+	deployment *appsv1.Deployment
+	daemonSet  *appsv1.DaemonSet
+}
+
+func (w *workload) kind() string {
+	if w.daemonSet != nil {
+		return "DaemonSet"
+	}
+
+	return "Deployment"
+}
+
+func (w *workload) objectMeta() *metav1.ObjectMeta {
+	if w.daemonSet != nil {
+		return &w.daemonSet.ObjectMeta
+	}
+
+	return &w.deployment.ObjectMeta
+}
+
+func (w *workload) template() *corev1.PodTemplateSpec {
+	if w.daemonSet != nil {
+		return &w.daemonSet.Spec.Template
+	}
+
+	return &w.deployment.Spec.Template
+}
+
+func (w *workload) String() string {
+	return fmt.Sprintf("%s %s/%s", w.kind(), w.objectMeta().Namespace, w.objectMeta().Name)
+}
+
+// updateWorkload updates the given workload.
+func (c *Client) updateWorkload(ctx context.Context, w *workload) error {
+	if w.daemonSet != nil {
+		_, err := c.kubeClient.AppsV1().DaemonSets(w.daemonSet.Namespace).Update(ctx, w.daemonSet, metav1.UpdateOptions{})
+		return err
+	}
+
+	_, err := c.kubeClient.AppsV1().Deployments(w.deployment.Namespace).Update(ctx, w.deployment, metav1.UpdateOptions{})
+
+	return err
+}
+
+// getWorkload returns the Deployment, or the DaemonSet, with the given name in the given namespace, or nil if there is
+// none.
+func (c *Client) getWorkload(ctx context.Context, namespace, name string) (*workload, error) {
+	deployment, err := c.kubeClient.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return &workload{deployment: deployment}, nil
+	}
+
+	if !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get deployment %q in namespace %q: %w", name, namespace, err)
+	}
+
+	daemonSet, err := c.kubeClient.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return &workload{daemonSet: daemonSet}, nil
+	}
+
+	if !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get daemonset %q in namespace %q: %w", name, namespace, err)
+	}
+
+	return nil, nil
+}
+
+// listWorkloads returns the Deployments and DaemonSets matching the given label selector in the given namespace, or in
+// all namespaces if the namespace is empty.
+func (c *Client) listWorkloads(ctx context.Context, namespace, labelSelector string) ([]*workload, error) {
+	opts := metav1.ListOptions{LabelSelector: labelSelector}
+
+	deployments, err := c.kubeClient.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list deployments matching %q: %w", labelSelector, err)
+	}
+
+	daemonSets, err := c.kubeClient.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list daemonsets matching %q: %w", labelSelector, err)
+	}
+
+	var workloads []*workload
+
+	for i := range deployments.Items {
+		workloads = append(workloads, &workload{deployment: &deployments.Items[i]})
+	}
+
+	for i := range daemonSets.Items {
+		workloads = append(workloads, &workload{daemonSet: &daemonSets.Items[i]})
+	}
+
+	return workloads, nil
+}
+
+// getCoreDNSWorkload returns the CoreDNS workload running in the given namespace, or nil if there is none. CoreDNS is
+// looked up with the label set by most Kubernetes distributions first, and then by name (e.g.: with kubeadm).
+func (c *Client) getCoreDNSWorkload(ctx context.Context, namespace string) (*workload, error) {
+	workloads, err := c.listWorkloads(ctx, namespace, coreDNSLabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(workloads) == 1 {
+		return workloads[0], nil
+	}
+
+	return c.getWorkload(ctx, namespace, "coredns")
+}
+
+// findCoreDNSWorkload returns the CoreDNS workload running outside the kube-system namespace, found with the label set
+// by most Kubernetes distributions, or nil if there is none.
+func (c *Client) findCoreDNSWorkload(ctx context.Context) (*workload, error) {
+	workloads, err := c.listWorkloads(ctx, metav1.NamespaceAll, coreDNSLabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*workload
+
+	for _, w := range workloads {
+		if w.objectMeta().Namespace != metav1.NamespaceSystem {
+			found = append(found, w)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("found %d CoreDNS workloads outside namespace %q, unable to choose one", len(found), metav1.NamespaceSystem)
+	}
+}
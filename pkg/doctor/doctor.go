@@ -0,0 +1,617 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/traefik/mesh/pkg/annotations"
+	"github.com/traefik/mesh/pkg/dns"
+	"github.com/traefik/mesh/pkg/k8s"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// controllerServiceName is the name of the Service exposing the controller API.
+const controllerServiceName = "traefik-mesh-controller"
+
+// Status is the status of a check.
+type Status string
+
+// Check statuses.
+const (
+	StatusOK      Status = "ok"
+	StatusWarning Status = "warning"
+	StatusFailed  Status = "failed"
+)
+
+// Result is the result of a check.
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report holds the results of all the checks.
+type Report struct {
+	Results []Result `json:"results"`
+	// Failed is true if at least one check has failed.
+	Failed bool `json:"failed"`
+}
+
+// Config holds the configuration of the Doctor, which mirrors the one of the controller and of the prepare command.
+type Config struct {
+	Namespace                string
+	ControllerServiceAccount string
+	ClusterDomain            string
+	MeshDomains              []string
+	DNSForward               string
+	ACL                      bool
+	WatchNamespaces          []string
+	IgnoreNamespaces         []string
+	NamespaceSelector        labels.Selector
+	OptIn                    bool
+	MTLS                     bool
+	DefaultMode              string
+	LimitHTTPPort            int32
+	LimitTCPPort             int32
+	LimitUDPPort             int32
+	APIPort                  int32
+	APITLS                   bool
+	APIAuth                  bool
+}
+
+// permission is a permission required to install and run Traefik Mesh.
+type permission struct {
+	verb        string
+	group       string
+	resource    string
+	subresource string
+	namespace   string
+}
+
+// Doctor checks that a cluster is ready to run Traefik Mesh.
+type Doctor struct {
+	cfg        Config
+	kubeClient kubernetes.Interface
+	dnsClient  *dns.Client
+	logger     logrus.FieldLogger
+}
+
+// NewDoctor returns an initialized Doctor.
+func NewDoctor(logger logrus.FieldLogger, kubeClient kubernetes.Interface, cfg Config) *Doctor {
+	dnsOpts := []dns.ClientOption{dns.Domains(cfg.MeshDomains...)}
+	if cfg.DNSForward != "" {
+		dnsOpts = append(dnsOpts, dns.ForwardTo(cfg.DNSForward))
+	}
+
+	return &Doctor{
+		cfg:        cfg,
+		kubeClient: kubeClient,
+		dnsClient:  dns.NewClient(logger, kubeClient, dnsOpts...),
+		logger:     logger,
+	}
+}
+
+// Run runs all the checks and returns their results. Checks don't stop on failure, so that all the problems are
+// reported at once.
+func (d *Doctor) Run(ctx context.Context) *Report {
+	report := &Report{}
+
+	provider, providerResult := d.checkDNSProvider(ctx)
+
+	report.add(d.checkSMI())
+	report.add(providerResult)
+	report.add(d.checkDNSConfig(ctx, provider))
+	report.add(d.checkPermissions(ctx))
+	report.add(d.checkPortRanges(ctx))
+	report.add(d.checkAPI(ctx))
+
+	return report
+}
+
+func (r *Report) add(result Result) {
+	r.Results = append(r.Results, result)
+
+	if result.Status == StatusFailed {
+		r.Failed = true
+	}
+}
+
+// WriteText writes a human readable version of the report to the given writer.
+func (r *Report) WriteText(w io.Writer) error {
+	for _, result := range r.Results {
+		if _, err := fmt.Fprintf(w, "%-10s%s: %s\n", "["+string(result.Status)+"]", result.Name, result.Message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as JSON to the given writer.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(r)
+}
+
+// checkSMI checks that the SMI CRDs are installed in the supported versions.
+func (d *Doctor) checkSMI() Result {
+	result := Result{Name: "SMI CRDs"}
+
+	if err := k8s.CheckSMIVersion(d.kubeClient, d.cfg.ACL); err != nil {
+		result.Status = StatusFailed
+		result.Message = err.Error()
+
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Message = "supported versions installed"
+
+	return result
+}
+
+// checkDNSProvider checks that the DNS provider of the cluster is supported, and returns it.
+func (d *Doctor) checkDNSProvider(ctx context.Context) (dns.Provider, Result) {
+	result := Result{Name: "DNS provider"}
+
+	provider, err := d.dnsClient.CheckDNSProvider(ctx)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Message = err.Error()
+
+		return dns.UnknownDNS, result
+	}
+
+	name := "CoreDNS"
+	if provider == dns.KubeDNS {
+		name = "KubeDNS"
+	}
+
+	version, err := d.dnsClient.ProviderVersion(ctx, provider)
+	if err != nil {
+		result.Status = StatusWarning
+		result.Message = fmt.Sprintf("%s detected, unable to get its version: %v", name, err)
+
+		return provider, result
+	}
+
+	result.Status = StatusOK
+	result.Message = fmt.Sprintf("%s %s", name, version)
+
+	return provider, result
+}
+
+// checkDNSConfig checks that the cluster DNS is configured for the mesh domains, as the prepare command would
+// configure it.
+func (d *Doctor) checkDNSConfig(ctx context.Context, provider dns.Provider) Result {
+	result := Result{Name: "DNS configuration"}
+
+	var (
+		state dns.ConfigState
+		err   error
+	)
+
+	switch provider {
+	case dns.CoreDNS:
+		state, err = d.dnsClient.CoreDNSConfigState(ctx, d.cfg.ClusterDomain, d.cfg.Namespace)
+	case dns.KubeDNS:
+		state, err = d.dnsClient.KubeDNSConfigState(ctx)
+	default:
+		result.Status = StatusFailed
+		result.Message = "skipped, no supported DNS provider"
+
+		return result
+	}
+
+	if err != nil {
+		result.Status = StatusFailed
+		result.Message = err.Error()
+
+		return result
+	}
+
+	switch state {
+	case dns.ConfigUpToDate:
+		result.Status = StatusOK
+	case dns.ConfigOutdated:
+		result.Status = StatusWarning
+	default:
+		result.Status = StatusFailed
+	}
+
+	result.Message = fmt.Sprintf("mesh domains %s configuration is %s", strings.Join(d.cfg.MeshDomains, ", "), state)
+
+	if state != dns.ConfigUpToDate {
+		result.Message += ", run the prepare command"
+	}
+
+	return result
+}
+
+// checkPermissions checks that the current user has the permissions required by the prepare and cleanup commands,
+// with SelfSubjectAccessReviews, and that the controller service account has the permissions required by the
+// controller, with SubjectAccessReviews.
+func (d *Doctor) checkPermissions(ctx context.Context) Result {
+	result := Result{Name: "RBAC permissions"}
+
+	missingForUser, err := d.missingPermissions(d.commandPermissions(), d.reviewUserAccess(ctx))
+	if err != nil {
+		result.Status = StatusFailed
+		result.Message = fmt.Sprintf("unable to review access: %v", err)
+
+		return result
+	}
+
+	missingForController, err := d.missingPermissions(d.controllerPermissions(), d.reviewControllerAccess(ctx))
+	if err != nil {
+		result.Status = StatusFailed
+		result.Message = fmt.Sprintf("unable to review access: %v", err)
+
+		return result
+	}
+
+	var missing []string
+
+	if len(missingForUser) > 0 {
+		missing = append(missing, fmt.Sprintf("missing %s for the current user", strings.Join(missingForUser, ", ")))
+	}
+
+	if len(missingForController) > 0 {
+		missing = append(missing, fmt.Sprintf("missing %s for %s", strings.Join(missingForController, ", "), d.controllerUser()))
+	}
+
+	if len(missing) > 0 {
+		result.Status = StatusFailed
+		result.Message = strings.Join(missing, "; ")
+
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Message = fmt.Sprintf("prepare and cleanup permissions granted to the current user, controller permissions granted to %s",
+		d.controllerUser())
+
+	return result
+}
+
+// reviewFunc returns true if the given permission is granted.
+type reviewFunc func(perm permission) (bool, error)
+
+// missingPermissions returns the given permissions which are not granted, according to the given review function.
+func (d *Doctor) missingPermissions(perms []permission, review reviewFunc) ([]string, error) {
+	var missing []string
+
+	for _, perm := range perms {
+		allowed, err := review(perm)
+		if err != nil {
+			return nil, err
+		}
+
+		if !allowed {
+			missing = append(missing, perm.String())
+		}
+	}
+
+	return missing, nil
+}
+
+// reviewUserAccess returns a reviewFunc checking the permissions of the current user with SelfSubjectAccessReviews.
+func (d *Doctor) reviewUserAccess(ctx context.Context) reviewFunc {
+	return func(perm permission) (bool, error) {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: perm.resourceAttributes(),
+			},
+		}
+
+		review, err := d.kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		return review.Status.Allowed, nil
+	}
+}
+
+// reviewControllerAccess returns a reviewFunc checking the permissions of the controller service account with
+// SubjectAccessReviews.
+func (d *Doctor) reviewControllerAccess(ctx context.Context) reviewFunc {
+	return func(perm permission) (bool, error) {
+		review := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				ResourceAttributes: perm.resourceAttributes(),
+				User:               d.controllerUser(),
+				Groups: []string{
+					"system:serviceaccounts",
+					"system:serviceaccounts:" + d.cfg.Namespace,
+					"system:authenticated",
+				},
+			},
+		}
+
+		review, err := d.kubeClient.AuthorizationV1().SubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		return review.Status.Allowed, nil
+	}
+}
+
+// controllerUser returns the name of the user the controller service account authenticates as. The service account
+// is also a member of the groups given to the SubjectAccessReviews.
+func (d *Doctor) controllerUser() string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", d.cfg.Namespace, d.cfg.ControllerServiceAccount)
+}
+
+// commandPermissions returns the permissions required by the prepare and cleanup commands.
+func (d *Doctor) commandPermissions() []permission {
+	var perms []permission
+
+	for _, resource := range []string{"deployments", "daemonsets"} {
+		for _, verb := range []string{"get", "list", "update"} {
+			perms = append(perms, permission{verb: verb, group: "apps", resource: resource})
+		}
+	}
+
+	for _, verb := range []string{"get", "create", "update", "delete"} {
+		perms = append(perms, permission{verb: verb, resource: "configmaps"})
+	}
+
+	for _, verb := range []string{"get", "list", "delete"} {
+		perms = append(perms, permission{verb: verb, resource: "services", namespace: d.cfg.Namespace})
+	}
+
+	// Reload plugin checks.
+	perms = append(perms,
+		permission{verb: "list", resource: "pods"},
+		permission{verb: "get", resource: "pods", subresource: "proxy"},
+	)
+
+	return perms
+}
+
+// controllerPermissions returns the permissions required by the controller, given the features it runs with.
+func (d *Doctor) controllerPermissions() []permission {
+	var perms []permission
+
+	for _, resource := range []string{"pods", "endpoints", "services", "namespaces"} {
+		for _, verb := range []string{"list", "watch"} {
+			perms = append(perms, permission{verb: verb, resource: resource})
+		}
+	}
+
+	// Shadow services.
+	for _, verb := range []string{"create", "update"} {
+		perms = append(perms, permission{verb: verb, resource: "services", namespace: d.cfg.Namespace})
+	}
+
+	for _, verb := range []string{"create", "patch"} {
+		perms = append(perms, permission{verb: verb, resource: "events"})
+	}
+
+	smiResources := []permission{
+		{group: "split.smi-spec.io", resource: "trafficsplits"},
+		{group: "specs.smi-spec.io", resource: "httproutegroups"},
+		{group: "specs.smi-spec.io", resource: "tcproutes"},
+	}
+	if d.cfg.ACL {
+		smiResources = append(smiResources, permission{group: "access.smi-spec.io", resource: "traffictargets"})
+	}
+
+	for _, smiResource := range smiResources {
+		for _, verb := range []string{"get", "list", "watch"} {
+			perms = append(perms, permission{verb: verb, group: smiResource.group, resource: smiResource.resource})
+		}
+	}
+
+	// Status annotations.
+	perms = append(perms, permission{verb: "patch", group: "split.smi-spec.io", resource: "trafficsplits"})
+	if d.cfg.ACL {
+		perms = append(perms, permission{verb: "patch", group: "access.smi-spec.io", resource: "traffictargets"})
+	}
+
+	if d.cfg.MTLS {
+		for _, verb := range []string{"get", "create"} {
+			perms = append(perms, permission{verb: verb, resource: "secrets", namespace: d.cfg.Namespace})
+		}
+	}
+
+	if d.cfg.APIAuth {
+		perms = append(perms,
+			permission{verb: "create", group: "authentication.k8s.io", resource: "tokenreviews"},
+			permission{verb: "create", group: "authorization.k8s.io", resource: "subjectaccessreviews"},
+		)
+	}
+
+	return perms
+}
+
+// String returns a human readable representation of the permission.
+func (p permission) String() string {
+	resource := p.resource
+	if p.subresource != "" {
+		resource += "/" + p.subresource
+	}
+
+	if p.group != "" {
+		resource += "." + p.group
+	}
+
+	if p.namespace == "" {
+		return fmt.Sprintf("%s %s", p.verb, resource)
+	}
+
+	return fmt.Sprintf("%s %s in namespace %q", p.verb, resource, p.namespace)
+}
+
+// resourceAttributes returns the attributes of the permission to review.
+func (p permission) resourceAttributes() *authorizationv1.ResourceAttributes {
+	return &authorizationv1.ResourceAttributes{
+		Namespace:   p.namespace,
+		Verb:        p.verb,
+		Group:       p.group,
+		Resource:    p.resource,
+		Subresource: p.subresource,
+	}
+}
+
+// checkPortRanges checks that the port ranges allocated to the proxies are large enough for the mesh services.
+func (d *Doctor) checkPortRanges(ctx context.Context) Result {
+	result := Result{Name: "Port ranges"}
+
+	services, err := d.kubeClient.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		result.Status = StatusFailed
+		result.Message = fmt.Sprintf("unable to list services: %v", err)
+
+		return result
+	}
+
+	namespaces, err := d.kubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		result.Status = StatusFailed
+		result.Message = fmt.Sprintf("unable to list namespaces: %v", err)
+
+		return result
+	}
+
+	namespaceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for i := range namespaces.Items {
+		if err = namespaceIndexer.Add(&namespaces.Items[i]); err != nil {
+			result.Status = StatusFailed
+			result.Message = fmt.Sprintf("unable to index namespaces: %v", err)
+
+			return result
+		}
+	}
+
+	namespaceLister := listers.NewNamespaceLister(namespaceIndexer)
+
+	// The services are filtered the same way the controller filters them.
+	filterOpts := []k8s.ResourceFilterOption{
+		k8s.WatchNamespaces(d.cfg.WatchNamespaces...),
+		k8s.IgnoreNamespaces(d.cfg.IgnoreNamespaces...),
+		k8s.IgnoreNamespaces(metav1.NamespaceSystem),
+		k8s.IgnoreService(metav1.NamespaceDefault, "kubernetes"),
+		k8s.IgnoreApps("maesh", "jaeger"),
+		k8s.MeshEnabledAnnotation(d.cfg.OptIn, namespaceLister),
+	}
+
+	if d.cfg.NamespaceSelector != nil {
+		filterOpts = append(filterOpts, k8s.WatchNamespaceSelector(d.cfg.NamespaceSelector, namespaceLister))
+	}
+
+	resourceFilter := k8s.NewResourceFilter(filterOpts...)
+
+	var httpPorts, tcpPorts, udpPorts int
+
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if resourceFilter.IsIgnored(svc) {
+			continue
+		}
+
+		trafficType, err := annotations.GetTrafficType(d.cfg.DefaultMode, svc.Annotations)
+		if err != nil {
+			d.logger.Warnf("Ignoring service %s/%s: %v", svc.Namespace, svc.Name, err)
+			continue
+		}
+
+		switch trafficType {
+		case annotations.ServiceTypeHTTP:
+			// HTTP ports are shared by the services, the range only has to fit the service with the most ports.
+			if ports := countPorts(svc, corev1.ProtocolTCP); ports > httpPorts {
+				httpPorts = ports
+			}
+		case annotations.ServiceTypeTCP:
+			tcpPorts += countPorts(svc, corev1.ProtocolTCP)
+		case annotations.ServiceTypeUDP:
+			udpPorts += countPorts(svc, corev1.ProtocolUDP)
+		}
+	}
+
+	var exceeded []string
+
+	if httpPorts > int(d.cfg.LimitHTTPPort) {
+		exceeded = append(exceeded, fmt.Sprintf("HTTP services need up to %d ports, %d allocated", httpPorts, d.cfg.LimitHTTPPort))
+	}
+
+	if tcpPorts > int(d.cfg.LimitTCPPort) {
+		exceeded = append(exceeded, fmt.Sprintf("TCP services need %d ports, %d allocated", tcpPorts, d.cfg.LimitTCPPort))
+	}
+
+	if udpPorts > int(d.cfg.LimitUDPPort) {
+		exceeded = append(exceeded, fmt.Sprintf("UDP services need %d ports, %d allocated", udpPorts, d.cfg.LimitUDPPort))
+	}
+
+	if len(exceeded) > 0 {
+		result.Status = StatusFailed
+		result.Message = strings.Join(exceeded, "; ")
+
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Message = fmt.Sprintf("HTTP %d/%d, TCP %d/%d, UDP %d/%d ports used",
+		httpPorts, d.cfg.LimitHTTPPort, tcpPorts, d.cfg.LimitTCPPort, udpPorts, d.cfg.LimitUDPPort)
+
+	return result
+}
+
+// countPorts returns the number of ports of the given service using the given protocol.
+func countPorts(svc *corev1.Service, protocol corev1.Protocol) int {
+	var count int
+
+	for _, port := range svc.Spec.Ports {
+		if port.Protocol == protocol {
+			count++
+		}
+	}
+
+	return count
+}
+
+// checkAPI checks that the controller API is reachable and ready, through the Kubernetes API server proxy.
+func (d *Doctor) checkAPI(ctx context.Context) Result {
+	result := Result{Name: "Controller API"}
+
+	scheme := "http"
+	if d.cfg.APITLS {
+		scheme = "https"
+	}
+
+	port := fmt.Sprint(d.cfg.APIPort)
+
+	res := d.kubeClient.CoreV1().Services(d.cfg.Namespace).ProxyGet(scheme, controllerServiceName, port, "/api/status/readiness", nil)
+	if res == nil {
+		result.Status = StatusFailed
+		result.Message = "unable to proxy requests to the controller API"
+
+		return result
+	}
+
+	if _, err := res.DoRaw(ctx); err != nil {
+		result.Status = StatusFailed
+		result.Message = fmt.Sprintf("service %s/%s is not ready on port %s: %v", d.cfg.Namespace, controllerServiceName, port, err)
+
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Message = fmt.Sprintf("service %s/%s is ready on port %s", d.cfg.Namespace, controllerServiceName, port)
+
+	return result
+}
@@ -0,0 +1,284 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/dns"
+	"github.com/traefik/mesh/pkg/k8s"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type responseWrapperMock struct {
+	err error
+}
+
+func (r responseWrapperMock) DoRaw(_ context.Context) ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return []byte("true"), nil
+}
+
+func (r responseWrapperMock) Stream(_ context.Context) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestDoctor_Run(t *testing.T) {
+	tests := []struct {
+		desc             string
+		prepared         bool
+		smiGroups        []string
+		denied           string
+		controllerDenied string
+		apiErr           error
+		limitTCPPort     int32
+		expStatuses      map[string]Status
+		expFailed        bool
+		expMessageFor    map[string]string
+	}{
+		{
+			desc:         "Prepared cluster",
+			prepared:     true,
+			smiGroups:    []string{"split.smi-spec.io/v1alpha3", "specs.smi-spec.io/v1alpha3", "access.smi-spec.io/v1alpha2"},
+			limitTCPPort: 25,
+			expStatuses: map[string]Status{
+				"SMI CRDs":          StatusOK,
+				"DNS provider":      StatusOK,
+				"DNS configuration": StatusOK,
+				"RBAC permissions":  StatusOK,
+				"Port ranges":       StatusOK,
+				"Controller API":    StatusOK,
+			},
+			expMessageFor: map[string]string{
+				"DNS provider":     "CoreDNS 1.8.0",
+				"RBAC permissions": "prepare and cleanup permissions granted to the current user, controller permissions granted to system:serviceaccount:traefik-mesh:traefik-mesh-controller",
+				"Port ranges":      "HTTP 2/10, TCP 2/25, UDP 1/25 ports used",
+			},
+		},
+		{
+			desc:             "Controller service account without the ACL permissions",
+			prepared:         true,
+			smiGroups:        []string{"split.smi-spec.io/v1alpha3", "specs.smi-spec.io/v1alpha3", "access.smi-spec.io/v1alpha2"},
+			controllerDenied: "traffictargets",
+			limitTCPPort:     25,
+			expStatuses: map[string]Status{
+				"SMI CRDs":          StatusOK,
+				"DNS provider":      StatusOK,
+				"DNS configuration": StatusOK,
+				"RBAC permissions":  StatusFailed,
+				"Port ranges":       StatusOK,
+				"Controller API":    StatusOK,
+			},
+			expFailed: true,
+			expMessageFor: map[string]string{
+				"RBAC permissions": "missing get traffictargets.access.smi-spec.io, list traffictargets.access.smi-spec.io, watch traffictargets.access.smi-spec.io, patch traffictargets.access.smi-spec.io for system:serviceaccount:traefik-mesh:traefik-mesh-controller",
+			},
+		},
+		{
+			desc:         "Cluster with problems",
+			smiGroups:    []string{"split.smi-spec.io/v1alpha2", "specs.smi-spec.io/v1alpha3"},
+			denied:       "configmaps",
+			apiErr:       errors.New("service unavailable"),
+			limitTCPPort: 1,
+			expStatuses: map[string]Status{
+				"SMI CRDs":          StatusFailed,
+				"DNS provider":      StatusOK,
+				"DNS configuration": StatusFailed,
+				"RBAC permissions":  StatusFailed,
+				"Port ranges":       StatusFailed,
+				"Controller API":    StatusFailed,
+			},
+			expFailed: true,
+			expMessageFor: map[string]string{
+				"DNS configuration": "mesh domains traefik.mesh, maesh configuration is missing, run the prepare command",
+				"RBAC permissions":  "missing get configmaps, create configmaps, update configmaps, delete configmaps for the current user",
+				"Port ranges":       "TCP services need 2 ports, 1 allocated",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k8sClient := k8s.NewClientMock("cluster.yaml")
+			kubeClient := k8sClient.KubernetesClient().(*fake.Clientset)
+
+			for _, group := range test.smiGroups {
+				kubeClient.Resources = append(kubeClient.Resources, &metav1.APIResourceList{GroupVersion: group})
+			}
+
+			kubeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				review.Status.Allowed = review.Spec.ResourceAttributes.Resource != test.denied
+
+				return true, review, nil
+			})
+
+			kubeClient.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				review.Status.Allowed = review.Spec.User == "system:serviceaccount:traefik-mesh:traefik-mesh-controller" &&
+					review.Spec.ResourceAttributes.Resource != test.controllerDenied
+
+				return true, review, nil
+			})
+
+			kubeClient.PrependProxyReactor("services", func(action k8stesting.Action) (bool, restclient.ResponseWrapper, error) {
+				proxyAction := action.(k8stesting.ProxyGetAction)
+				if proxyAction.GetName() != "traefik-mesh-controller" || proxyAction.GetPort() != "9000" || proxyAction.GetPath() != "/api/status/readiness" {
+					return false, nil, nil
+				}
+
+				return true, responseWrapperMock{err: test.apiErr}, nil
+			})
+
+			logger := logrus.New()
+			logger.SetOutput(os.Stdout)
+			logger.SetLevel(logrus.DebugLevel)
+
+			if test.prepared {
+				dnsClient := dns.NewClient(logger, kubeClient)
+				require.NoError(t, dnsClient.ConfigureCoreDNS(ctx, "kube-system", "cluster.local", "traefik-mesh"))
+			}
+
+			d := NewDoctor(logger, kubeClient, Config{
+				Namespace:                "traefik-mesh",
+				ControllerServiceAccount: "traefik-mesh-controller",
+				ClusterDomain:            "cluster.local",
+				MeshDomains:              []string{"traefik.mesh", "maesh"},
+				ACL:                      true,
+				DefaultMode:              "http",
+				LimitHTTPPort:            10,
+				LimitTCPPort:             test.limitTCPPort,
+				LimitUDPPort:             25,
+				APIPort:                  9000,
+			})
+
+			report := d.Run(ctx)
+
+			assert.Equal(t, test.expFailed, report.Failed)
+
+			statuses := make(map[string]Status)
+			messages := make(map[string]string)
+
+			for _, result := range report.Results {
+				statuses[result.Name] = result.Status
+				messages[result.Name] = result.Message
+			}
+
+			assert.Equal(t, test.expStatuses, statuses)
+
+			for name, message := range test.expMessageFor {
+				assert.Equal(t, message, messages[name], name)
+			}
+		})
+	}
+}
+
+func TestDoctor_controllerPermissions(t *testing.T) {
+	tests := []struct {
+		desc       string
+		cfg        Config
+		expPresent []string
+		expAbsent  []string
+	}{
+		{
+			desc: "Default controller",
+			cfg:  Config{Namespace: "traefik-mesh"},
+			expPresent: []string{
+				"list pods",
+				"watch endpoints",
+				"watch namespaces",
+				"create services in namespace \"traefik-mesh\"",
+				"create events",
+				"watch trafficsplits.split.smi-spec.io",
+				"list tcproutes.specs.smi-spec.io",
+				"patch trafficsplits.split.smi-spec.io",
+			},
+			expAbsent: []string{
+				"list traffictargets.access.smi-spec.io",
+				"patch traffictargets.access.smi-spec.io",
+				"create secrets in namespace \"traefik-mesh\"",
+				"create tokenreviews.authentication.k8s.io",
+				"create subjectaccessreviews.authorization.k8s.io",
+			},
+		},
+		{
+			desc: "Controller with all the features enabled",
+			cfg: Config{
+				Namespace: "traefik-mesh",
+				ACL:       true,
+				MTLS:      true,
+				APIAuth:   true,
+			},
+			expPresent: []string{
+				"list traffictargets.access.smi-spec.io",
+				"patch traffictargets.access.smi-spec.io",
+				"get secrets in namespace \"traefik-mesh\"",
+				"create secrets in namespace \"traefik-mesh\"",
+				"create tokenreviews.authentication.k8s.io",
+				"create subjectaccessreviews.authorization.k8s.io",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			d := &Doctor{cfg: test.cfg}
+
+			var perms []string
+			for _, perm := range d.controllerPermissions() {
+				perms = append(perms, perm.String())
+			}
+
+			for _, perm := range test.expPresent {
+				assert.Contains(t, perms, perm)
+			}
+
+			for _, perm := range test.expAbsent {
+				assert.NotContains(t, perms, perm)
+			}
+		})
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	report := &Report{}
+	report.add(Result{Name: "SMI CRDs", Status: StatusOK, Message: "supported versions installed"})
+	report.add(Result{Name: "Controller API", Status: StatusFailed, Message: "not ready"})
+
+	assert.True(t, report.Failed)
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	assert.Equal(t, "[ok]      SMI CRDs: supported versions installed\n[failed]  Controller API: not ready\n", text.String())
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteJSON(&out))
+
+	var got Report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, *report, got)
+}
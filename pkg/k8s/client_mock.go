@@ -61,9 +61,10 @@ func NewClientMock(path string) *ClientMock {
 	k8sObjects := MustParseYaml(yamlContent)
 
 	return &ClientMock{
-		kubeClient:  fakekubeclient.NewSimpleClientset(filterObjectsByKind(k8sObjects, CoreObjectKinds)...),
-		splitClient: fakesplitclient.NewSimpleClientset(filterObjectsByKind(k8sObjects, SplitObjectKinds)...),
-		specsClient: fakespecsclient.NewSimpleClientset(filterObjectsByKind(k8sObjects, SpecsObjectKinds)...),
+		kubeClient:   fakekubeclient.NewSimpleClientset(filterObjectsByKind(k8sObjects, CoreObjectKinds)...),
+		accessClient: fakeaccessclient.NewSimpleClientset(filterObjectsByKind(k8sObjects, AccessObjectKinds)...),
+		splitClient:  fakesplitclient.NewSimpleClientset(filterObjectsByKind(k8sObjects, SplitObjectKinds)...),
+		specsClient:  fakespecsclient.NewSimpleClientset(filterObjectsByKind(k8sObjects, SpecsObjectKinds)...),
 	}
 }
 
@@ -17,8 +17,14 @@ const (
 	// TCPRouteObjectKind is the name of an SMI object of kind TCPRoute.
 	TCPRouteObjectKind = "TCPRoute"
 
+	// StatusAnnotation is the annotation holding the status of the SMI resources processed by the controller. SMI CRDs
+	// do not have a status subresource, therefore the status is written as a JSON document in this annotation.
+	StatusAnnotation = "mesh.traefik.io/status"
+	// EventComponent is the name of the component reported in the Kubernetes Events emitted by the controller.
+	EventComponent = "traefik-mesh-controller"
+
 	// CoreObjectKinds is a filter for objects to process by the core client.
-	CoreObjectKinds = "Deployment|Endpoints|Service|Ingress|Secret|Namespace|Pod|ConfigMap"
+	CoreObjectKinds = "Deployment|DaemonSet|Endpoints|Service|Ingress|Secret|Namespace|Pod|ConfigMap"
 	// AccessObjectKinds is a filter for objects to process by the access client.
 	AccessObjectKinds = TrafficTargetObjectKind
 	// SpecsObjectKinds is a filter for objects to process by the specs client.
@@ -1,16 +1,24 @@
 package k8s
 
 import (
+	"github.com/traefik/mesh/pkg/annotations"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers/core/v1"
 )
 
 // ResourceFilter holds resource filtering rules.
 type ResourceFilter struct {
 	watchedNamespaces []string
 	ignoredNamespaces []string
+	namespaceSelector labels.Selector
+	namespaceLister   listers.NamespaceLister
 	ignoredServices   []namespaceName
 	ignoredApps       []string
+
+	meshEnabledAnnotation bool
+	optIn                 bool
 }
 
 type namespaceName struct {
@@ -28,6 +36,27 @@ func WatchNamespaces(namespaces ...string) ResourceFilterOption {
 	}
 }
 
+// WatchNamespaceSelector restricts the namespaces to watch to the ones having labels matching the given selector.
+// Namespace labels are resolved using the given lister.
+func WatchNamespaceSelector(selector labels.Selector, namespaceLister listers.NamespaceLister) ResourceFilterOption {
+	return func(filter *ResourceFilter) {
+		filter.namespaceSelector = selector
+		filter.namespaceLister = namespaceLister
+	}
+}
+
+// MeshEnabledAnnotation allows services to opt in or opt out of the mesh with the "mesh.traefik.io/enabled" annotation,
+// set on the service itself or on its namespace. The service annotation takes precedence over the namespace one. When
+// optIn is true, services are ignored unless they are explicitly enabled. Namespace annotations are resolved using the
+// given lister, which must be watching the namespaces.
+func MeshEnabledAnnotation(optIn bool, namespaceLister listers.NamespaceLister) ResourceFilterOption {
+	return func(filter *ResourceFilter) {
+		filter.meshEnabledAnnotation = true
+		filter.optIn = optIn
+		filter.namespaceLister = namespaceLister
+	}
+}
+
 // IgnoreNamespaces adds the given namespaces to the list of namespaces to ignore.
 func IgnoreNamespaces(namespaces ...string) ResourceFilterOption {
 	return func(filter *ResourceFilter) {
@@ -83,6 +112,11 @@ func (f *ResourceFilter) IsIgnored(obj interface{}) bool {
 		return true
 	}
 
+	// Check if the namespace labels match the namespace selector.
+	if f.namespaceSelector != nil && !f.isNamespaceSelected(pMeta.Namespace) {
+		return true
+	}
+
 	// Check if the "app" label doesn't contain a value which is ignored.
 	if contains(f.ignoredApps, pMeta.Labels["app"]) {
 		return true
@@ -98,11 +132,42 @@ func (f *ResourceFilter) IsIgnored(obj interface{}) bool {
 		if svc.Spec.Type == corev1.ServiceTypeExternalName {
 			return true
 		}
+
+		// Check if the service has not opted out of the mesh.
+		if f.meshEnabledAnnotation && !f.isServiceMeshEnabled(svc) {
+			return true
+		}
 	}
 
 	return false
 }
 
+// isNamespaceSelected returns true if the namespace with the given name has labels matching the namespace selector.
+func (f *ResourceFilter) isNamespaceSelected(name string) bool {
+	namespace, err := f.namespaceLister.Get(name)
+	if err != nil {
+		return false
+	}
+
+	return f.namespaceSelector.Matches(labels.Set(namespace.Labels))
+}
+
+// isServiceMeshEnabled returns true if the given service is part of the mesh. The "mesh.traefik.io/enabled" annotation
+// is looked up on the service first, then on its namespace. Invalid annotation values are considered as not set.
+func (f *ResourceFilter) isServiceMeshEnabled(svc *corev1.Service) bool {
+	if enabled, err := annotations.IsMeshEnabled(svc.Annotations); err == nil {
+		return enabled
+	}
+
+	if namespace, err := f.namespaceLister.Get(svc.Namespace); err == nil {
+		if enabled, err := annotations.IsMeshEnabled(namespace.Annotations); err == nil {
+			return enabled
+		}
+	}
+
+	return !f.optIn
+}
+
 func contains(slice []string, str string) bool {
 	for _, item := range slice {
 		if item == str {
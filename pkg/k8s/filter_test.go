@@ -4,8 +4,12 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestResourceFilter_New(t *testing.T) {
@@ -231,3 +235,140 @@ func TestResourceFilter_IgnoreService(t *testing.T) {
 	assert.Len(t, filter.watchedNamespaces, 0)
 	assert.Len(t, filter.ignoredApps, 0)
 }
+
+func TestResourceFilter_IsIgnoredWithNamespaceSelector(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "selected-ns",
+			Labels: map[string]string{"mesh.traefik.io/enabled": "true"},
+		},
+	}))
+	require.NoError(t, indexer.Add(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "unselected-ns",
+			Labels: map[string]string{"mesh.traefik.io/enabled": "false"},
+		},
+	}))
+
+	selector, err := labels.Parse("mesh.traefik.io/enabled=true")
+	require.NoError(t, err)
+
+	filter := NewResourceFilter(WatchNamespaceSelector(selector, listers.NewNamespaceLister(indexer)))
+
+	got := filter.IsIgnored(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "selected-ns"},
+	})
+	assert.False(t, got)
+
+	got = filter.IsIgnored(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "unselected-ns"},
+	})
+	assert.True(t, got)
+
+	got = filter.IsIgnored(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "unknown-ns"},
+	})
+	assert.True(t, got)
+}
+
+func TestResourceFilter_IsIgnoredWithMeshEnabledAnnotation(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "enabled-ns",
+			Annotations: map[string]string{"mesh.traefik.io/enabled": "true"},
+		},
+	}))
+	require.NoError(t, indexer.Add(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "disabled-ns",
+			Annotations: map[string]string{"mesh.traefik.io/enabled": "false"},
+		},
+	}))
+	require.NoError(t, indexer.Add(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default-ns",
+		},
+	}))
+
+	tests := []struct {
+		desc                  string
+		namespace             string
+		annotations           map[string]string
+		expectedOptOutIgnored bool
+		expectedOptInIgnored  bool
+	}{
+		{
+			desc:                 "service without annotation in a namespace without annotation",
+			namespace:            "default-ns",
+			expectedOptInIgnored: true,
+		},
+		{
+			desc:                  "service disabled in a namespace without annotation",
+			namespace:             "default-ns",
+			annotations:           map[string]string{"mesh.traefik.io/enabled": "false"},
+			expectedOptOutIgnored: true,
+			expectedOptInIgnored:  true,
+		},
+		{
+			desc:        "service enabled in a namespace without annotation",
+			namespace:   "default-ns",
+			annotations: map[string]string{"mesh.traefik.io/enabled": "true"},
+		},
+		{
+			desc:                  "service without annotation in a disabled namespace",
+			namespace:             "disabled-ns",
+			expectedOptOutIgnored: true,
+			expectedOptInIgnored:  true,
+		},
+		{
+			desc:        "service enabled in a disabled namespace",
+			namespace:   "disabled-ns",
+			annotations: map[string]string{"mesh.traefik.io/enabled": "true"},
+		},
+		{
+			desc:      "service without annotation in an enabled namespace",
+			namespace: "enabled-ns",
+		},
+		{
+			desc:                  "service disabled in an enabled namespace",
+			namespace:             "enabled-ns",
+			annotations:           map[string]string{"mesh.traefik.io/enabled": "false"},
+			expectedOptOutIgnored: true,
+			expectedOptInIgnored:  true,
+		},
+		{
+			desc:                 "service with an invalid annotation in a namespace without annotation",
+			namespace:            "default-ns",
+			annotations:          map[string]string{"mesh.traefik.io/enabled": "foo"},
+			expectedOptInIgnored: true,
+		},
+		{
+			desc:        "service enabled with the deprecated annotation",
+			namespace:   "disabled-ns",
+			annotations: map[string]string{"maesh.containo.us/enabled": "true"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			svc := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "svc",
+					Namespace:   test.namespace,
+					Annotations: test.annotations,
+				},
+			}
+
+			optOutFilter := NewResourceFilter(MeshEnabledAnnotation(false, listers.NewNamespaceLister(indexer)))
+			assert.Equal(t, test.expectedOptOutIgnored, optOutFilter.IsIgnored(svc))
+
+			optInFilter := NewResourceFilter(MeshEnabledAnnotation(true, listers.NewNamespaceLister(indexer)))
+			assert.Equal(t, test.expectedOptInIgnored, optInFilter.IsIgnored(svc))
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ServiceClusterIPs returns the ClusterIPs of the given service, one per IP family on dual-stack clusters. It falls
+// back to the ClusterIP on clusters which don't set the ClusterIPs, and returns nothing for headless services.
+func ServiceClusterIPs(svc *corev1.Service) []string {
+	clusterIPs := svc.Spec.ClusterIPs
+	if len(clusterIPs) == 0 && svc.Spec.ClusterIP != "" {
+		clusterIPs = []string{svc.Spec.ClusterIP}
+	}
+
+	var ips []string
+
+	for _, ip := range clusterIPs {
+		if ip == "" || ip == corev1.ClusterIPNone {
+			continue
+		}
+
+		ips = append(ips, ip)
+	}
+
+	return ips
+}
+
+// PodIPs returns the IPs of the given pod, one per IP family on dual-stack clusters. It falls back to the PodIP on
+// clusters which don't set the PodIPs.
+func PodIPs(pod *corev1.Pod) []string {
+	if len(pod.Status.PodIPs) == 0 {
+		if pod.Status.PodIP == "" {
+			return nil
+		}
+
+		return []string{pod.Status.PodIP}
+	}
+
+	ips := make([]string, 0, len(pod.Status.PodIPs))
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+
+	return ips
+}
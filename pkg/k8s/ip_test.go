@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestServiceClusterIPs(t *testing.T) {
+	tests := []struct {
+		desc   string
+		spec   corev1.ServiceSpec
+		expIPs []string
+	}{
+		{
+			desc:   "Single-stack service without ClusterIPs",
+			spec:   corev1.ServiceSpec{ClusterIP: "10.0.0.1"},
+			expIPs: []string{"10.0.0.1"},
+		},
+		{
+			desc:   "Dual-stack service",
+			spec:   corev1.ServiceSpec{ClusterIP: "10.0.0.1", ClusterIPs: []string{"10.0.0.1", "fd00::1"}},
+			expIPs: []string{"10.0.0.1", "fd00::1"},
+		},
+		{
+			desc:   "IPv6 service",
+			spec:   corev1.ServiceSpec{ClusterIP: "fd00::1", ClusterIPs: []string{"fd00::1"}},
+			expIPs: []string{"fd00::1"},
+		},
+		{
+			desc: "Headless service",
+			spec: corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone, ClusterIPs: []string{corev1.ClusterIPNone}},
+		},
+		{
+			desc: "Service without ClusterIP",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expIPs, ServiceClusterIPs(&corev1.Service{Spec: test.spec}))
+		})
+	}
+}
+
+func TestPodIPs(t *testing.T) {
+	tests := []struct {
+		desc   string
+		status corev1.PodStatus
+		expIPs []string
+	}{
+		{
+			desc:   "Single-stack pod without PodIPs",
+			status: corev1.PodStatus{PodIP: "10.4.0.1"},
+			expIPs: []string{"10.4.0.1"},
+		},
+		{
+			desc:   "Dual-stack pod",
+			status: corev1.PodStatus{PodIP: "10.4.0.1", PodIPs: []corev1.PodIP{{IP: "10.4.0.1"}, {IP: "fd00:4::1"}}},
+			expIPs: []string{"10.4.0.1", "fd00:4::1"},
+		},
+		{
+			desc: "Pod without IP",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expIPs, PodIPs(&corev1.Pod{Status: test.status}))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package k8s
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// ShadowServiceNamespaceLabel is the label holding the namespace of the service a shadow service is created for.
+	ShadowServiceNamespaceLabel = "mesh.traefik.io/service-namespace"
+	// ShadowServiceNameLabel is the label holding the name of the service a shadow service is created for.
+	ShadowServiceNameLabel = "mesh.traefik.io/service-name"
+
+	// shadowServiceHashLength is the number of hexadecimal characters of the hash ending the bounded shadow service
+	// names.
+	shadowServiceHashLength = 16
+)
+
+// ShadowServiceName returns the name of the shadow service, in the given Traefik Mesh namespace, of the service with
+// the given namespace and name. The name is `<traefik-mesh-namespace>-<name>-6d61657368-<namespace>`, which the
+// CoreDNS rewrite rules resolve, unless it is longer than a DNS label. In this case, the name is truncated and ends
+// with a hash of the service namespace and name, and it can only be resolved through the shadow service labels.
+func ShadowServiceName(traefikMeshNamespace, namespace, name string) string {
+	shadowSvcName := unboundedShadowServiceName(traefikMeshNamespace, namespace, name)
+	if len(shadowSvcName) <= validation.DNS1035LabelMaxLength {
+		return shadowSvcName
+	}
+
+	hash := sha256.Sum256([]byte(namespace + "/" + name))
+	prefix := strings.TrimRight(shadowSvcName[:validation.DNS1035LabelMaxLength-shadowServiceHashLength-1], "-")
+
+	return prefix + "-" + hex.EncodeToString(hash[:])[:shadowServiceHashLength]
+}
+
+// IsHashedShadowServiceName returns true if the name of the shadow service of the service with the given namespace and
+// name is bounded with a hash, in which case the CoreDNS rewrite rules cannot resolve it.
+func IsHashedShadowServiceName(traefikMeshNamespace, namespace, name string) bool {
+	return len(unboundedShadowServiceName(traefikMeshNamespace, namespace, name)) > validation.DNS1035LabelMaxLength
+}
+
+func unboundedShadowServiceName(traefikMeshNamespace, namespace, name string) string {
+	return fmt.Sprintf("%s-%s-6d61657368-%s", traefikMeshNamespace, name, namespace)
+}
+
+// ShadowServiceLabels returns the labels of the shadow service of the service with the given namespace and name.
+func ShadowServiceLabels(namespace, name string) map[string]string {
+	return map[string]string{
+		"app":                       "maesh",
+		"type":                      "shadow",
+		ShadowServiceNamespaceLabel: namespace,
+		ShadowServiceNameLabel:      name,
+	}
+}
+
+// ShadowServiceSource returns the namespace and the name of the service the given shadow service is created for, read
+// from its labels. It returns false if the shadow service has been created by a version without these labels.
+func ShadowServiceSource(shadowSvc *corev1.Service) (namespace, name string, ok bool) {
+	namespace = shadowSvc.Labels[ShadowServiceNamespaceLabel]
+	name = shadowSvc.Labels[ShadowServiceNameLabel]
+
+	if namespace == "" || name == "" {
+		return "", "", false
+	}
+
+	return namespace, name, true
+}
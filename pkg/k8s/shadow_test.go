@@ -0,0 +1,83 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestShadowServiceName(t *testing.T) {
+	longName := strings.Repeat("a", 63)
+	longNamespace := strings.Repeat("b", 63)
+
+	tests := []struct {
+		desc      string
+		namespace string
+		name      string
+		expName   string
+		expHashed bool
+	}{
+		{
+			desc:      "Short service name and namespace",
+			namespace: "bar",
+			name:      "foo",
+			expName:   "traefik-mesh-foo-6d61657368-bar",
+		},
+		{
+			desc:      "Long service name",
+			namespace: "bar",
+			name:      longName,
+			expName:   "traefik-mesh-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-",
+			expHashed: true,
+		},
+		{
+			desc:      "Long service name and namespace",
+			namespace: longNamespace,
+			name:      longName,
+			expName:   "traefik-mesh-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-",
+			expHashed: true,
+		},
+		{
+			desc:      "Truncated on a dash",
+			namespace: longNamespace,
+			name:      "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-a",
+			expName:   "traefik-mesh-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-",
+			expHashed: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			name := ShadowServiceName("traefik-mesh", test.namespace, test.name)
+
+			assert.LessOrEqual(t, len(name), validation.DNS1035LabelMaxLength)
+			assert.Empty(t, validation.IsDNS1035Label(name))
+			assert.True(t, strings.HasPrefix(name, test.expName), name)
+			assert.Equal(t, name, ShadowServiceName("traefik-mesh", test.namespace, test.name))
+			assert.Equal(t, test.expHashed, IsHashedShadowServiceName("traefik-mesh", test.namespace, test.name))
+		})
+	}
+
+	// Services with the same truncated prefix get different names.
+	assert.NotEqual(t, ShadowServiceName("traefik-mesh", "bar", longName), ShadowServiceName("traefik-mesh", "baz", longName))
+}
+
+func TestShadowServiceSource(t *testing.T) {
+	shadowSvc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Labels: ShadowServiceLabels("bar", "foo")}}
+
+	namespace, name, ok := ShadowServiceSource(shadowSvc)
+	require.True(t, ok)
+	assert.Equal(t, "bar", namespace)
+	assert.Equal(t, "foo", name)
+
+	_, _, ok = ShadowServiceSource(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "maesh", "type": "shadow"}}})
+	assert.False(t, ok)
+}
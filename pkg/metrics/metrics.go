@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	metricsNamespace = "traefik_mesh"
+	metricsSubsystem = "controller"
+)
+
+// WorkQueueName is the name of the controller work queue, whose depth is reported by the work queue metrics provider.
+const WorkQueueName = "traefik_mesh_controller"
+
+// Resource kinds reported by the topology resources metric.
+const (
+	ResourceKindService       = "service"
+	ResourceKindPod           = "pod"
+	ResourceKindTrafficTarget = "traffic_target"
+	ResourceKindTrafficSplit  = "traffic_split"
+)
+
+// Registry records the controller metrics.
+type Registry interface {
+	// IncWorkQueueRetries increments the number of work items re-queued after a failure.
+	IncWorkQueueRetries()
+	// ObserveTopologyBuildDuration records the duration of a topology build.
+	ObserveTopologyBuildDuration(duration time.Duration)
+	// SetTopologyResources sets the number of resources of the given kind found in the last topology.
+	SetTopologyResources(kind string, count int)
+	// SetServiceConfigurationErrors sets the number of configuration errors of each service, keyed by namespace and
+	// name. Services which are not part of the given map are reset.
+	SetServiceConfigurationErrors(errs map[ServiceKey]int)
+	// SetPortMappingUsage sets the number of used ports and the number of available ports of the port mapping of the
+	// given protocol.
+	SetPortMappingUsage(protocol string, used, capacity int)
+	// SetInformerSynced sets the cache sync status of the given informer.
+	SetInformerSynced(informer string, synced bool)
+}
+
+// ServiceKey identifies a service.
+type ServiceKey struct {
+	Namespace string
+	Name      string
+}
+
+// PrometheusRegistry is a Registry exposing the metrics to Prometheus.
+type PrometheusRegistry struct {
+	workQueueDepth             prometheus.Gauge
+	workQueueRetries           prometheus.Counter
+	topologyBuildDuration      prometheus.Histogram
+	topologyResources          *prometheus.GaugeVec
+	serviceConfigurationErrors *prometheus.GaugeVec
+	portMappingUsedPorts       *prometheus.GaugeVec
+	portMappingAvailablePorts  *prometheus.GaugeVec
+	informerSynced             *prometheus.GaugeVec
+}
+
+// NewPrometheusRegistry creates a new PrometheusRegistry and registers its metrics with the given registerer.
+func NewPrometheusRegistry(registerer prometheus.Registerer) (*PrometheusRegistry, error) {
+	r := &PrometheusRegistry{
+		workQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "workqueue_depth",
+			Help:      "Number of items waiting in the work queue.",
+		}),
+		workQueueRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "workqueue_retries_total",
+			Help:      "Number of work items re-queued after a failure.",
+		}),
+		topologyBuildDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "topology_build_duration_seconds",
+			Help:      "Duration of the topology builds.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		topologyResources: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "topology_resources",
+			Help:      "Number of resources found in the last topology, by kind.",
+		}, []string{"kind"}),
+		serviceConfigurationErrors: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "service_configuration_errors",
+			Help:      "Number of configuration errors found in the last topology, by service.",
+		}, []string{"namespace", "service"}),
+		portMappingUsedPorts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "port_mapping_used_ports",
+			Help:      "Number of ports used by the port mapping, by protocol.",
+		}, []string{"protocol"}),
+		portMappingAvailablePorts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "port_mapping_available_ports",
+			Help:      "Number of ports which can be allocated by the port mapping, by protocol.",
+		}, []string{"protocol"}),
+		informerSynced: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "informer_synced",
+			Help:      "Cache sync status of the informers, 1 when synced.",
+		}, []string{"informer"}),
+	}
+
+	collectors := []prometheus.Collector{
+		r.workQueueDepth,
+		r.workQueueRetries,
+		r.topologyBuildDuration,
+		r.topologyResources,
+		r.serviceConfigurationErrors,
+		r.portMappingUsedPorts,
+		r.portMappingAvailablePorts,
+		r.informerSynced,
+	}
+
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("unable to register metric: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+// WorkQueueMetricsProvider returns a workqueue.MetricsProvider reporting the depth of the controller work queue, which
+// is updated by the queue itself each time an item is added or taken. It must be given to workqueue.SetProvider before
+// the work queue is created.
+func (r *PrometheusRegistry) WorkQueueMetricsProvider() workqueue.MetricsProvider {
+	return workQueueMetricsProvider{depth: r.workQueueDepth}
+}
+
+// IncWorkQueueRetries increments the number of work items re-queued after a failure.
+func (r *PrometheusRegistry) IncWorkQueueRetries() {
+	r.workQueueRetries.Inc()
+}
+
+// ObserveTopologyBuildDuration records the duration of a topology build.
+func (r *PrometheusRegistry) ObserveTopologyBuildDuration(duration time.Duration) {
+	r.topologyBuildDuration.Observe(duration.Seconds())
+}
+
+// SetTopologyResources sets the number of resources of the given kind found in the last topology.
+func (r *PrometheusRegistry) SetTopologyResources(kind string, count int) {
+	r.topologyResources.WithLabelValues(kind).Set(float64(count))
+}
+
+// SetServiceConfigurationErrors sets the number of configuration errors of each service. Services which are not part
+// of the given map are reset, this way services which have been fixed or removed are not reported anymore.
+func (r *PrometheusRegistry) SetServiceConfigurationErrors(errs map[ServiceKey]int) {
+	r.serviceConfigurationErrors.Reset()
+
+	for key, count := range errs {
+		r.serviceConfigurationErrors.WithLabelValues(key.Namespace, key.Name).Set(float64(count))
+	}
+}
+
+// SetPortMappingUsage sets the number of used ports and the number of available ports of the port mapping of the
+// given protocol.
+func (r *PrometheusRegistry) SetPortMappingUsage(protocol string, used, capacity int) {
+	r.portMappingUsedPorts.WithLabelValues(protocol).Set(float64(used))
+	r.portMappingAvailablePorts.WithLabelValues(protocol).Set(float64(capacity))
+}
+
+// SetInformerSynced sets the cache sync status of the given informer.
+func (r *PrometheusRegistry) SetInformerSynced(informer string, synced bool) {
+	var value float64
+	if synced {
+		value = 1
+	}
+
+	r.informerSynced.WithLabelValues(informer).Set(value)
+}
+
+// NoopRegistry is a Registry which doesn't record anything.
+type NoopRegistry struct{}
+
+// IncWorkQueueRetries does nothing.
+func (NoopRegistry) IncWorkQueueRetries() {}
+
+// ObserveTopologyBuildDuration does nothing.
+func (NoopRegistry) ObserveTopologyBuildDuration(time.Duration) {}
+
+// SetTopologyResources does nothing.
+func (NoopRegistry) SetTopologyResources(string, int) {}
+
+// SetServiceConfigurationErrors does nothing.
+func (NoopRegistry) SetServiceConfigurationErrors(map[ServiceKey]int) {}
+
+// SetPortMappingUsage does nothing.
+func (NoopRegistry) SetPortMappingUsage(string, int, int) {}
+
+// SetInformerSynced does nothing.
+func (NoopRegistry) SetInformerSynced(string, bool) {}
+
+// workQueueMetricsProvider is a workqueue.MetricsProvider which only records the depth of the controller work queue.
+type workQueueMetricsProvider struct {
+	depth prometheus.Gauge
+}
+
+func (p workQueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	if name != WorkQueueName {
+		return noopMetric{}
+	}
+
+	return p.depth
+}
+
+func (workQueueMetricsProvider) NewAddsMetric(string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+
+func (workQueueMetricsProvider) NewLatencyMetric(string) workqueue.HistogramMetric {
+	return noopMetric{}
+}
+
+func (workQueueMetricsProvider) NewWorkDurationMetric(string) workqueue.HistogramMetric {
+	return noopMetric{}
+}
+
+func (workQueueMetricsProvider) NewUnfinishedWorkSecondsMetric(string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (workQueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (workQueueMetricsProvider) NewRetriesMetric(string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+
+// noopMetric is a work queue metric which doesn't record anything.
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
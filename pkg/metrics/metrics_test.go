@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusRegistry(t *testing.T) {
+	promRegistry := prometheus.NewRegistry()
+
+	registry, err := NewPrometheusRegistry(promRegistry)
+	require.NoError(t, err)
+
+	depth := registry.WorkQueueMetricsProvider().NewDepthMetric(WorkQueueName)
+	depth.Inc()
+	depth.Inc()
+	depth.Inc()
+	depth.Dec()
+
+	// The depth of the other work queues is not recorded.
+	registry.WorkQueueMetricsProvider().NewDepthMetric("other").Inc()
+
+	registry.IncWorkQueueRetries()
+	registry.IncWorkQueueRetries()
+	registry.ObserveTopologyBuildDuration(200 * time.Millisecond)
+	registry.SetTopologyResources(ResourceKindService, 4)
+	registry.SetTopologyResources(ResourceKindTrafficSplit, 1)
+	registry.SetServiceConfigurationErrors(map[ServiceKey]int{
+		{Namespace: "ns", Name: "svc-1"}: 2,
+		{Namespace: "ns", Name: "svc-2"}: 1,
+	})
+	registry.SetServiceConfigurationErrors(map[ServiceKey]int{
+		{Namespace: "ns", Name: "svc-1"}: 1,
+	})
+	registry.SetPortMappingUsage("tcp", 2, 25)
+	registry.SetInformerSynced("*v1.Service", true)
+	registry.SetInformerSynced("*v1alpha2.TrafficTarget", false)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(registry.workQueueDepth))
+	assert.Equal(t, float64(2), testutil.ToFloat64(registry.workQueueRetries))
+	assert.Equal(t, 1, testutil.CollectAndCount(registry.topologyBuildDuration))
+
+	expected := `
+# HELP traefik_mesh_controller_informer_synced Cache sync status of the informers, 1 when synced.
+# TYPE traefik_mesh_controller_informer_synced gauge
+traefik_mesh_controller_informer_synced{informer="*v1.Service"} 1
+traefik_mesh_controller_informer_synced{informer="*v1alpha2.TrafficTarget"} 0
+# HELP traefik_mesh_controller_port_mapping_available_ports Number of ports which can be allocated by the port mapping, by protocol.
+# TYPE traefik_mesh_controller_port_mapping_available_ports gauge
+traefik_mesh_controller_port_mapping_available_ports{protocol="tcp"} 25
+# HELP traefik_mesh_controller_port_mapping_used_ports Number of ports used by the port mapping, by protocol.
+# TYPE traefik_mesh_controller_port_mapping_used_ports gauge
+traefik_mesh_controller_port_mapping_used_ports{protocol="tcp"} 2
+# HELP traefik_mesh_controller_service_configuration_errors Number of configuration errors found in the last topology, by service.
+# TYPE traefik_mesh_controller_service_configuration_errors gauge
+traefik_mesh_controller_service_configuration_errors{namespace="ns",service="svc-1"} 1
+# HELP traefik_mesh_controller_topology_resources Number of resources found in the last topology, by kind.
+# TYPE traefik_mesh_controller_topology_resources gauge
+traefik_mesh_controller_topology_resources{kind="service"} 4
+traefik_mesh_controller_topology_resources{kind="traffic_split"} 1
+`
+
+	err = testutil.GatherAndCompare(promRegistry, strings.NewReader(expected),
+		"traefik_mesh_controller_informer_synced",
+		"traefik_mesh_controller_port_mapping_available_ports",
+		"traefik_mesh_controller_port_mapping_used_ports",
+		"traefik_mesh_controller_service_configuration_errors",
+		"traefik_mesh_controller_topology_resources",
+	)
+	assert.NoError(t, err)
+}
+
+func TestNewPrometheusRegistry_AlreadyRegistered(t *testing.T) {
+	promRegistry := prometheus.NewRegistry()
+
+	_, err := NewPrometheusRegistry(promRegistry)
+	require.NoError(t, err)
+
+	_, err = NewPrometheusRegistry(promRegistry)
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadOrCreateCertificateAuthority(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	ca, err := LoadOrCreateCertificateAuthority(context.Background(), client, "traefik-mesh", "traefik-mesh-ca")
+	require.NoError(t, err)
+
+	secret, err := client.CoreV1().Secrets("traefik-mesh").Get(context.Background(), "traefik-mesh-ca", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	assert.Equal(t, ca.CertificatePEM(), secret.Data[corev1.TLSCertKey])
+
+	// The CA stored in the Secret must be reused.
+	loaded, err := LoadOrCreateCertificateAuthority(context.Background(), client, "traefik-mesh", "traefik-mesh-ca")
+	require.NoError(t, err)
+	assert.Equal(t, ca.CertificatePEM(), loaded.CertificatePEM())
+	assert.Equal(t, ca.Info(), loaded.Info())
+}
+
+func TestParseCertificateAuthority_notCA(t *testing.T) {
+	ca, err := NewCertificateAuthority()
+	require.NoError(t, err)
+
+	cert, err := ca.Issue("foo", nil, nil, nil, time.Hour)
+	require.NoError(t, err)
+
+	_, err = ParseCertificateAuthority(cert.CertPEM, cert.KeyPEM)
+	assert.Error(t, err)
+}
+
+func TestCertificateAuthority_Issue(t *testing.T) {
+	ca, err := NewCertificateAuthority()
+	require.NoError(t, err)
+
+	uri := SPIFFEID("cluster.local", "my-ns", "my-sa")
+
+	cert, err := ca.Issue("foo", []string{"*.my-ns.traefik.mesh"}, []net.IP{net.ParseIP("10.10.10.10")}, []*url.URL{uri}, time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, "CN=foo", cert.Info.Subject)
+	assert.Equal(t, []string{"*.my-ns.traefik.mesh"}, cert.Info.DNSNames)
+	assert.Equal(t, []string{"spiffe://cluster.local/ns/my-ns/sa/my-sa"}, cert.Info.URIs)
+
+	block, _ := pem.Decode(cert.CertPEM)
+	require.NotNil(t, block)
+
+	x509Cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(ca.CertificatePEM()))
+
+	_, err = x509Cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.10.10.10", x509Cert.IPAddresses[0].String())
+}
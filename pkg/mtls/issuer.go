@@ -0,0 +1,194 @@
+package mtls
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traefik/mesh/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DefaultNodeCertificateValidity is the default validity of the certificates issued to the mesh nodes.
+const DefaultNodeCertificateValidity = 24 * time.Hour
+
+// SPIFFEID returns the SPIFFE ID identifying the given service account in the given trust domain.
+func SPIFFEID(trustDomain, namespace, serviceAccount string) *url.URL {
+	return &url.URL{
+		Scheme: "spiffe",
+		Host:   trustDomain,
+		Path:   fmt.Sprintf("/ns/%s/sa/%s", namespace, serviceAccount),
+	}
+}
+
+// NodeCertificateIssuer issues the certificates of the mesh nodes. Certificates are issued on demand, and renewed
+// once two thirds of their validity have elapsed, or when the node IP or the meshed namespaces change.
+type NodeCertificateIssuer struct {
+	ca          *CertificateAuthority
+	validity    time.Duration
+	trustDomain string
+	domains     []string
+
+	mu    sync.Mutex
+	nodes map[types.UID]*nodeCertificate
+}
+
+type nodeCertificate struct {
+	name      string
+	ips       []string
+	dnsNames  []string
+	cert      *Certificate
+	rotations int
+}
+
+// NodeCertificateStatus is the status of the certificate of a mesh node.
+type NodeCertificateStatus struct {
+	Node        string          `json:"node"`
+	IP          string          `json:"ip"`
+	IPs         []string        `json:"ips,omitempty"`
+	Certificate CertificateInfo `json:"certificate"`
+	// Rotations is the number of times the certificate of the node has been renewed.
+	Rotations int `json:"rotations"`
+}
+
+// NewNodeCertificateIssuer creates a new NodeCertificateIssuer issuing certificates from the given CA, valid for the
+// given duration. The certificates identify the service account of the nodes with a SPIFFE ID in the given trust
+// domain, and are valid for the names of the services under the given mesh domains.
+func NewNodeCertificateIssuer(ca *CertificateAuthority, validity time.Duration, trustDomain string, domains []string) *NodeCertificateIssuer {
+	return &NodeCertificateIssuer{
+		ca:          ca,
+		validity:    validity,
+		trustDomain: trustDomain,
+		domains:     domains,
+		nodes:       make(map[types.UID]*nodeCertificate),
+	}
+}
+
+// RootCA returns the PEM encoded certificate of the CA issuing the node certificates.
+func (i *NodeCertificateIssuer) RootCA() []byte {
+	return i.ca.CertificatePEM()
+}
+
+// Get returns the certificate of the given mesh node, issuing or renewing it if needed. The certificate is valid for
+// the <service>.<namespace>.<domain> names of the services of the given namespaces, through which the node serves the
+// requests sent over mTLS.
+func (i *NodeCertificateIssuer) Get(pod *corev1.Pod, namespaces []string) (*Certificate, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+
+	// Dual-stack nodes are reached over each of their IPs.
+	podIPs := k8s.PodIPs(pod)
+	dnsNames := i.dnsNames(namespaces)
+
+	node, ok := i.nodes[pod.UID]
+	if ok && strings.Join(node.ips, ",") == strings.Join(podIPs, ",") &&
+		strings.Join(node.dnsNames, ",") == strings.Join(dnsNames, ",") &&
+		!i.needsRenewal(node.cert, now) {
+		return node.cert, nil
+	}
+
+	if len(podIPs) == 0 {
+		return nil, fmt.Errorf("no IP for node %q", pod.Name)
+	}
+
+	ips := make([]net.IP, 0, len(podIPs))
+
+	for _, podIP := range podIPs {
+		ip := net.ParseIP(podIP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP %q for node %q", podIP, pod.Name)
+		}
+
+		ips = append(ips, ip)
+	}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+
+	uri := SPIFFEID(i.trustDomain, pod.Namespace, serviceAccount)
+
+	cert, err := i.ca.Issue(pod.Name, dnsNames, ips, []*url.URL{uri}, i.validity)
+	if err != nil {
+		return nil, fmt.Errorf("unable to issue certificate for node %q: %w", pod.Name, err)
+	}
+
+	var rotations int
+	if ok {
+		rotations = node.rotations + 1
+	}
+
+	i.nodes[pod.UID] = &nodeCertificate{
+		name:      pod.Name,
+		ips:       podIPs,
+		dnsNames:  dnsNames,
+		cert:      cert,
+		rotations: rotations,
+	}
+
+	// Forget the expired certificates, which belong to nodes which are gone.
+	for uid, node := range i.nodes {
+		if node.cert.Info.NotAfter.Before(now) {
+			delete(i.nodes, uid)
+		}
+	}
+
+	return cert, nil
+}
+
+// Status returns the status of the certificates of the mesh nodes, sorted by node name.
+func (i *NodeCertificateIssuer) Status() []NodeCertificateStatus {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	statuses := make([]NodeCertificateStatus, 0, len(i.nodes))
+
+	for _, node := range i.nodes {
+		statuses = append(statuses, NodeCertificateStatus{
+			Node:        node.name,
+			IP:          node.ips[0],
+			IPs:         node.ips,
+			Certificate: node.cert.Info,
+			Rotations:   node.rotations,
+		})
+	}
+
+	sort.Slice(statuses, func(a, b int) bool {
+		return statuses[a].Node < statuses[b].Node
+	})
+
+	return statuses
+}
+
+// CA returns information about the CA certificate.
+func (i *NodeCertificateIssuer) CA() CertificateInfo {
+	return i.ca.Info()
+}
+
+// dnsNames returns the sorted wildcard names matching the services of the given namespaces under the mesh domains.
+func (i *NodeCertificateIssuer) dnsNames(namespaces []string) []string {
+	dnsNames := make([]string, 0, len(namespaces)*len(i.domains))
+
+	for _, namespace := range namespaces {
+		for _, domain := range i.domains {
+			dnsNames = append(dnsNames, fmt.Sprintf("*.%s.%s", namespace, domain))
+		}
+	}
+
+	sort.Strings(dnsNames)
+
+	return dnsNames
+}
+
+// needsRenewal returns true if less than a third of the validity of the given certificate remains.
+func (i *NodeCertificateIssuer) needsRenewal(cert *Certificate, now time.Time) bool {
+	return cert.Info.NotAfter.Sub(now) < i.validity/3
+}
@@ -0,0 +1,150 @@
+package mtls
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeCertificateIssuer_Get(t *testing.T) {
+	ca, err := NewCertificateAuthority()
+	require.NoError(t, err)
+
+	issuer := NewNodeCertificateIssuer(ca, time.Hour, "cluster.local", []string{"traefik.mesh"})
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-pod-1", Namespace: "traefik-mesh", UID: "uid-1"},
+		Spec:       corev1.PodSpec{ServiceAccountName: "traefik-mesh-proxy"},
+		Status:     corev1.PodStatus{PodIP: "10.10.10.10"},
+	}
+
+	cert, err := issuer.Get(pod, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spiffe://cluster.local/ns/traefik-mesh/sa/traefik-mesh-proxy"}, cert.Info.URIs)
+
+	// The certificate must be reused while it is valid.
+	again, err := issuer.Get(pod, nil)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Info.SerialNumber, again.Info.SerialNumber)
+
+	// A new certificate must be issued when the node IP changes.
+	pod.Status.PodIP = "10.10.10.11"
+
+	rotated, err := issuer.Get(pod, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, cert.Info.SerialNumber, rotated.Info.SerialNumber)
+
+	statuses := issuer.Status()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "mesh-pod-1", statuses[0].Node)
+	assert.Equal(t, "10.10.10.11", statuses[0].IP)
+	assert.Equal(t, rotated.Info, statuses[0].Certificate)
+	assert.Equal(t, 1, statuses[0].Rotations)
+}
+
+func TestNodeCertificateIssuer_GetRenewsCertificates(t *testing.T) {
+	ca, err := NewCertificateAuthority()
+	require.NoError(t, err)
+
+	// Certificates are renewed once two thirds of their validity have elapsed, which is the case right away with such
+	// a short validity.
+	issuer := NewNodeCertificateIssuer(ca, time.Nanosecond, "cluster.local", []string{"traefik.mesh"})
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-pod-1", Namespace: "traefik-mesh", UID: "uid-1"},
+		Status:     corev1.PodStatus{PodIP: "10.10.10.10"},
+	}
+
+	cert, err := issuer.Get(pod, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spiffe://cluster.local/ns/traefik-mesh/sa/default"}, cert.Info.URIs)
+
+	renewed, err := issuer.Get(pod, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, cert.Info.SerialNumber, renewed.Info.SerialNumber)
+}
+
+func TestNodeCertificateIssuer_GetInvalidIP(t *testing.T) {
+	ca, err := NewCertificateAuthority()
+	require.NoError(t, err)
+
+	issuer := NewNodeCertificateIssuer(ca, time.Hour, "cluster.local", []string{"traefik.mesh"})
+
+	_, err = issuer.Get(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "mesh-pod-1", UID: "uid-1"}}, nil)
+	assert.Error(t, err)
+	assert.Empty(t, issuer.Status())
+}
+
+func TestNodeCertificateIssuer_GetDualStack(t *testing.T) {
+	ca, err := NewCertificateAuthority()
+	require.NoError(t, err)
+
+	issuer := NewNodeCertificateIssuer(ca, time.Hour, "cluster.local", []string{"traefik.mesh"})
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-pod-1", Namespace: "traefik-mesh", UID: "uid-1"},
+		Status: corev1.PodStatus{
+			PodIP:  "10.10.10.10",
+			PodIPs: []corev1.PodIP{{IP: "10.10.10.10"}, {IP: "fd00::10"}},
+		},
+	}
+
+	cert, err := issuer.Get(pod, nil)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(cert.CertPEM)
+	require.NotNil(t, block)
+
+	x509Cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	require.Len(t, x509Cert.IPAddresses, 2)
+	assert.Equal(t, "10.10.10.10", x509Cert.IPAddresses[0].String())
+	assert.Equal(t, "fd00::10", x509Cert.IPAddresses[1].String())
+
+	statuses := issuer.Status()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "10.10.10.10", statuses[0].IP)
+	assert.Equal(t, []string{"10.10.10.10", "fd00::10"}, statuses[0].IPs)
+}
+
+func TestNodeCertificateIssuer_GetDNSNames(t *testing.T) {
+	ca, err := NewCertificateAuthority()
+	require.NoError(t, err)
+
+	issuer := NewNodeCertificateIssuer(ca, time.Hour, "cluster.local", []string{"traefik.mesh", "maesh"})
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-pod-1", Namespace: "traefik-mesh", UID: "uid-1"},
+		Status:     corev1.PodStatus{PodIP: "10.10.10.10"},
+	}
+
+	cert, err := issuer.Get(pod, []string{"ns-b", "ns-a"})
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(cert.CertPEM)
+	require.NotNil(t, block)
+
+	x509Cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"*.ns-a.maesh", "*.ns-a.traefik.mesh", "*.ns-b.maesh", "*.ns-b.traefik.mesh"}, x509Cert.DNSNames)
+	assert.NoError(t, x509Cert.VerifyHostname("svc.ns-a.traefik.mesh"))
+
+	// The certificate is kept while the namespaces don't change.
+	again, err := issuer.Get(pod, []string{"ns-a", "ns-b"})
+	require.NoError(t, err)
+	assert.Equal(t, cert.Info.SerialNumber, again.Info.SerialNumber)
+
+	// A new namespace requires a new certificate.
+	reissued, err := issuer.Get(pod, []string{"ns-a", "ns-b", "ns-c"})
+	require.NoError(t, err)
+	assert.NotEqual(t, cert.Info.SerialNumber, reissued.Info.SerialNumber)
+	assert.Contains(t, reissued.Info.DNSNames, "*.ns-c.traefik.mesh")
+}
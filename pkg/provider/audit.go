@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/traefik/mesh/pkg/topology"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+)
+
+// ACLAuditHeader is the header set, in ACL audit mode, on the requests which would have been denied.
+const ACLAuditHeader = "X-Traefik-Mesh-Acl-Audit"
+
+// ACLAuditDenied is the value of the ACLAuditHeader header set on the requests which would have been denied.
+const ACLAuditDenied = "denied"
+
+const aclAuditMiddlewareKey = "acl-audit-middleware"
+
+// buildAuditRouters builds, in ACL audit mode, the routers replacing the block-all routers. They forward the requests
+// which are not authorized by a TrafficTarget to the pods of the service, tagged with the ACLAuditHeader header.
+func (p *Provider) buildAuditRouters(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, scheme string, middlewares []string) {
+	if _, ok := cfg.HTTP.Middlewares[aclAuditMiddlewareKey]; !ok {
+		cfg.HTTP.Middlewares[aclAuditMiddlewareKey] = &dynamic.Middleware{
+			Headers: &dynamic.Headers{
+				CustomRequestHeaders: map[string]string{ACLAuditHeader: ACLAuditDenied},
+			},
+		}
+	}
+
+	svcKey := topology.Key{Name: svc.Name, Namespace: svc.Namespace}
+
+	p.buildServicesAndRoutersForHTTPService(t, cfg, svc, scheme, addToSliceCopy(middlewares, aclAuditMiddlewareKey), svcKey)
+}
+
+// buildWhitelistedHTTPRouter builds a router which accepts only the requests allowed by the given IPWhiteList
+// middleware. The middleware is added to the configuration and to the router middlewares, except in ACL audit mode
+// where the allowed IPs are matched by the router rule instead, letting the other requests fall back to the audit
+// routers.
+func (p *Provider) buildWhitelistedHTTPRouter(cfg *dynamic.Configuration, rule, entrypoint string, middlewares []string, whitelistKey string, whitelist *dynamic.Middleware, svcKey string, priority int) *dynamic.Router {
+	if !p.config.ACLAudit {
+		cfg.HTTP.Middlewares[whitelistKey] = whitelist
+
+		return buildHTTPRouter(rule, entrypoint, addToSliceCopy(middlewares, whitelistKey), svcKey, priority)
+	}
+
+	return buildHTTPRouter(buildHTTPRuleFromWhitelist(rule, whitelist.IPWhiteList), entrypoint, middlewares, svcKey, priority)
+}
+
+// buildHTTPRuleFromWhitelist returns the given rule, restricted to the requests coming from the IPs allowed by the
+// given IPWhiteList. When the whitelist is based on the X-Forwarded-For header, the last IP of the header is matched.
+func buildHTTPRuleFromWhitelist(rule string, whitelist *dynamic.IPWhiteList) string {
+	ips := whitelist.SourceRange
+	if len(ips) == 0 {
+		// Nothing is allowed, match an IP which can't be the one of a pod like the block-all middleware does.
+		ips = []string{"255.255.255.255"}
+	}
+
+	if whitelist.IPStrategy != nil && whitelist.IPStrategy.Depth > 0 {
+		quoted := make([]string, len(ips))
+		for i, ip := range ips {
+			quoted[i] = regexp.QuoteMeta(ip)
+		}
+
+		return fmt.Sprintf("(%s) && HeadersRegexp(`X-Forwarded-For`, `(^|[ ,])(%s)$`)", rule, strings.Join(quoted, "|"))
+	}
+
+	return fmt.Sprintf("(%s) && ClientIP(`%s`)", rule, strings.Join(ips, "`, `"))
+}
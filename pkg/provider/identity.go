@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"net/url"
+	"sort"
+
+	"github.com/traefik/mesh/pkg/mtls"
+	"github.com/traefik/mesh/pkg/topology"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	traefiktls "github.com/traefik/traefik/v2/pkg/tls"
+	"github.com/traefik/traefik/v2/pkg/types"
+)
+
+// ACLIdentityAuthorizePath is the path of the API endpoint authorizing the requests in identity ACL mode. It expects
+// the allowed identities in the "identity" query parameters, and the client certificate SANs in the
+// X-Forwarded-Tls-Client-Cert-Info header. With the "audit" query parameter, denied requests are allowed and tagged
+// with the ACLAuditHeader header.
+const ACLIdentityAuthorizePath = "/api/acl/authorize"
+
+// ACLClientCertInfoHeader is the header in which the proxies pass the SANs of the client certificate to the API. It
+// is the only request header forwarded to the API, which keeps the credentials of the applications away from it.
+const ACLClientCertInfoHeader = "X-Forwarded-Tls-Client-Cert-Info"
+
+// ACLSourceIdentityHeader is the header in which the API returns the identity of the authorized client, forwarded to
+// the destination when tracing headers are enabled.
+const ACLSourceIdentityHeader = "X-Traefik-Mesh-Source-Identity"
+
+const (
+	// ACLIdentityTLSOptionsKey is the key of the TLS options requiring the clients to present a certificate issued
+	// by the mesh CA in identity ACL mode.
+	ACLIdentityTLSOptionsKey = "traefik-mesh-acl-identity"
+
+	aclIdentityClientCertMiddlewareKey = "acl-identity-client-cert"
+)
+
+// buildIdentityMiddleware adds to the given configuration, under the given key, the middleware authorizing the requests
+// carrying a client certificate with one of the given identities. It must be preceded by the middleware passing the
+// client certificate SANs to the API, added along with the TLS options requiring the client certificate.
+func (p *Provider) buildIdentityMiddleware(cfg *dynamic.Configuration, key string, identities []string) {
+	if cfg.TLS == nil {
+		cfg.TLS = &dynamic.TLSConfiguration{Options: make(map[string]traefiktls.Options)}
+	}
+
+	if _, ok := cfg.TLS.Options[ACLIdentityTLSOptionsKey]; !ok {
+		cfg.TLS.Options[ACLIdentityTLSOptionsKey] = traefiktls.Options{
+			MinVersion: "VersionTLS12",
+			ClientAuth: traefiktls.ClientAuth{
+				CAFiles:        []traefiktls.FileOrContent{traefiktls.FileOrContent(p.config.MTLSRootCA)},
+				ClientAuthType: "RequireAndVerifyClientCert",
+			},
+		}
+
+		cfg.HTTP.Middlewares[aclIdentityClientCertMiddlewareKey] = &dynamic.Middleware{
+			PassTLSClientCert: &dynamic.PassTLSClientCert{
+				Info: &dynamic.TLSClientCertificateInfo{Sans: true},
+			},
+		}
+	}
+
+	query := make(url.Values)
+	for _, identity := range identities {
+		query.Add("identity", identity)
+	}
+
+	forwardAuth := &dynamic.ForwardAuth{
+		AuthRequestHeaders: []string{ACLClientCertInfoHeader},
+	}
+
+	// The API certificate is verified with the given CA when it is served over TLS.
+	if p.config.ACLAuthCA != "" {
+		forwardAuth.TLS = &types.ClientTLS{CA: p.config.ACLAuthCA}
+	}
+
+	// In audit mode, the API lets the requests through, and tags the ones which would have been denied.
+	if p.config.ACLAudit {
+		query.Set("audit", "true")
+		forwardAuth.AuthResponseHeaders = append(forwardAuth.AuthResponseHeaders, ACLAuditHeader)
+	}
+
+	// The identity of the actual client is only known once the request is authorized.
+	if p.config.TracingHeaders {
+		forwardAuth.AuthResponseHeaders = append(forwardAuth.AuthResponseHeaders, ACLSourceIdentityHeader)
+	}
+
+	forwardAuth.Address = p.config.ACLAuthURL + ACLIdentityAuthorizePath + "?" + query.Encode()
+
+	cfg.HTTP.Middlewares[key] = &dynamic.Middleware{ForwardAuth: forwardAuth}
+}
+
+// buildIdentityRouter builds a router which accepts only the requests sent over mTLS, and enforces the identity of the
+// client with the given identity middleware.
+func buildIdentityRouter(routerRule string, entrypoint string, middlewares []string, identityMiddlewareKey string, svcKey string, priority int) *dynamic.Router {
+	middlewares = addToSliceCopy(middlewares, aclIdentityClientCertMiddlewareKey)
+	middlewares = addToSliceCopy(middlewares, identityMiddlewareKey)
+
+	router := buildHTTPRouter(routerRule, entrypoint, middlewares, svcKey, priority)
+	router.TLS = &dynamic.RouterTLSConfig{Options: ACLIdentityTLSOptionsKey}
+
+	return router
+}
+
+// buildIdentitiesFromTrafficTarget returns the SPIFFE IDs of the service accounts listed in the
+// ServiceTrafficTarget.Sources.
+func (p *Provider) buildIdentitiesFromTrafficTarget(tt *topology.ServiceTrafficTarget) []string {
+	identities := make(map[string]struct{})
+
+	for _, source := range tt.Sources {
+		identities[mtls.SPIFFEID(p.config.MTLSTrustDomain, source.Namespace, source.ServiceAccount).String()] = struct{}{}
+	}
+
+	return sortedIdentities(identities)
+}
+
+// buildIdentitiesFromTrafficSplit returns the SPIFFE IDs of the service accounts of the Pods that can access all the
+// leaves of the TrafficSplit.
+func (p *Provider) buildIdentitiesFromTrafficSplit(t *topology.Topology, ts *topology.TrafficSplit) []string {
+	identities := make(map[string]struct{})
+
+	for _, podKey := range ts.Incoming {
+		pod, ok := t.Pods[podKey]
+		if !ok {
+			p.logger.Errorf("Unable to find Pod %q for identities from Traffic Split %s@%s", podKey, topology.Key{Name: ts.Name, Namespace: ts.Namespace})
+			continue
+		}
+
+		serviceAccount := pod.ServiceAccount
+		if serviceAccount == "" {
+			serviceAccount = "default"
+		}
+
+		identities[mtls.SPIFFEID(p.config.MTLSTrustDomain, pod.Namespace, serviceAccount).String()] = struct{}{}
+	}
+
+	return sortedIdentities(identities)
+}
+
+func sortedIdentities(identities map[string]struct{}) []string {
+	sorted := make([]string, 0, len(identities))
+	for identity := range identities {
+		sorted = append(sorted, identity)
+	}
+
+	sort.Strings(sorted)
+
+	return sorted
+}
@@ -35,6 +35,22 @@ func getWhitelistMiddlewareKeyFromTrafficSplitIndirect(ts *topology.TrafficSplit
 	return fmt.Sprintf("%s-%s-%s-whitelist-traffic-split-indirect", ts.Service.Namespace, ts.Service.Name, ts.Name)
 }
 
+func getTCPWhitelistMiddlewareKeyFromService(svc *topology.Service, port int32) string {
+	return fmt.Sprintf("%s-%s-%d-whitelist-traffic-target-tcp", svc.Namespace, svc.Name, port)
+}
+
+func getTCPWhitelistMiddlewareKeyFromTrafficSplit(ts *topology.TrafficSplit) string {
+	return fmt.Sprintf("%s-%s-%s-whitelist-traffic-split-tcp", ts.Service.Namespace, ts.Service.Name, ts.Name)
+}
+
+func getIdentityMiddlewareKeyFromTrafficTarget(tt *topology.ServiceTrafficTarget) string {
+	return fmt.Sprintf("%s-%s-%s-identity-traffic-target", tt.Service.Namespace, tt.Service.Name, tt.Name)
+}
+
+func getIdentityMiddlewareKeyFromTrafficSplit(ts *topology.TrafficSplit) string {
+	return fmt.Sprintf("%s-%s-%s-identity-traffic-split", ts.Service.Namespace, ts.Service.Name, ts.Name)
+}
+
 func getServiceKeyFromTrafficTarget(tt *topology.ServiceTrafficTarget, port int32) string {
 	return fmt.Sprintf("%s-%s-%s-%d-traffic-target", tt.Service.Namespace, tt.Service.Name, tt.Name, port)
 }
@@ -47,6 +63,10 @@ func getRouterKeyFromTrafficTargetIndirect(tt *topology.ServiceTrafficTarget, po
 	return fmt.Sprintf("%s-%s-%s-%d-traffic-target-indirect", tt.Service.Namespace, tt.Service.Name, tt.Name, port)
 }
 
+func getRouterKeyFromTrafficTargetIdentity(tt *topology.ServiceTrafficTarget, port int32) string {
+	return fmt.Sprintf("%s-%s-%s-%d-traffic-target-identity", tt.Service.Namespace, tt.Service.Name, tt.Name, port)
+}
+
 func getServiceKeyFromTrafficSplit(ts *topology.TrafficSplit, port int32) string {
 	return fmt.Sprintf("%s-%s-%s-%d-traffic-split", ts.Service.Namespace, ts.Service.Name, ts.Name, port)
 }
@@ -59,6 +79,14 @@ func getRouterKeyFromTrafficSplitIndirect(ts *topology.TrafficSplit, port int32)
 	return fmt.Sprintf("%s-%s-%s-%d-traffic-split-indirect", ts.Service.Namespace, ts.Service.Name, ts.Name, port)
 }
 
+func getRouterKeyFromTrafficSplitIdentity(ts *topology.TrafficSplit, port int32) string {
+	return fmt.Sprintf("%s-%s-%s-%d-traffic-split-identity", ts.Service.Namespace, ts.Service.Name, ts.Name, port)
+}
+
 func getServiceKeyFromTrafficSplitBackend(ts *topology.TrafficSplit, port int32, backend topology.TrafficSplitBackend) string {
 	return fmt.Sprintf("%s-%s-%s-%d-%s-traffic-split-backend", ts.Service.Namespace, ts.Service.Name, ts.Name, port, backend.Service.Name)
 }
+
+func getServersTransportKey(svc *topology.Service) string {
+	return fmt.Sprintf("%s-%s-mtls", svc.Namespace, svc.Name)
+}
@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/traefik/mesh/pkg/annotations"
+	"github.com/traefik/mesh/pkg/topology"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+	traefiktls "github.com/traefik/traefik/v2/pkg/tls"
+)
+
+// buildServersTransport adds to the given configuration the servers transport used by the proxies to contact the pods
+// of the given service, and returns its key. It returns an empty key if mTLS is disabled or if the pods are not
+// contacted over HTTPS.
+//
+// The pods must present a certificate issued by the mesh CA for the "<name>.<namespace>.svc" server name. The client
+// certificate of each proxy is not part of the configuration, it is added by the API when a proxy fetches its
+// configuration.
+func (p *Provider) buildServersTransport(cfg *dynamic.Configuration, svc *topology.Service, scheme string) string {
+	if !p.config.MTLS || scheme != annotations.SchemeHTTPS {
+		return ""
+	}
+
+	if cfg.HTTP.ServersTransports == nil {
+		cfg.HTTP.ServersTransports = make(map[string]*dynamic.ServersTransport)
+	}
+
+	key := getServersTransportKey(svc)
+	cfg.HTTP.ServersTransports[key] = &dynamic.ServersTransport{
+		ServerName: fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace),
+		RootCAs:    []traefiktls.FileOrContent{traefiktls.FileOrContent(p.config.MTLSRootCA)},
+	}
+
+	return key
+}
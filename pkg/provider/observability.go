@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/traefik/mesh/pkg/topology"
+	"github.com/traefik/traefik/v2/pkg/config/dynamic"
+)
+
+// headerDestinationService is the header set on the requests forwarded by the proxies to identify the destination
+// mesh service, for the applications and the tracing agents to tag their spans with. The spans of the proxies are
+// already tagged by Traefik with the router and service names, which are derived from the destination service.
+const headerDestinationService = "X-Traefik-Mesh-Destination-Service"
+
+// buildObservabilityMiddleware builds a middleware tagging the requests sent to the given service with the
+// destination service. It returns nil if tracing headers are disabled.
+func (p *Provider) buildObservabilityMiddleware(svc *topology.Service) *dynamic.Middleware {
+	if !p.config.TracingHeaders {
+		return nil
+	}
+
+	return &dynamic.Middleware{
+		Headers: &dynamic.Headers{
+			CustomRequestHeaders: map[string]string{
+				headerDestinationService: getMeshServiceName(topology.Key{Name: svc.Name, Namespace: svc.Namespace}),
+			},
+		},
+	}
+}
+
+// getMeshServiceName returns the name used to identify the given service in the observability headers.
+func getMeshServiceName(svcKey topology.Key) string {
+	return fmt.Sprintf("%s.%s", svcKey.Name, svcKey.Namespace)
+}
@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 
 	"github.com/sirupsen/logrus"
 	"github.com/traefik/mesh/pkg/annotations"
+	"github.com/traefik/mesh/pkg/dns"
 	"github.com/traefik/mesh/pkg/topology"
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
 	corev1 "k8s.io/api/core/v1"
@@ -16,6 +18,9 @@ import (
 // MiddlewareBuilder is capable of building a middleware from service annotations.
 type MiddlewareBuilder func(annotations map[string]string) (map[string]*dynamic.Middleware, error)
 
+// TCPMiddlewareBuilder is capable of building a TCP middleware from service annotations.
+type TCPMiddlewareBuilder func(annotations map[string]string) (map[string]*dynamic.TCPMiddleware, error)
+
 // PortFinder finds service port mappings.
 type PortFinder interface {
 	Find(namespace, name string, port int32) (int32, bool)
@@ -42,27 +47,52 @@ type Config struct {
 	MaxHTTPPort        int32
 	ACL                bool
 	DefaultTrafficType string
+	TracingHeaders     bool
+	// MTLS enables mTLS between the proxies and the pods contacted over HTTPS, which must present a certificate
+	// issued by the mesh CA given in MTLSRootCA.
+	MTLS            bool
+	MTLSRootCA      string
+	MTLSTrustDomain string
+	// ACLIdentity enforces the TrafficTargets on the requests sent over mTLS with the identity of the client
+	// certificate, authorized by the API served at ACLAuthURL. The other requests are still enforced with the IPs of
+	// the source pods. ACLAuthCA is the PEM encoded CA verifying the API certificate, when it is served over TLS.
+	ACLIdentity bool
+	ACLAuthURL  string
+	ACLAuthCA   string
+	// ACLAudit builds the same ACL rules, but doesn't block the HTTP requests which are not authorized by a
+	// TrafficTarget. Instead, they are tagged with the ACLAuditHeader header.
+	ACLAudit bool
+	// Domains are the mesh domains the services are reachable through. The first one is used to reach the backends of
+	// the TrafficSplits. Defaults to the cluster DNS default mesh domains.
+	Domains []string
 }
 
 // Provider holds the configuration for generating dynamic configuration from a kubernetes cluster state.
 type Provider struct {
 	config Config
 
-	tcpStateTable          PortFinder
-	udpStateTable          PortFinder
-	buildServiceMiddleware MiddlewareBuilder
+	tcpStateTable             PortFinder
+	udpStateTable             PortFinder
+	buildServiceMiddleware    MiddlewareBuilder
+	buildServiceTCPMiddleware TCPMiddlewareBuilder
 
 	logger logrus.FieldLogger
 }
 
 // New creates a new Provider.
-func New(tcpStateTable, udpStateTable PortFinder, middlewareBuilder MiddlewareBuilder, cfg Config, logger logrus.FieldLogger) *Provider {
+func New(tcpStateTable, udpStateTable PortFinder, middlewareBuilder MiddlewareBuilder, tcpMiddlewareBuilder TCPMiddlewareBuilder, cfg Config, logger logrus.FieldLogger) *Provider {
+	// The main mesh domain is required to reach the TrafficSplit backends.
+	if len(cfg.Domains) == 0 {
+		cfg.Domains = dns.DefaultDomains()
+	}
+
 	return &Provider{
-		config:                 cfg,
-		tcpStateTable:          tcpStateTable,
-		udpStateTable:          udpStateTable,
-		logger:                 logger,
-		buildServiceMiddleware: middlewareBuilder,
+		config:                    cfg,
+		tcpStateTable:             tcpStateTable,
+		udpStateTable:             udpStateTable,
+		logger:                    logger,
+		buildServiceMiddleware:    middlewareBuilder,
+		buildServiceTCPMiddleware: tcpMiddlewareBuilder,
 	}
 }
 
@@ -135,16 +165,21 @@ func (p *Provider) buildConfigForService(t *topology.Topology, cfg *dynamic.Conf
 
 	var middlewareKeys []string
 
-	// Middlewares are currently supported only for HTTP services.
-	if trafficType == annotations.ServiceTypeHTTP {
+	// Middlewares are currently supported only for HTTP and TCP services.
+	switch trafficType {
+	case annotations.ServiceTypeHTTP:
 		middlewareKeys, err = p.buildMiddlewaresForConfigFromService(cfg, svc)
-		if err != nil {
-			return err
-		}
+	case annotations.ServiceTypeTCP:
+		middlewareKeys, err = p.buildTCPMiddlewaresForConfigFromService(cfg, svc)
 	}
 
-	// When ACL mode is on, all traffic must be forbidden unless explicitly authorized via a TrafficTarget.
-	if p.config.ACL {
+	if err != nil {
+		return err
+	}
+
+	// When ACL mode is on, all traffic must be forbidden unless explicitly authorized via a TrafficTarget. In audit
+	// mode, TCP and UDP traffic is allowed as if ACL mode was off, as it cannot be tagged.
+	if p.config.ACL && !(p.config.ACLAudit && trafficType != annotations.ServiceTypeHTTP) {
 		p.buildACLConfigRoutersAndServices(t, cfg, svc, scheme, trafficType, middlewareKeys)
 	} else {
 		err = p.buildConfigRoutersAndServices(t, cfg, svc, scheme, trafficType, middlewareKeys)
@@ -174,9 +209,51 @@ func (p *Provider) buildMiddlewaresForConfigFromService(cfg *dynamic.Configurati
 		return middlewareKeys, fmt.Errorf("unable to build middlewares: %w", err)
 	}
 
-	for name, middleware := range middlewares {
+	if observability := p.buildObservabilityMiddleware(svc); observability != nil {
+		middlewareKey := getMiddlewareKey(svc, "observability")
+		cfg.HTTP.Middlewares[middlewareKey] = observability
+
+		middlewareKeys = append(middlewareKeys, middlewareKey)
+	}
+
+	names := make([]string, 0, len(middlewares))
+	for name := range middlewares {
+		names = append(names, name)
+	}
+
+	// Sort the middlewares to keep the order of the router middlewares, and therefore the configuration version,
+	// stable between two builds.
+	sort.Strings(names)
+
+	for _, name := range names {
 		middlewareKey := getMiddlewareKey(svc, name)
-		cfg.HTTP.Middlewares[middlewareKey] = middleware
+		cfg.HTTP.Middlewares[middlewareKey] = middlewares[name]
+
+		middlewareKeys = append(middlewareKeys, middlewareKey)
+	}
+
+	return middlewareKeys, nil
+}
+
+func (p *Provider) buildTCPMiddlewaresForConfigFromService(cfg *dynamic.Configuration, svc *topology.Service) ([]string, error) {
+	middlewares, err := p.buildServiceTCPMiddleware(svc.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build TCP middlewares: %w", err)
+	}
+
+	names := make([]string, 0, len(middlewares))
+	for name := range middlewares {
+		names = append(names, name)
+	}
+
+	// Sort the middlewares to keep the order of the router middlewares stable between two builds.
+	sort.Strings(names)
+
+	var middlewareKeys []string
+
+	for _, name := range names {
+		middlewareKey := getMiddlewareKey(svc, name)
+		addTCPMiddleware(cfg, middlewareKey, middlewares[name])
 
 		middlewareKeys = append(middlewareKeys, middlewareKey)
 	}
@@ -195,7 +272,11 @@ func (p *Provider) buildConfigRoutersAndServices(t *topology.Topology, cfg *dyna
 
 func (p *Provider) buildACLConfigRoutersAndServices(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, scheme, trafficType string, middlewareKeys []string) {
 	if trafficType == annotations.ServiceTypeHTTP {
-		p.buildBlockAllRouters(cfg, svc)
+		if p.config.ACLAudit {
+			p.buildAuditRouters(t, cfg, svc, scheme, middlewareKeys)
+		} else {
+			p.buildBlockAllRouters(cfg, svc)
+		}
 	}
 
 	for _, ttKey := range svc.TrafficTargets {
@@ -217,7 +298,7 @@ func (p *Provider) buildServicesAndRoutersForService(t *topology.Topology, cfg *
 		p.buildServicesAndRoutersForHTTPService(t, cfg, svc, scheme, middlewares, svcKey)
 
 	case annotations.ServiceTypeTCP:
-		p.buildServicesAndRoutersForTCPService(t, cfg, svc, svcKey)
+		p.buildServicesAndRoutersForTCPService(t, cfg, svc, middlewares, svcKey)
 
 	case annotations.ServiceTypeUDP:
 		p.buildServicesAndRoutersForUDPService(t, cfg, svc, svcKey)
@@ -230,7 +311,7 @@ func (p *Provider) buildServicesAndRoutersForService(t *topology.Topology, cfg *
 }
 
 func (p *Provider) buildServicesAndRoutersForHTTPService(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, scheme string, middlewares []string, svcKey topology.Key) {
-	httpRule := buildHTTPRuleFromService(svc)
+	httpRule := buildHTTPRuleFromService(svc, p.config.Domains)
 
 	for portID, svcPort := range svc.Ports {
 		entrypoint, err := p.buildHTTPEntrypoint(portID)
@@ -244,12 +325,15 @@ func (p *Provider) buildServicesAndRoutersForHTTPService(t *topology.Topology, c
 
 		key := getServiceRouterKeyFromService(svc, svcPort.Port)
 
-		cfg.HTTP.Services[key] = p.buildHTTPServiceFromService(t, svc, scheme, svcPort)
+		service := p.buildHTTPServiceFromService(t, svc, scheme, svcPort)
+		service.LoadBalancer.ServersTransport = p.buildServersTransport(cfg, svc, scheme)
+
+		cfg.HTTP.Services[key] = service
 		cfg.HTTP.Routers[key] = buildHTTPRouter(httpRule, entrypoint, middlewares, key, priorityService)
 	}
 }
 
-func (p *Provider) buildServicesAndRoutersForTCPService(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, svcKey topology.Key) {
+func (p *Provider) buildServicesAndRoutersForTCPService(t *topology.Topology, cfg *dynamic.Configuration, svc *topology.Service, middlewares []string, svcKey topology.Key) {
 	rule := buildTCPRouterRule()
 
 	for _, svcPort := range svc.Ports {
@@ -265,7 +349,7 @@ func (p *Provider) buildServicesAndRoutersForTCPService(t *topology.Topology, cf
 		key := getServiceRouterKeyFromService(svc, svcPort.Port)
 
 		addTCPService(cfg, key, p.buildTCPServiceFromService(t, svc, svcPort))
-		addTCPRouter(cfg, key, buildTCPRouter(rule, entrypoint, key))
+		addTCPRouter(cfg, key, buildTCPRouter(rule, entrypoint, middlewares, key))
 	}
 }
 
@@ -303,7 +387,7 @@ func (p *Provider) buildServicesAndRoutersForTrafficTarget(t *topology.Topology,
 		p.buildHTTPServicesAndRoutersForTrafficTarget(t, tt, cfg, ttSvc, ttKey, scheme, middlewares)
 
 	case annotations.ServiceTypeTCP:
-		p.buildTCPServicesAndRoutersForTrafficTarget(t, tt, cfg, ttSvc, ttKey)
+		p.buildTCPServicesAndRoutersForTrafficTarget(t, tt, cfg, ttSvc, ttKey, middlewares)
 	default:
 		return fmt.Errorf("unknown traffic-type %q", trafficType)
 	}
@@ -314,9 +398,13 @@ func (p *Provider) buildServicesAndRoutersForTrafficTarget(t *topology.Topology,
 func (p *Provider) buildHTTPServicesAndRoutersForTrafficTarget(t *topology.Topology, tt *topology.ServiceTrafficTarget, cfg *dynamic.Configuration, ttSvc *topology.Service, ttKey topology.ServiceTrafficTargetKey, scheme string, middlewares []string) {
 	whitelistDirect := p.buildWhitelistMiddlewareFromTrafficTargetDirect(t, tt)
 	whitelistDirectKey := getWhitelistMiddlewareKeyFromTrafficTargetDirect(tt)
-	cfg.HTTP.Middlewares[whitelistDirectKey] = whitelistDirect
 
-	rule := buildHTTPRuleFromTrafficTarget(tt, ttSvc)
+	rule := buildHTTPRuleFromTrafficTarget(tt, ttSvc, p.config.Domains)
+
+	identityKey := getIdentityMiddlewareKeyFromTrafficTarget(tt)
+	if p.config.ACLIdentity {
+		p.buildIdentityMiddleware(cfg, identityKey, p.buildIdentitiesFromTrafficTarget(tt))
+	}
 
 	for portID, svcPort := range tt.Destination.Ports {
 		entrypoint, err := p.buildHTTPEntrypoint(portID)
@@ -329,36 +417,47 @@ func (p *Provider) buildHTTPServicesAndRoutersForTrafficTarget(t *topology.Topol
 		}
 
 		svcKey := getServiceKeyFromTrafficTarget(tt, svcPort.Port)
-		cfg.HTTP.Services[svcKey] = p.buildHTTPServiceFromTrafficTarget(t, tt, scheme, svcPort)
+		service := p.buildHTTPServiceFromTrafficTarget(t, tt, scheme, svcPort)
+		service.LoadBalancer.ServersTransport = p.buildServersTransport(cfg, ttSvc, scheme)
 
-		rtrMiddlewares := addToSliceCopy(middlewares, whitelistDirectKey)
+		cfg.HTTP.Services[svcKey] = service
 
 		directRtrKey := getRouterKeyFromTrafficTargetDirect(tt, svcPort.Port)
-		cfg.HTTP.Routers[directRtrKey] = buildHTTPRouter(rule, entrypoint, rtrMiddlewares, svcKey, priorityTrafficTargetDirect)
+		cfg.HTTP.Routers[directRtrKey] = p.buildWhitelistedHTTPRouter(cfg, rule, entrypoint, middlewares, whitelistDirectKey, whitelistDirect, svcKey, priorityTrafficTargetDirect)
+
+		if p.config.ACLIdentity {
+			identityRtrKey := getRouterKeyFromTrafficTargetIdentity(tt, svcPort.Port)
+			cfg.HTTP.Routers[identityRtrKey] = buildIdentityRouter(rule, entrypoint, middlewares, identityKey, svcKey, priorityTrafficTargetDirect)
+		}
 
 		// If the ServiceTrafficTarget is the backend of at least one TrafficSplit we need an additional router with
 		// a whitelist middleware which whitelists based on the X-Forwarded-For header instead of on the RemoteAddr value.
 		if len(ttSvc.BackendOf) > 0 {
 			whitelistIndirect := p.buildWhitelistMiddlewareFromTrafficTargetIndirect(t, tt)
 			whitelistIndirectKey := getWhitelistMiddlewareKeyFromTrafficTargetIndirect(tt)
-			cfg.HTTP.Middlewares[whitelistIndirectKey] = whitelistIndirect
 
-			rule = buildHTTPRuleFromTrafficTargetIndirect(tt, ttSvc)
-			rtrMiddlewares = addToSliceCopy(middlewares, whitelistIndirectKey)
+			indirectRule := buildHTTPRuleFromTrafficTargetIndirect(tt, ttSvc, p.config.Domains)
 
 			indirectRtrKey := getRouterKeyFromTrafficTargetIndirect(tt, svcPort.Port)
-			cfg.HTTP.Routers[indirectRtrKey] = buildHTTPRouter(rule, entrypoint, rtrMiddlewares, svcKey, priorityTrafficTargetIndirect)
+			cfg.HTTP.Routers[indirectRtrKey] = p.buildWhitelistedHTTPRouter(cfg, indirectRule, entrypoint, middlewares, whitelistIndirectKey, whitelistIndirect, svcKey, priorityTrafficTargetIndirect)
 		}
 	}
 }
 
-func (p *Provider) buildTCPServicesAndRoutersForTrafficTarget(t *topology.Topology, tt *topology.ServiceTrafficTarget, cfg *dynamic.Configuration, ttSvc *topology.Service, ttKey topology.ServiceTrafficTargetKey) {
+func (p *Provider) buildTCPServicesAndRoutersForTrafficTarget(t *topology.Topology, tt *topology.ServiceTrafficTarget, cfg *dynamic.Configuration, ttSvc *topology.Service, ttKey topology.ServiceTrafficTargetKey, middlewares []string) {
 	if !hasTrafficTargetRuleTCPRoute(tt) {
 		return
 	}
 
 	rule := buildTCPRouterRule()
 
+	// The connections forwarded by a TrafficSplit to one of its backends come from a proxy, and TCP doesn't carry the
+	// address of the source pod. Those backends can't be whitelisted, only the TCPRoute rule applies.
+	var whitelist *dynamic.Middleware
+	if len(ttSvc.BackendOf) == 0 {
+		whitelist = p.buildWhitelistMiddlewareFromTrafficTargetDirect(t, tt)
+	}
+
 	for _, svcPort := range tt.Destination.Ports {
 		entrypoint, err := p.buildTCPEntrypoint(ttSvc, svcPort.Port)
 		if err != nil {
@@ -371,8 +470,19 @@ func (p *Provider) buildTCPServicesAndRoutersForTrafficTarget(t *topology.Topolo
 
 		key := getServiceRouterKeyFromService(ttSvc, svcPort.Port)
 
+		rtrMiddlewares := middlewares
+
+		// All the TrafficTargets of a TCP service share the same router for a given port, so their sources are merged
+		// into the same whitelist.
+		if whitelist != nil {
+			whitelistKey := getTCPWhitelistMiddlewareKeyFromService(ttSvc, svcPort.Port)
+			addTCPWhitelistSourceRange(cfg, whitelistKey, whitelist.IPWhiteList.SourceRange)
+
+			rtrMiddlewares = addToSliceCopy(middlewares, whitelistKey)
+		}
+
 		addTCPService(cfg, key, p.buildTCPServiceFromTrafficTarget(t, tt, svcPort))
-		addTCPRouter(cfg, key, buildTCPRouter(rule, entrypoint, key))
+		addTCPRouter(cfg, key, buildTCPRouter(rule, entrypoint, rtrMiddlewares, key))
 	}
 }
 
@@ -392,7 +502,7 @@ func (p *Provider) buildServiceAndRoutersForTrafficSplit(t *topology.Topology, c
 		p.buildHTTPServiceAndRoutersForTrafficSplit(t, cfg, tsKey, scheme, ts, tsSvc, middlewares)
 
 	case annotations.ServiceTypeTCP:
-		p.buildTCPServiceAndRoutersForTrafficSplit(cfg, tsKey, ts, tsSvc)
+		p.buildTCPServiceAndRoutersForTrafficSplit(t, cfg, tsKey, ts, tsSvc, middlewares)
 
 	case annotations.ServiceTypeUDP:
 		p.buildUDPServiceAndRoutersForTrafficSplit(cfg, tsKey, ts, tsSvc)
@@ -405,16 +515,18 @@ func (p *Provider) buildServiceAndRoutersForTrafficSplit(t *topology.Topology, c
 }
 
 func (p *Provider) buildHTTPServiceAndRoutersForTrafficSplit(t *topology.Topology, cfg *dynamic.Configuration, tsKey topology.Key, scheme string, ts *topology.TrafficSplit, tsSvc *topology.Service, middlewares []string) {
-	rule := buildHTTPRuleFromTrafficSplit(ts, tsSvc)
+	rule := buildHTTPRuleFromTrafficSplit(ts, tsSvc, p.config.Domains)
 
-	rtrMiddlewares := middlewares
+	whitelistDirectKey := getWhitelistMiddlewareKeyFromTrafficSplitDirect(ts)
 
+	var whitelistDirect *dynamic.Middleware
 	if p.config.ACL {
-		whitelistDirect := p.buildWhitelistMiddlewareFromTrafficSplitDirect(t, ts)
-		whitelistDirectKey := getWhitelistMiddlewareKeyFromTrafficSplitDirect(ts)
-		cfg.HTTP.Middlewares[whitelistDirectKey] = whitelistDirect
+		whitelistDirect = p.buildWhitelistMiddlewareFromTrafficSplitDirect(t, ts)
+	}
 
-		rtrMiddlewares = addToSliceCopy(middlewares, whitelistDirectKey)
+	identityKey := getIdentityMiddlewareKeyFromTrafficSplit(ts)
+	if p.config.ACL && p.config.ACLIdentity {
+		p.buildIdentityMiddleware(cfg, identityKey, p.buildIdentitiesFromTrafficSplit(t, ts))
 	}
 
 	for portID, svcPort := range tsSvc.Ports {
@@ -440,27 +552,44 @@ func (p *Provider) buildHTTPServiceAndRoutersForTrafficSplit(t *topology.Topolog
 		cfg.HTTP.Services[svcKey] = buildHTTPServiceFromTrafficSplit(backendSvcs)
 
 		directRtrKey := getRouterKeyFromTrafficSplitDirect(ts, svcPort.Port)
-		cfg.HTTP.Routers[directRtrKey] = buildHTTPRouter(rule, entrypoint, rtrMiddlewares, svcKey, priorityTrafficSplit)
+		if whitelistDirect != nil {
+			cfg.HTTP.Routers[directRtrKey] = p.buildWhitelistedHTTPRouter(cfg, rule, entrypoint, middlewares, whitelistDirectKey, whitelistDirect, svcKey, priorityTrafficSplit)
+		} else {
+			cfg.HTTP.Routers[directRtrKey] = buildHTTPRouter(rule, entrypoint, middlewares, svcKey, priorityTrafficSplit)
+		}
+
+		if p.config.ACL && p.config.ACLIdentity {
+			identityRtrKey := getRouterKeyFromTrafficSplitIdentity(ts, svcPort.Port)
+			cfg.HTTP.Routers[identityRtrKey] = buildIdentityRouter(rule, entrypoint, middlewares, identityKey, svcKey, priorityTrafficSplit)
+		}
 
 		// If the ServiceTrafficSplit is a backend of at least one TrafficSplit we need an additional router with
 		// a whitelist middleware which whitelists based on the X-Forwarded-For header instead of on the RemoteAddr value.
 		if len(tsSvc.BackendOf) > 0 && p.config.ACL {
 			whitelistIndirect := p.buildWhitelistMiddlewareFromTrafficSplitIndirect(t, ts)
 			whitelistIndirectKey := getWhitelistMiddlewareKeyFromTrafficSplitIndirect(ts)
-			cfg.HTTP.Middlewares[whitelistIndirectKey] = whitelistIndirect
 
-			rule = buildHTTPRuleFromTrafficSplitIndirect(ts, tsSvc)
-			rtrMiddlewaresindirect := addToSliceCopy(middlewares, whitelistIndirectKey)
+			indirectRule := buildHTTPRuleFromTrafficSplitIndirect(ts, tsSvc, p.config.Domains)
 
 			indirectRtrKey := getRouterKeyFromTrafficSplitIndirect(ts, svcPort.Port)
-			cfg.HTTP.Routers[indirectRtrKey] = buildHTTPRouter(rule, entrypoint, rtrMiddlewaresindirect, svcKey, priorityTrafficTargetIndirect)
+			cfg.HTTP.Routers[indirectRtrKey] = p.buildWhitelistedHTTPRouter(cfg, indirectRule, entrypoint, middlewares, whitelistIndirectKey, whitelistIndirect, svcKey, priorityTrafficTargetIndirect)
 		}
 	}
 }
 
-func (p *Provider) buildTCPServiceAndRoutersForTrafficSplit(cfg *dynamic.Configuration, tsKey topology.Key, ts *topology.TrafficSplit, tsSvc *topology.Service) {
+func (p *Provider) buildTCPServiceAndRoutersForTrafficSplit(t *topology.Topology, cfg *dynamic.Configuration, tsKey topology.Key, ts *topology.TrafficSplit, tsSvc *topology.Service, middlewares []string) {
 	tcpRule := buildTCPRouterRule()
 
+	// As for the TrafficTargets, a TrafficSplit which is itself the backend of a TrafficSplit can't be whitelisted.
+	if p.config.ACL && !p.config.ACLAudit && len(tsSvc.BackendOf) == 0 {
+		whitelistKey := getTCPWhitelistMiddlewareKeyFromTrafficSplit(ts)
+		whitelist := p.buildWhitelistMiddlewareFromTrafficSplitDirect(t, ts)
+
+		addTCPWhitelistSourceRange(cfg, whitelistKey, whitelist.IPWhiteList.SourceRange)
+
+		middlewares = addToSliceCopy(middlewares, whitelistKey)
+	}
+
 	for _, svcPort := range tsSvc.Ports {
 		entrypoint, err := p.buildTCPEntrypoint(tsSvc, svcPort.Port)
 		if err != nil {
@@ -476,7 +605,7 @@ func (p *Provider) buildTCPServiceAndRoutersForTrafficSplit(cfg *dynamic.Configu
 		for i, backend := range ts.Backends {
 			backendSvcKey := getServiceKeyFromTrafficSplitBackend(ts, svcPort.Port, backend)
 
-			addTCPService(cfg, backendSvcKey, buildTCPSplitTrafficBackendService(backend, svcPort.TargetPort.IntVal))
+			addTCPService(cfg, backendSvcKey, buildTCPSplitTrafficBackendService(backend, p.config.Domains[0], svcPort.TargetPort.IntVal))
 
 			backendSvcs[i] = dynamic.TCPWRRService{
 				Name:   backendSvcKey,
@@ -487,7 +616,7 @@ func (p *Provider) buildTCPServiceAndRoutersForTrafficSplit(cfg *dynamic.Configu
 		key := getServiceRouterKeyFromService(tsSvc, svcPort.Port)
 
 		addTCPService(cfg, key, buildTCPServiceFromTrafficSplit(backendSvcs))
-		addTCPRouter(cfg, key, buildTCPRouter(tcpRule, entrypoint, key))
+		addTCPRouter(cfg, key, buildTCPRouter(tcpRule, entrypoint, middlewares, key))
 	}
 }
 
@@ -507,7 +636,7 @@ func (p *Provider) buildUDPServiceAndRoutersForTrafficSplit(cfg *dynamic.Configu
 		for i, backend := range ts.Backends {
 			backendSvcKey := getServiceKeyFromTrafficSplitBackend(ts, svcPort.Port, backend)
 
-			addUDPService(cfg, backendSvcKey, buildUDPSplitTrafficBackendService(backend, svcPort.TargetPort.IntVal))
+			addUDPService(cfg, backendSvcKey, buildUDPSplitTrafficBackendService(backend, p.config.Domains[0], svcPort.TargetPort.IntVal))
 
 			backendSvcs[i] = dynamic.UDPWRRService{
 				Name:   backendSvcKey,
@@ -538,7 +667,7 @@ func (p *Provider) buildServicesForTrafficSplitBackends(t *topology.Topology, cf
 
 		backendSvcKey := getServiceKeyFromTrafficSplitBackend(ts, svcPort.Port, backend)
 
-		cfg.HTTP.Services[backendSvcKey] = buildHTTPSplitTrafficBackendService(backend, scheme, svcPort.Port)
+		cfg.HTTP.Services[backendSvcKey] = buildHTTPSplitTrafficBackendService(backend, p.config.Domains[0], scheme, svcPort.Port)
 		backendSvcs[i] = dynamic.WRRService{
 			Name:   backendSvcKey,
 			Weight: getIntRef(backend.Weight),
@@ -549,7 +678,7 @@ func (p *Provider) buildServicesForTrafficSplitBackends(t *topology.Topology, cf
 }
 
 func (p *Provider) buildBlockAllRouters(cfg *dynamic.Configuration, svc *topology.Service) {
-	rule := buildHTTPRuleFromService(svc)
+	rule := buildHTTPRuleFromService(svc, p.config.Domains)
 
 	for portID, svcPort := range svc.Ports {
 		entrypoint, err := p.buildHTTPEntrypoint(portID)
@@ -770,7 +899,7 @@ func (p *Provider) buildWhitelistMiddlewareFromTrafficTargetDirect(t *topology.T
 				continue
 			}
 
-			IPs = append(IPs, pod.IP)
+			IPs = append(IPs, pod.IPs...)
 		}
 	}
 
@@ -794,7 +923,7 @@ func (p *Provider) buildWhitelistMiddlewareFromTrafficSplitDirect(t *topology.To
 			continue
 		}
 
-		IPs = append(IPs, pod.IP)
+		IPs = append(IPs, pod.IPs...)
 	}
 
 	return &dynamic.Middleware{
@@ -852,9 +981,9 @@ func buildUDPServiceFromTrafficSplit(backendSvc []dynamic.UDPWRRService) *dynami
 	}
 }
 
-func buildHTTPSplitTrafficBackendService(backend topology.TrafficSplitBackend, scheme string, port int32) *dynamic.Service {
+func buildHTTPSplitTrafficBackendService(backend topology.TrafficSplitBackend, domain, scheme string, port int32) *dynamic.Service {
 	server := dynamic.Server{
-		URL: fmt.Sprintf("%s://%s.%s.traefik.mesh:%d", scheme, backend.Service.Name, backend.Service.Namespace, port),
+		URL: fmt.Sprintf("%s://%s.%s.%s:%d", scheme, backend.Service.Name, backend.Service.Namespace, domain, port),
 	}
 
 	return &dynamic.Service{
@@ -865,9 +994,9 @@ func buildHTTPSplitTrafficBackendService(backend topology.TrafficSplitBackend, s
 	}
 }
 
-func buildTCPSplitTrafficBackendService(backend topology.TrafficSplitBackend, port int32) *dynamic.TCPService {
+func buildTCPSplitTrafficBackendService(backend topology.TrafficSplitBackend, domain string, port int32) *dynamic.TCPService {
 	server := dynamic.TCPServer{
-		Address: fmt.Sprintf("%s.%s.traefik.mesh:%d", backend.Service.Name, backend.Service.Namespace, port),
+		Address: fmt.Sprintf("%s.%s.%s:%d", backend.Service.Name, backend.Service.Namespace, domain, port),
 	}
 
 	return &dynamic.TCPService{
@@ -877,9 +1006,9 @@ func buildTCPSplitTrafficBackendService(backend topology.TrafficSplitBackend, po
 	}
 }
 
-func buildUDPSplitTrafficBackendService(backend topology.TrafficSplitBackend, port int32) *dynamic.UDPService {
+func buildUDPSplitTrafficBackendService(backend topology.TrafficSplitBackend, domain string, port int32) *dynamic.UDPService {
 	server := dynamic.UDPServer{
-		Address: fmt.Sprintf("%s.%s.traefik.mesh:%d", backend.Service.Name, backend.Service.Namespace, port),
+		Address: fmt.Sprintf("%s.%s.%s:%d", backend.Service.Name, backend.Service.Namespace, domain, port),
 	}
 
 	return &dynamic.UDPService{
@@ -899,9 +1028,10 @@ func buildHTTPRouter(routerRule string, entrypoint string, middlewares []string,
 	}
 }
 
-func buildTCPRouter(routerRule string, entrypoint string, svcKey string) *dynamic.TCPRouter {
+func buildTCPRouter(routerRule string, entrypoint string, middlewares []string, svcKey string) *dynamic.TCPRouter {
 	return &dynamic.TCPRouter{
 		EntryPoints: []string{entrypoint},
+		Middlewares: middlewares,
 		Service:     svcKey,
 		Rule:        routerRule,
 	}
@@ -956,6 +1086,48 @@ func addTCPRouter(config *dynamic.Configuration, key string, router *dynamic.TCP
 	config.TCP.Routers[key] = router
 }
 
+func addTCPMiddleware(config *dynamic.Configuration, key string, middleware *dynamic.TCPMiddleware) {
+	if config.TCP == nil {
+		config.TCP = &dynamic.TCPConfiguration{}
+	}
+
+	if config.TCP.Middlewares == nil {
+		config.TCP.Middlewares = map[string]*dynamic.TCPMiddleware{}
+	}
+
+	config.TCP.Middlewares[key] = middleware
+}
+
+// addTCPWhitelistSourceRange adds the given source range to the TCP IPWhiteList middleware with the given key, which is
+// created if it doesn't exist yet.
+func addTCPWhitelistSourceRange(config *dynamic.Configuration, key string, sourceRange []string) {
+	var whitelist *dynamic.TCPMiddleware
+	if config.TCP != nil {
+		whitelist = config.TCP.Middlewares[key]
+	}
+
+	if whitelist == nil {
+		whitelist = &dynamic.TCPMiddleware{IPWhiteList: &dynamic.TCPIPWhiteList{}}
+		addTCPMiddleware(config, key, whitelist)
+	}
+
+	for _, source := range sourceRange {
+		if !containsString(whitelist.IPWhiteList.SourceRange, source) {
+			whitelist.IPWhiteList.SourceRange = append(whitelist.IPWhiteList.SourceRange, source)
+		}
+	}
+}
+
+func containsString(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
+
 func addUDPService(config *dynamic.Configuration, key string, service *dynamic.UDPService) {
 	if config.UDP == nil {
 		config.UDP = &dynamic.UDPConfiguration{}
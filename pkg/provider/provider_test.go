@@ -9,6 +9,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/mesh/pkg/annotations"
 	"github.com/traefik/mesh/pkg/topology"
 	"github.com/traefik/traefik/v2/pkg/config/dynamic"
 )
@@ -29,6 +30,11 @@ func TestProvider_BuildConfig(t *testing.T) {
 	tests := []struct {
 		desc               string
 		acl                bool
+		tracingHeaders     bool
+		mtls               bool
+		aclIdentity        bool
+		aclAudit           bool
+		aclAuthCA          string
 		defaultTrafficType string
 		tcpStateTable      map[servicePort]int32
 		udpStateTable      map[servicePort]int32
@@ -55,6 +61,13 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:           "testdata/annotations-scheme-topology.json",
 			wantConfig:         "testdata/annotations-scheme-config.json",
 		},
+		{
+			desc:               "mTLS enabled: HTTPS service",
+			mtls:               true,
+			defaultTrafficType: "http",
+			topology:           "testdata/annotations-scheme-topology.json",
+			wantConfig:         "testdata/mtls-enabled-https-config.json",
+		},
 		{
 			desc:               "ACL disabled: basic HTTP service",
 			acl:                false,
@@ -62,6 +75,17 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:           "testdata/acl-disabled-http-basic-topology.json",
 			wantConfig:         "testdata/acl-disabled-http-basic-config.json",
 		},
+		{
+			desc:               "Annotations: TCP middlewares",
+			acl:                false,
+			defaultTrafficType: "tcp",
+			tcpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 5000,
+				{Namespace: "my-ns", Name: "svc-a", Port: 8081}: 5001,
+			},
+			topology:   "testdata/annotations-tcp-middlewares-topology.json",
+			wantConfig: "testdata/annotations-tcp-middlewares-config.json",
+		},
 		{
 			desc:               "ACL disabled: basic TCP service",
 			acl:                false,
@@ -98,6 +122,13 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:           "testdata/acl-enabled-http-basic-topology.json",
 			wantConfig:         "testdata/acl-enabled-http-basic-config.json",
 		},
+		{
+			desc:               "ACL enabled: dual-stack HTTP service",
+			acl:                true,
+			defaultTrafficType: "http",
+			topology:           "testdata/acl-enabled-http-dual-stack-topology.json",
+			wantConfig:         "testdata/acl-enabled-http-dual-stack-config.json",
+		},
 		{
 			desc:               "ACL enabled: basic TCP service",
 			acl:                true,
@@ -109,6 +140,19 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:   "testdata/acl-enabled-tcp-basic-topology.json",
 			wantConfig: "testdata/acl-enabled-tcp-basic-config.json",
 		},
+		{
+			desc:               "ACL enabled: TCP service with traffic-split",
+			acl:                true,
+			defaultTrafficType: "tcp",
+			tcpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 5000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 5001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 5002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 5003,
+			},
+			topology:   "testdata/acl-enabled-tcp-traffic-split-topology.json",
+			wantConfig: "testdata/acl-enabled-tcp-traffic-split-config.json",
+		},
 		{
 			desc:               "ACL enabled: HTTP service with http-route-group",
 			acl:                true,
@@ -130,6 +174,77 @@ func TestProvider_BuildConfig(t *testing.T) {
 			topology:           "testdata/acl-enabled-http-traffic-split-http-route-group-topology.json",
 			wantConfig:         "testdata/acl-enabled-http-traffic-split-http-route-group-config.json",
 		},
+		{
+			desc:               "ACL enabled: identity mode: HTTP service with traffic-split",
+			acl:                true,
+			mtls:               true,
+			aclIdentity:        true,
+			defaultTrafficType: "http",
+			topology:           "testdata/acl-enabled-http-traffic-split-topology.json",
+			wantConfig:         "testdata/acl-enabled-identity-http-traffic-split-config.json",
+		},
+		{
+			desc:               "ACL enabled: identity mode: API served over TLS",
+			acl:                true,
+			mtls:               true,
+			aclIdentity:        true,
+			aclAuthCA:          "api-ca",
+			defaultTrafficType: "http",
+			topology:           "testdata/acl-enabled-http-traffic-split-topology.json",
+			wantConfig:         "testdata/acl-enabled-identity-api-tls-config.json",
+		},
+		{
+			desc:               "ACL enabled: identity mode: tracing headers",
+			acl:                true,
+			mtls:               true,
+			aclIdentity:        true,
+			tracingHeaders:     true,
+			defaultTrafficType: "http",
+			topology:           "testdata/acl-enabled-http-traffic-split-topology.json",
+			wantConfig:         "testdata/acl-enabled-identity-tracing-headers-config.json",
+		},
+		{
+			desc:               "ACL enabled: audit mode: HTTP service with traffic-split",
+			acl:                true,
+			aclAudit:           true,
+			defaultTrafficType: "http",
+			topology:           "testdata/acl-enabled-http-traffic-split-topology.json",
+			wantConfig:         "testdata/acl-enabled-audit-http-traffic-split-config.json",
+		},
+		{
+			desc:               "ACL enabled: audit mode: basic TCP service",
+			acl:                true,
+			aclAudit:           true,
+			defaultTrafficType: "tcp",
+			tcpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 5000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8081}: 5001,
+			},
+			topology:   "testdata/acl-enabled-tcp-basic-topology.json",
+			wantConfig: "testdata/acl-enabled-audit-tcp-basic-config.json",
+		},
+		{
+			desc:               "ACL enabled: audit mode: TCP service with traffic-split",
+			acl:                true,
+			aclAudit:           true,
+			defaultTrafficType: "tcp",
+			tcpStateTable: map[servicePort]int32{
+				{Namespace: "my-ns", Name: "svc-a", Port: 8080}: 5000,
+				{Namespace: "my-ns", Name: "svc-b", Port: 8080}: 5001,
+				{Namespace: "my-ns", Name: "svc-c", Port: 8080}: 5002,
+				{Namespace: "my-ns", Name: "svc-d", Port: 8080}: 5003,
+			},
+			topology:   "testdata/acl-enabled-tcp-traffic-split-topology.json",
+			wantConfig: "testdata/acl-enabled-audit-tcp-traffic-split-config.json",
+		},
+		{
+			desc:               "ACL enabled: HTTP service with tracing headers",
+			acl:                true,
+			tracingHeaders:     true,
+			defaultTrafficType: "http",
+			topology:           "testdata/acl-enabled-http-tracing-headers-topology.json",
+			wantConfig:         "testdata/acl-enabled-http-tracing-headers-config.json",
+		},
 	}
 
 	for _, test := range tests {
@@ -145,11 +260,25 @@ func TestProvider_BuildConfig(t *testing.T) {
 				defaultTrafficType = test.defaultTrafficType
 			}
 
+			aclAuthURL := "http://traefik-mesh-controller.traefik-mesh.svc:9000"
+			if test.aclAuthCA != "" {
+				aclAuthURL = "https://traefik-mesh-controller.traefik-mesh.svc:9000"
+			}
+
 			cfg := Config{
 				MinHTTPPort:        10000,
 				MaxHTTPPort:        10010,
 				ACL:                test.acl,
 				DefaultTrafficType: defaultTrafficType,
+				TracingHeaders:     test.tracingHeaders,
+				MTLS:               test.mtls,
+				MTLSRootCA:         "root-ca",
+				MTLSTrustDomain:    "cluster.local",
+				ACLIdentity:        test.aclIdentity,
+				ACLAudit:           test.aclAudit,
+				ACLAuthURL:         aclAuthURL,
+				ACLAuthCA:          test.aclAuthCA,
+				Domains:            []string{"traefik.mesh", "maesh"},
 			}
 
 			tcpStateTable := func(namespace, name string, port int32) (int32, bool) {
@@ -172,7 +301,7 @@ func TestProvider_BuildConfig(t *testing.T) {
 				return nil, nil
 			}
 
-			p := New(stateTableMock(tcpStateTable), stateTableMock(udpStateTable), middlewareBuilder, cfg, logger)
+			p := New(stateTableMock(tcpStateTable), stateTableMock(udpStateTable), middlewareBuilder, annotations.BuildTCPMiddlewares, cfg, logger)
 
 			topo, err := loadTopology(test.topology)
 			require.NoError(t, err)
@@ -184,6 +313,56 @@ func TestProvider_BuildConfig(t *testing.T) {
 	}
 }
 
+func TestProvider_BuildConfigWithDefaultDomains(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	middlewareBuilder := func(a map[string]string) (map[string]*dynamic.Middleware, error) {
+		return nil, nil
+	}
+	noPort := func(namespace, name string, port int32) (int32, bool) {
+		return 0, false
+	}
+
+	cfg := Config{
+		MinHTTPPort:        10000,
+		MaxHTTPPort:        10010,
+		DefaultTrafficType: "http",
+	}
+
+	p := New(stateTableMock(noPort), stateTableMock(noPort), middlewareBuilder, annotations.BuildTCPMiddlewares, cfg, logger)
+
+	topo, err := loadTopology("testdata/acl-disabled-http-traffic-split-topology.json")
+	require.NoError(t, err)
+
+	assertConfig(t, "testdata/acl-disabled-http-traffic-split-config.json", p.BuildConfig(topo))
+}
+
+func TestProvider_buildMiddlewaresForConfigFromService(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	middlewareBuilder := func(a map[string]string) (map[string]*dynamic.Middleware, error) {
+		return map[string]*dynamic.Middleware{
+			"retry":           {Retry: &dynamic.Retry{Attempts: 2}},
+			"circuit-breaker": {CircuitBreaker: &dynamic.CircuitBreaker{Expression: "NetworkErrorRatio() > 0.5"}},
+			"rate-limit":      {RateLimit: &dynamic.RateLimit{Average: 100}},
+		}, nil
+	}
+
+	p := New(nil, nil, middlewareBuilder, annotations.BuildTCPMiddlewares, Config{}, logger)
+	svc := &topology.Service{Name: "svc", Namespace: "ns"}
+
+	for i := 0; i < 10; i++ {
+		cfg := NewDefaultDynamicConfig()
+
+		keys, err := p.buildMiddlewaresForConfigFromService(cfg, svc)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"ns-svc-circuit-breaker", "ns-svc-rate-limit", "ns-svc-retry"}, keys)
+	}
+}
+
 func loadTopology(filename string) (*topology.Topology, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -89,13 +89,31 @@ func appendHeaderFilter(matchParts []string, match *specs.HTTPMatch) []string {
 	return matchParts
 }
 
-func buildHTTPRuleFromService(svc *topology.Service) string {
-	return fmt.Sprintf("Host(`%[1]s.%[2]s.traefik.mesh`) || Host(`%[1]s.%[2]s.maesh`) || Host(`%s`)", svc.Name, svc.Namespace, svc.ClusterIP)
+// buildHTTPRuleFromService builds a rule matching the requests sent to the given service through one of the given mesh
+// domains, or one of its ClusterIPs.
+func buildHTTPRuleFromService(svc *topology.Service, domains []string) string {
+	hosts := make([]string, 0, len(domains)+len(svc.ClusterIPs))
+
+	for _, domain := range domains {
+		hosts = append(hosts, fmt.Sprintf("Host(`%s.%s.%s`)", svc.Name, svc.Namespace, domain))
+	}
+
+	for _, ip := range svc.ClusterIPs {
+		hosts = append(hosts, fmt.Sprintf("Host(`%s`)", ip))
+
+		// The port is stripped from the Host header before matching, but an IPv6 address sent without a port keeps
+		// its brackets.
+		if strings.Contains(ip, ":") {
+			hosts = append(hosts, fmt.Sprintf("Host(`[%s]`)", ip))
+		}
+	}
+
+	return strings.Join(hosts, " || ")
 }
 
-func buildHTTPRuleFromTrafficTarget(tt *topology.ServiceTrafficTarget, ttSvc *topology.Service) string {
+func buildHTTPRuleFromTrafficTarget(tt *topology.ServiceTrafficTarget, ttSvc *topology.Service, domains []string) string {
 	ttRule := buildHTTPRuleFromTrafficSpecs(tt.Rules)
-	svcRule := buildHTTPRuleFromService(ttSvc)
+	svcRule := buildHTTPRuleFromService(ttSvc, domains)
 
 	if ttRule != "" {
 		return fmt.Sprintf("(%s) && (%s)", svcRule, ttRule)
@@ -104,9 +122,9 @@ func buildHTTPRuleFromTrafficTarget(tt *topology.ServiceTrafficTarget, ttSvc *to
 	return svcRule
 }
 
-func buildHTTPRuleFromTrafficSplit(ts *topology.TrafficSplit, tsSvc *topology.Service) string {
+func buildHTTPRuleFromTrafficSplit(ts *topology.TrafficSplit, tsSvc *topology.Service, domains []string) string {
 	tsRule := buildHTTPRuleFromTrafficSpecs(ts.Rules)
-	svcRule := buildHTTPRuleFromService(tsSvc)
+	svcRule := buildHTTPRuleFromService(tsSvc, domains)
 
 	if tsRule != "" {
 		return fmt.Sprintf("(%s) && (%s)", svcRule, tsRule)
@@ -115,9 +133,9 @@ func buildHTTPRuleFromTrafficSplit(ts *topology.TrafficSplit, tsSvc *topology.Se
 	return svcRule
 }
 
-func buildHTTPRuleFromTrafficTargetIndirect(tt *topology.ServiceTrafficTarget, ttSvc *topology.Service) string {
+func buildHTTPRuleFromTrafficTargetIndirect(tt *topology.ServiceTrafficTarget, ttSvc *topology.Service, domains []string) string {
 	ttRule := buildHTTPRuleFromTrafficSpecs(tt.Rules)
-	svcRule := buildHTTPRuleFromService(ttSvc)
+	svcRule := buildHTTPRuleFromService(ttSvc, domains)
 	indirectRule := "HeadersRegexp(`X-Forwarded-For`, `.+`)"
 
 	if ttRule != "" {
@@ -127,9 +145,9 @@ func buildHTTPRuleFromTrafficTargetIndirect(tt *topology.ServiceTrafficTarget, t
 	return fmt.Sprintf("(%s) && %s", svcRule, indirectRule)
 }
 
-func buildHTTPRuleFromTrafficSplitIndirect(ts *topology.TrafficSplit, tsSvc *topology.Service) string {
+func buildHTTPRuleFromTrafficSplitIndirect(ts *topology.TrafficSplit, tsSvc *topology.Service, domains []string) string {
 	tsRule := buildHTTPRuleFromTrafficSpecs(ts.Rules)
-	svcRule := buildHTTPRuleFromService(tsSvc)
+	svcRule := buildHTTPRuleFromService(tsSvc, domains)
 	indirectRule := "HeadersRegexp(`X-Forwarded-For`, `.+`)"
 
 	if tsRule != "" {
@@ -99,6 +99,7 @@ func (b *Builder) evaluateService(res *resources, topology *Topology, svc *corev
 		Annotations: svc.Annotations,
 		Ports:       svc.Spec.Ports,
 		ClusterIP:   svc.Spec.ClusterIP,
+		ClusterIPs:  mk8s.ServiceClusterIPs(svc),
 		Pods:        pods,
 	}
 }
@@ -620,6 +621,7 @@ func getOrCreatePod(topology *Topology, pod *corev1.Pod) Key {
 			OwnerReferences: pod.OwnerReferences,
 			ContainerPorts:  containerPorts,
 			IP:              pod.Status.PodIP,
+			IPs:             mk8s.PodIPs(pod),
 		}
 	}
 
@@ -136,6 +136,7 @@ type Service struct {
 	Annotations map[string]string    `json:"annotations"`
 	Ports       []corev1.ServicePort `json:"ports,omitempty"`
 	ClusterIP   string               `json:"clusterIp"`
+	ClusterIPs  []string             `json:"clusterIps,omitempty"`
 	Pods        []Key                `json:"pods,omitempty"`
 
 	// List of TrafficTargets that are targeting pods which are selected by this service.
@@ -201,6 +202,7 @@ type Pod struct {
 	OwnerReferences []v1.OwnerReference    `json:"ownerReferences,omitempty"`
 	ContainerPorts  []corev1.ContainerPort `json:"containerPorts,omitempty"`
 	IP              string                 `json:"ip"`
+	IPs             []string               `json:"ips,omitempty"`
 
 	SourceOf      []ServiceTrafficTargetKey `json:"sourceOf,omitempty"`
 	DestinationOf []ServiceTrafficTargetKey `json:"destinationOf,omitempty"`